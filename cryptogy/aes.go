@@ -50,6 +50,7 @@ func PKCS7Unpadding(origData []byte) []byte {
 
 // AES加密，支持模式CBC、CFB、CTR、OFB，不支持ECB和GCM
 // 其中CBC模式一般需要填充，用法: c.SetPaddingFunc("PKCS5")
+// 也可以使用其他分组算法，例如SM4，用法: NewSM4Cipher("CBC", key)
 type AESCipher struct {
 	modeName  string
 	iv        []byte
@@ -59,13 +60,25 @@ type AESCipher struct {
 }
 
 func NewAESCipher(mode string, key []byte) (*AESCipher, error) {
-	c := &AESCipher{modeName: strings.ToUpper(mode)}
 	block, err := aes.NewCipher(key)
-	if err == nil {
-		c.Block = block
-		c.iv = key[:c.BlockSize()]
+	if err != nil {
+		return &AESCipher{modeName: strings.ToUpper(mode)}, err
 	}
-	return c, err
+	return NewBlockCipher(mode, block, key[:block.BlockSize()]), nil
+}
+
+// 国密SM4，密钥为16字节
+func NewSM4Cipher(mode string, key []byte) (*AESCipher, error) {
+	block, err := NewSM4Block(key)
+	if err != nil {
+		return &AESCipher{modeName: strings.ToUpper(mode)}, err
+	}
+	return NewBlockCipher(mode, block, key[:block.BlockSize()]), nil
+}
+
+// 使用任意分组算法，iv长度必须等于分组大小
+func NewBlockCipher(mode string, block cipher.Block, iv []byte) *AESCipher {
+	return &AESCipher{modeName: strings.ToUpper(mode), iv: iv, Block: block}
 }
 
 func (c *AESCipher) SetPaddingFunc(name string) {
@@ -125,7 +138,7 @@ func (c *AESCipher) Decrypt(cipherText []byte) ([]byte, error) {
 	if c.modeName == "CBC" {
 		c.GetDecrypter().CryptBlocks(origData, cipherText)
 	} else {
-		c.GetStream(true).XORKeyStream(cipherText, origData)
+		c.GetStream(true).XORKeyStream(origData, cipherText)
 	}
 	if c.Unpadding != nil {
 		origData = c.Unpadding(origData)
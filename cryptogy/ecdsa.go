@@ -0,0 +1,122 @@
+package cryptogy
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"errors"
+)
+
+// ECDSA签名，签名为ASN.1格式，哈希由曲线决定：P-256用SHA256，P-384用SHA384，P-521用SHA512
+// 用法: NewECDSACipher(privKey, pubKey).SignData([]byte("hello world"))
+type ECDSACipher struct {
+	privKey, pubKey []byte
+	priv            *ecdsa.PrivateKey
+	pub             *ecdsa.PublicKey
+}
+
+// 可通过openssl产生
+// openssl ecparam -name prime256v1 -genkey -noout -out ec_private_key.pem
+// openssl ec -in ec_private_key.pem -pubout -out ec_public_key.pem
+func NewECDSACipher(privKey, pubKey string) ECDSACipher {
+	return ECDSACipher{privKey: []byte(privKey), pubKey: []byte(pubKey)}
+}
+
+// 使用已解析的密钥，其中一个可以为nil
+func NewECDSACipherKey(priv *ecdsa.PrivateKey, pub *ecdsa.PublicKey) ECDSACipher {
+	if pub == nil && priv != nil {
+		pub = &priv.PublicKey
+	}
+	return ECDSACipher{priv: priv, pub: pub}
+}
+
+func (c ECDSACipher) GetPublicKey() (*ecdsa.PublicKey, error) {
+	if c.pub != nil {
+		return c.pub, nil
+	}
+	face, err := ParsePublicKeyPEM(c.pubKey)
+	if err != nil {
+		return nil, err
+	}
+	if key, ok := face.(*ecdsa.PublicKey); ok {
+		return key, nil
+	}
+	return nil, errors.New("public key is not ECDSA")
+}
+
+func (c ECDSACipher) GetPrivateKey() (*ecdsa.PrivateKey, error) {
+	if c.priv != nil {
+		return c.priv, nil
+	}
+	face, err := ParsePrivateKeyPEM(c.privKey)
+	if err != nil {
+		return nil, err
+	}
+	if key, ok := face.(*ecdsa.PrivateKey); ok {
+		return key, nil
+	}
+	return nil, errors.New("private key is not ECDSA")
+}
+
+// 曲线对应的哈希
+func CurveHash(curve elliptic.Curve) crypto.Hash {
+	switch curve.Params().BitSize {
+	case 384:
+		return crypto.SHA384
+	case 521:
+		return crypto.SHA512
+	}
+	return crypto.SHA256
+}
+
+func (c ECDSACipher) getCurve() elliptic.Curve {
+	if key, err := c.GetPublicKey(); err == nil {
+		return key.Curve
+	} else if priv, err := c.GetPrivateKey(); err == nil {
+		return priv.Curve
+	}
+	return elliptic.P256()
+}
+
+// 算法名称，如ES256
+func (c ECDSACipher) Algorithm() string {
+	return "ES" + hashBits(CurveHash(c.getCurve()))
+}
+
+// 签名
+func (c ECDSACipher) Sign(msg []byte) ([]byte, error) {
+	key, err := c.GetPrivateKey()
+	if err != nil {
+		return nil, err
+	}
+	hashed, err := HashSum(CurveHash(key.Curve), msg)
+	if err != nil {
+		return nil, err
+	}
+	return ecdsa.SignASN1(rand.Reader, key, hashed)
+}
+
+// 校验
+func (c ECDSACipher) Verify(msg, sig []byte) error {
+	key, err := c.GetPublicKey()
+	if err != nil {
+		return err
+	}
+	hashed, err := HashSum(CurveHash(key.Curve), msg)
+	if err != nil {
+		return err
+	}
+	if !ecdsa.VerifyASN1(key, hashed, sig) {
+		return VerifyFailedError
+	}
+	return nil
+}
+
+func (c ECDSACipher) SignData(msg []byte) ([]byte, error) {
+	return c.Sign(msg)
+}
+
+func (c ECDSACipher) VerifyData(msg, sig []byte) error {
+	return c.Verify(msg, sig)
+}
@@ -0,0 +1,91 @@
+package cryptogy
+
+import (
+	"crypto/ed25519"
+	"errors"
+)
+
+// Ed25519签名，不需要另外的哈希
+// 用法: NewEd25519Cipher(privKey, pubKey).Sign([]byte("hello world"))
+type Ed25519Cipher struct {
+	privKey, pubKey []byte
+	priv            ed25519.PrivateKey
+	pub             ed25519.PublicKey
+}
+
+// 可通过openssl产生
+// openssl genpkey -algorithm ed25519 -out ed_private_key.pem
+// openssl pkey -in ed_private_key.pem -pubout -out ed_public_key.pem
+func NewEd25519Cipher(privKey, pubKey string) Ed25519Cipher {
+	return Ed25519Cipher{privKey: []byte(privKey), pubKey: []byte(pubKey)}
+}
+
+// 使用已解析的密钥，其中一个可以为nil
+func NewEd25519CipherKey(priv ed25519.PrivateKey, pub ed25519.PublicKey) Ed25519Cipher {
+	if pub == nil && priv != nil {
+		pub = priv.Public().(ed25519.PublicKey)
+	}
+	return Ed25519Cipher{priv: priv, pub: pub}
+}
+
+func (c Ed25519Cipher) GetPublicKey() (ed25519.PublicKey, error) {
+	if c.pub != nil {
+		return c.pub, nil
+	}
+	face, err := ParsePublicKeyPEM(c.pubKey)
+	if err != nil {
+		return nil, err
+	}
+	if key, ok := face.(ed25519.PublicKey); ok {
+		return key, nil
+	}
+	return nil, errors.New("public key is not Ed25519")
+}
+
+func (c Ed25519Cipher) GetPrivateKey() (ed25519.PrivateKey, error) {
+	if c.priv != nil {
+		return c.priv, nil
+	}
+	face, err := ParsePrivateKeyPEM(c.privKey)
+	if err != nil {
+		return nil, err
+	}
+	if key, ok := face.(ed25519.PrivateKey); ok {
+		return key, nil
+	}
+	return nil, errors.New("private key is not Ed25519")
+}
+
+// 算法名称，采用JWA的写法
+func (c Ed25519Cipher) Algorithm() string {
+	return "EdDSA"
+}
+
+// 签名
+func (c Ed25519Cipher) Sign(msg []byte) ([]byte, error) {
+	key, err := c.GetPrivateKey()
+	if err != nil {
+		return nil, err
+	}
+	return ed25519.Sign(key, msg), nil
+}
+
+// 校验
+func (c Ed25519Cipher) Verify(msg, sig []byte) error {
+	key, err := c.GetPublicKey()
+	if err != nil {
+		return err
+	}
+	if !ed25519.Verify(key, msg, sig) {
+		return VerifyFailedError
+	}
+	return nil
+}
+
+func (c Ed25519Cipher) SignData(msg []byte) ([]byte, error) {
+	return c.Sign(msg)
+}
+
+func (c Ed25519Cipher) VerifyData(msg, sig []byte) error {
+	return c.Verify(msg, sig)
+}
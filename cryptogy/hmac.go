@@ -4,9 +4,11 @@ import (
 	"crypto/hmac"
 	"crypto/rand"
 	"crypto/sha256"
+	"crypto/sha512"
 	"encoding/base64"
 	"encoding/hex"
 	"hash"
+	"reflect"
 	"strings"
 )
 
@@ -83,3 +85,33 @@ func (p *SaltPassword) VerifyPassword(plainText, cipherText string) bool {
 	}
 	return false
 }
+
+// 算法名称，SHA256/384/512分别为HS256/384/512，SM3为HMAC-SM3，其他返回HMAC
+// 按哈希的类型和长度判断，SHA512/256、SHA3-256等同样长度的哈希不会被当作HS256
+func (h MacHash) Algorithm() string {
+	digest := h.creator()
+	if _, ok := digest.(*SM3Digest); ok {
+		return "HMAC-SM3"
+	}
+	for _, alg := range []struct {
+		name    string
+		creator NewHashFunc
+	}{{"HS256", sha256.New}, {"HS384", sha512.New384}, {"HS512", sha512.New}} {
+		d := alg.creator()
+		if reflect.TypeOf(d) == reflect.TypeOf(digest) && d.Size() == digest.Size() {
+			return alg.name
+		}
+	}
+	return "HMAC"
+}
+
+func (h MacHash) SignData(msg []byte) ([]byte, error) {
+	return h.MacSum(string(msg)), nil
+}
+
+func (h MacHash) VerifyData(msg, sig []byte) error {
+	if !hmac.Equal(sig, h.MacSum(string(msg))) {
+		return VerifyFailedError
+	}
+	return nil
+}
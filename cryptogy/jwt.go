@@ -0,0 +1,352 @@
+package cryptogy
+
+import (
+	"encoding/asn1"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	TokenMalformedError    = errors.New("token is malformed")
+	TokenKeyNotFoundError  = errors.New("token key is not found")
+	TokenExpiredError      = errors.New("token is expired")
+	TokenNotValidYetError  = errors.New("token is not valid yet")
+	TokenIssuedFutureError = errors.New("token is issued in the future")
+	TokenIssuerError       = errors.New("token issuer is invalid")
+	TokenAudienceError     = errors.New("token audience is invalid")
+)
+
+// JWS头部
+type JWSHeader struct {
+	Alg string `json:"alg"`
+	Typ string `json:"typ,omitempty"`
+	Kid string `json:"kid,omitempty"`
+}
+
+// 紧凑格式的JWS签名，返回 header.payload.signature
+func SignJWS(s Signer, kid string, payload []byte) (string, error) {
+	head, err := json.Marshal(JWSHeader{Alg: s.Algorithm(), Typ: "JWT", Kid: kid})
+	if err != nil {
+		return "", err
+	}
+	input := b64Enc(head) + "." + b64Enc(payload)
+	sig, err := s.SignData([]byte(input))
+	if err != nil {
+		return "", err
+	}
+	if strings.HasPrefix(s.Algorithm(), "ES") { // JWS使用r||s格式
+		if sig, err = ecdsaASN1ToRaw(sig, s.Algorithm()); err != nil {
+			return "", err
+		}
+	}
+	return input + "." + b64Enc(sig), nil
+}
+
+// 校验紧凑格式的JWS，返回头部和负载
+func VerifyJWS(token string, keys KeyResolver) (*JWSHeader, []byte, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, nil, TokenMalformedError
+	}
+	head, err := b64Dec(parts[0])
+	if err != nil {
+		return nil, nil, TokenMalformedError
+	}
+	header := new(JWSHeader)
+	if err = json.Unmarshal(head, header); err != nil {
+		return nil, nil, TokenMalformedError
+	}
+	payload, err := b64Dec(parts[1])
+	if err != nil {
+		return header, nil, TokenMalformedError
+	}
+	sig, err := b64Dec(parts[2])
+	if err != nil {
+		return header, nil, TokenMalformedError
+	}
+	v, err := keys.Resolve(header.Kid, header.Alg)
+	if err != nil {
+		return header, nil, err
+	}
+	// 防止算法混淆，头部的alg必须和密钥一致，不接受none
+	if v.Algorithm() != header.Alg {
+		return header, nil, VerifyFailedError
+	}
+	if strings.HasPrefix(header.Alg, "ES") {
+		if sig, err = ecdsaRawToASN1(sig, header.Alg); err != nil {
+			return header, nil, VerifyFailedError
+		}
+	}
+	input := token[:len(parts[0])+1+len(parts[1])]
+	if err = v.VerifyData([]byte(input), sig); err != nil {
+		return header, nil, err
+	}
+	return header, payload, nil
+}
+
+// 按kid查找校验密钥
+type KeyResolver interface {
+	Resolve(kid, alg string) (Verifier, error)
+}
+
+type KeyResolverFunc func(kid, alg string) (Verifier, error)
+
+func (f KeyResolverFunc) Resolve(kid, alg string) (Verifier, error) {
+	return f(kid, alg)
+}
+
+// 密钥环，kid到校验器的映射，可在运行中增删
+type KeyRing struct {
+	keys map[string]Verifier
+	lock sync.RWMutex
+}
+
+func NewKeyRing() *KeyRing {
+	return &KeyRing{keys: make(map[string]Verifier)}
+}
+
+// 从JWK集合创建，每个JWK需要有kid和alg
+func NewKeyRingJWKS(set *JWKSet) (*KeyRing, error) {
+	r := NewKeyRing()
+	for _, k := range set.Keys {
+		key, err := k.Key()
+		if err != nil {
+			return nil, err
+		}
+		v, err := NewVerifier(k.Alg, key)
+		if err != nil {
+			return nil, fmt.Errorf("jwk %q: %s", k.Kid, err)
+		}
+		r.Add(k.Kid, v)
+	}
+	return r, nil
+}
+
+func (r *KeyRing) Add(kid string, v Verifier) *KeyRing {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.keys[kid] = v
+	return r
+}
+
+func (r *KeyRing) Remove(kid string) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	delete(r.keys, kid)
+}
+
+func (r *KeyRing) Resolve(kid, alg string) (Verifier, error) {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+	if v, ok := r.keys[kid]; ok {
+		return v, nil
+	}
+	return nil, TokenKeyNotFoundError
+}
+
+// JWT声明
+type Claims map[string]interface{}
+
+// 取得数字声明，如exp、nbf、iat
+func (c Claims) GetInt(name string) (int64, bool) {
+	switch v := c[name].(type) {
+	case float64:
+		return int64(v), true
+	case int64:
+		return v, true
+	case int:
+		return int64(v), true
+	case json.Number:
+		n, err := v.Int64()
+		return n, err == nil
+	}
+	return 0, false
+}
+
+func (c Claims) GetString(name string) string {
+	if v, ok := c[name].(string); ok {
+		return v
+	}
+	return ""
+}
+
+// aud可以是字符串或字符串数组
+func (c Claims) Audience() []string {
+	switch v := c["aud"].(type) {
+	case string:
+		return []string{v}
+	case []string:
+		return v
+	case []interface{}:
+		var result []string
+		for _, a := range v {
+			if s, ok := a.(string); ok {
+				result = append(result, s)
+			}
+		}
+		return result
+	}
+	return nil
+}
+
+// JWT签发
+type JWTIssuer struct {
+	Signer   Signer
+	Kid      string
+	Issuer   string
+	Audience string
+	Expire   time.Duration // 有效期，0为不过期
+	Now      func() time.Time
+}
+
+func NewJWTIssuer(s Signer, kid string, expire time.Duration) *JWTIssuer {
+	return &JWTIssuer{Signer: s, Kid: kid, Expire: expire, Now: time.Now}
+}
+
+// 签发，自动补充iat、exp、iss、aud
+func (i *JWTIssuer) Issue(claims Claims) (string, error) {
+	now := time.Now()
+	if i.Now != nil {
+		now = i.Now()
+	}
+	c := Claims{"iat": now.Unix()}
+	if i.Expire > 0 {
+		c["exp"] = now.Add(i.Expire).Unix()
+	}
+	if i.Issuer != "" {
+		c["iss"] = i.Issuer
+	}
+	if i.Audience != "" {
+		c["aud"] = i.Audience
+	}
+	for k, v := range claims {
+		c[k] = v
+	}
+	payload, err := json.Marshal(c)
+	if err != nil {
+		return "", err
+	}
+	return SignJWS(i.Signer, i.Kid, payload)
+}
+
+// JWT校验
+type JWTValidator struct {
+	Keys     KeyResolver
+	Issuer   string        // 不为空时检查iss
+	Audience string        // 不为空时检查aud
+	Leeway   time.Duration // 允许的时钟误差
+	Now      func() time.Time
+}
+
+func NewJWTValidator(keys KeyResolver, leeway time.Duration) *JWTValidator {
+	return &JWTValidator{Keys: keys, Leeway: leeway, Now: time.Now}
+}
+
+// 校验签名和exp、nbf、iat、iss、aud
+func (v *JWTValidator) Validate(token string) (Claims, error) {
+	_, payload, err := VerifyJWS(token, v.Keys)
+	if err != nil {
+		return nil, err
+	}
+	claims := Claims{}
+	if err = json.Unmarshal(payload, &claims); err != nil {
+		return nil, TokenMalformedError
+	}
+	if err = v.Check(claims); err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
+
+// 只检查声明，不检查签名
+func (v *JWTValidator) Check(claims Claims) error {
+	now := time.Now()
+	if v.Now != nil {
+		now = v.Now()
+	}
+	leeway := int64(v.Leeway / time.Second)
+	ts := now.Unix()
+	exp, hasExp, err := timeClaim(claims, "exp")
+	if err != nil {
+		return err
+	} else if hasExp && ts >= exp+leeway {
+		return TokenExpiredError
+	}
+	nbf, hasNbf, err := timeClaim(claims, "nbf")
+	if err != nil {
+		return err
+	} else if hasNbf && ts < nbf-leeway {
+		return TokenNotValidYetError
+	}
+	iat, hasIat, err := timeClaim(claims, "iat")
+	if err != nil {
+		return err
+	} else if hasIat && ts < iat-leeway {
+		return TokenIssuedFutureError
+	}
+	if v.Issuer != "" && claims.GetString("iss") != v.Issuer {
+		return TokenIssuerError
+	}
+	if v.Audience != "" {
+		for _, aud := range claims.Audience() {
+			if aud == v.Audience {
+				return nil
+			}
+		}
+		return TokenAudienceError
+	}
+	return nil
+}
+
+// 时间声明，不存在时ok为false，存在但不是数字（包括null）时出错
+func timeClaim(claims Claims, name string) (int64, bool, error) {
+	if _, ok := claims[name]; !ok {
+		return 0, false, nil
+	}
+	if n, ok := claims.GetInt(name); ok {
+		return n, true, nil
+	}
+	return 0, false, TokenMalformedError
+}
+
+type ecdsaSignature struct {
+	R, S *big.Int
+}
+
+// ES256/384/512的r、s长度
+func ecdsaSize(alg string) int {
+	switch alg {
+	case "ES384":
+		return 48
+	case "ES512":
+		return 66
+	}
+	return 32
+}
+
+func ecdsaASN1ToRaw(sig []byte, alg string) ([]byte, error) {
+	var es ecdsaSignature
+	if _, err := asn1.Unmarshal(sig, &es); err != nil {
+		return nil, err
+	}
+	size := ecdsaSize(alg)
+	raw := make([]byte, size*2)
+	es.R.FillBytes(raw[:size])
+	es.S.FillBytes(raw[size:])
+	return raw, nil
+}
+
+func ecdsaRawToASN1(raw []byte, alg string) ([]byte, error) {
+	size := ecdsaSize(alg)
+	if len(raw) != size*2 {
+		return nil, errors.New("bad ECDSA signature size")
+	}
+	r := new(big.Int).SetBytes(raw[:size])
+	s := new(big.Int).SetBytes(raw[size:])
+	return asn1.Marshal(ecdsaSignature{r, s})
+}
@@ -0,0 +1,117 @@
+package cryptogy
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// RFC 7515 附录A.1 的HS256示例
+func TestJWSExample(t *testing.T) {
+	k := JWK{Kty: "oct", K: "AyM1SysPpbyDfgZld3umj1qzKObwVMkoqQ-EstJQLr_T-1qS0gZH75aKtMN3Yj0iPS4hcgUuTwjAzZr1Z9CAow"}
+	secret, err := k.Key()
+	assert.NoError(t, err)
+	v, err := NewVerifier("HS256", secret)
+	assert.NoError(t, err)
+	token := "eyJ0eXAiOiJKV1QiLA0KICJhbGciOiJIUzI1NiJ9" +
+		".eyJpc3MiOiJqb2UiLA0KICJleHAiOjEzMDA4MTkzODAsDQogImh0dHA6Ly9leGFtcGxlLmNvbS9pc19yb290Ijp0cnVlfQ" +
+		".dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk"
+	jv := NewJWTValidator(NewKeyRing().Add("", v), time.Minute)
+	jv.Issuer = "joe"
+	jv.Now = func() time.Time { return time.Unix(1300819380, 0) }
+	claims, err := jv.Validate(token)
+	assert.NoError(t, err)
+	assert.Equal(t, true, claims["http://example.com/is_root"])
+	jv.Leeway = 0
+	_, err = jv.Validate(token)
+	assert.Equal(t, TokenExpiredError, err)
+}
+
+func TestJWTAlgorithms(t *testing.T) {
+	rsaKey, _ := ParsePrivateKeyPEM([]byte(privKey))
+	ecKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	_, edKey, _ := ed25519.GenerateKey(rand.Reader)
+	keys := map[string]interface{}{
+		"HS256": "secret", "HS384": "secret", "HS512": "secret",
+		"RS256": rsaKey, "PS256": rsaKey, "ES256": ecKey, "EdDSA": edKey,
+	}
+	ring := NewKeyRing()
+	for alg, key := range keys {
+		v, err := NewVerifier(alg, key)
+		assert.NoError(t, err)
+		ring.Add(alg, v)
+	}
+	jv := NewJWTValidator(ring, 0)
+	jv.Issuer, jv.Audience = "gozzo", "api"
+	for alg, key := range keys {
+		s, err := NewSigner(alg, key)
+		assert.NoError(t, err)
+		ji := NewJWTIssuer(s, alg, time.Hour)
+		ji.Issuer, ji.Audience = "gozzo", "api"
+		token, err := ji.Issue(Claims{"sub": "ryan"})
+		assert.NoError(t, err)
+		claims, err := jv.Validate(token)
+		assert.NoError(t, err, alg)
+		assert.Equal(t, "ryan", claims.GetString("sub"))
+		// 篡改负载
+		parts := strings.Split(token, ".")
+		parts[1] = b64Enc([]byte(`{"sub":"admin","iss":"gozzo","aud":"api"}`))
+		_, err = jv.Validate(strings.Join(parts, "."))
+		assert.Error(t, err, alg)
+	}
+}
+
+func TestJWTClaims(t *testing.T) {
+	now := time.Unix(1600000000, 0)
+	jv := &JWTValidator{Leeway: 30 * time.Second, Now: func() time.Time { return now }}
+	assert.NoError(t, jv.Check(Claims{"exp": float64(now.Unix() - 10)}))
+	assert.Equal(t, TokenExpiredError, jv.Check(Claims{"exp": float64(now.Unix() - 30)}))
+	assert.Equal(t, TokenNotValidYetError, jv.Check(Claims{"nbf": float64(now.Unix() + 31)}))
+	assert.Equal(t, TokenIssuedFutureError, jv.Check(Claims{"iat": float64(now.Unix() + 60)}))
+	jv.Audience = "api"
+	assert.NoError(t, jv.Check(Claims{"aud": []interface{}{"web", "api"}}))
+	assert.Equal(t, TokenAudienceError, jv.Check(Claims{"aud": "web"}))
+	jv.Issuer = "gozzo"
+	assert.Equal(t, TokenIssuerError, jv.Check(Claims{"aud": "api", "iss": "other"}))
+
+	// 时间声明不是数字时不能跳过检查，校验失败时不返回声明
+	s, _ := NewSigner("HS256", "secret")
+	v, _ := NewVerifier("HS256", "secret")
+	ring := NewKeyRing()
+	ring.Add("k1", v)
+	ji := NewJWTIssuer(s, "k1", time.Hour)
+	jv = NewJWTValidator(ring, 0)
+	for _, exp := range []interface{}{"0", nil, true} {
+		token, err := ji.Issue(Claims{"exp": exp})
+		assert.NoError(t, err)
+		claims, err := jv.Validate(token)
+		assert.Equal(t, TokenMalformedError, err, "%v", exp)
+		assert.Nil(t, claims)
+	}
+	assert.Equal(t, TokenMalformedError, jv.Check(Claims{"nbf": "later"}))
+	token, _ := ji.Issue(Claims{"exp": 0})
+	claims, err := jv.Validate(token)
+	assert.Equal(t, TokenExpiredError, err)
+	assert.Nil(t, claims)
+}
+
+func TestJWTAlgConfusion(t *testing.T) {
+	// 以HS256签发，但密钥环中kid对应RS256
+	rsaKey, _ := ParsePrivateKeyPEM([]byte(privKey))
+	v, _ := NewVerifier("RS256", rsaKey)
+	s, _ := NewSigner("HS256", pubKey)
+	token, err := SignJWS(s, "k1", []byte(`{}`))
+	assert.NoError(t, err)
+	_, _, err = VerifyJWS(token, NewKeyRing().Add("k1", v))
+	assert.Equal(t, VerifyFailedError, err)
+	_, _, err = VerifyJWS(token, NewKeyRing())
+	assert.Equal(t, TokenKeyNotFoundError, err)
+	_, _, err = VerifyJWS("abc.def", NewKeyRing())
+	assert.Equal(t, TokenMalformedError, err)
+}
@@ -0,0 +1,300 @@
+package cryptogy
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+)
+
+// 解析pem格式的私钥，支持PKCS1、SEC1(EC)和PKCS8
+// 返回*rsa.PrivateKey、*ecdsa.PrivateKey或ed25519.PrivateKey
+func ParsePrivateKeyPEM(data []byte) (crypto.PrivateKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("private key error!")
+	}
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		return x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		return x509.ParseECPrivateKey(block.Bytes)
+	}
+	return x509.ParsePKCS8PrivateKey(block.Bytes)
+}
+
+// 解析pem格式的公钥，支持PKIX、PKCS1和证书
+// 返回*rsa.PublicKey、*ecdsa.PublicKey或ed25519.PublicKey
+func ParsePublicKeyPEM(data []byte) (crypto.PublicKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("public key error !")
+	}
+	switch block.Type {
+	case "RSA PUBLIC KEY":
+		return x509.ParsePKCS1PublicKey(block.Bytes)
+	case "CERTIFICATE":
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		return cert.PublicKey, nil
+	}
+	return x509.ParsePKIXPublicKey(block.Bytes)
+}
+
+// JSON Web Key (RFC 7517)，支持RSA、EC、OKP(Ed25519)和oct(HMAC)
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid,omitempty"`
+	Alg string `json:"alg,omitempty"`
+	Use string `json:"use,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	D   string `json:"d,omitempty"`
+	P   string `json:"p,omitempty"`
+	Q   string `json:"q,omitempty"`
+	X   string `json:"x,omitempty"`
+	Y   string `json:"y,omitempty"`
+	K   string `json:"k,omitempty"`
+}
+
+// JWK集合
+type JWKSet struct {
+	Keys []JWK `json:"keys"`
+}
+
+func ParseJWK(data []byte) (*JWK, error) {
+	k := new(JWK)
+	if err := json.Unmarshal(data, k); err != nil {
+		return nil, err
+	}
+	return k, nil
+}
+
+func ParseJWKSet(data []byte) (*JWKSet, error) {
+	s := new(JWKSet)
+	if err := json.Unmarshal(data, s); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// 按kid查找
+func (s *JWKSet) Lookup(kid string) *JWK {
+	for i := range s.Keys {
+		if s.Keys[i].Kid == kid {
+			return &s.Keys[i]
+		}
+	}
+	return nil
+}
+
+// 将公钥或私钥转为JWK，HMAC密钥传入[]byte
+func NewJWK(key interface{}, kid string) (*JWK, error) {
+	k := &JWK{Kid: kid}
+	switch key := key.(type) {
+	case *rsa.PrivateKey:
+		pub, _ := NewJWK(&key.PublicKey, kid)
+		k = pub
+		k.D = b64Int(key.D)
+		if len(key.Primes) == 2 {
+			k.P, k.Q = b64Int(key.Primes[0]), b64Int(key.Primes[1])
+		}
+	case *rsa.PublicKey:
+		k.Kty = "RSA"
+		k.N, k.E = b64Int(key.N), b64Int(big.NewInt(int64(key.E)))
+	case *ecdsa.PrivateKey:
+		pub, err := NewJWK(&key.PublicKey, kid)
+		if err != nil {
+			return nil, err
+		}
+		k = pub
+		k.D = b64Fixed(key.D, curveBytes(key.Curve))
+	case *ecdsa.PublicKey:
+		k.Kty, k.Crv = "EC", key.Curve.Params().Name
+		size := curveBytes(key.Curve)
+		k.X, k.Y = b64Fixed(key.X, size), b64Fixed(key.Y, size)
+	case ed25519.PrivateKey:
+		k.Kty, k.Crv = "OKP", "Ed25519"
+		k.X = b64Enc(key.Public().(ed25519.PublicKey))
+		k.D = b64Enc(key.Seed())
+	case ed25519.PublicKey:
+		k.Kty, k.Crv = "OKP", "Ed25519"
+		k.X = b64Enc(key)
+	case []byte:
+		k.Kty, k.K = "oct", b64Enc(key)
+	default:
+		return nil, fmt.Errorf("unsupported key type %T", key)
+	}
+	return k, nil
+}
+
+// 取得密钥，有私有部分时返回私钥，否则返回公钥，oct类型返回[]byte
+func (k JWK) Key() (interface{}, error) {
+	switch k.Kty {
+	case "oct":
+		return b64Dec(k.K)
+	case "RSA":
+		return k.rsaKey()
+	case "EC":
+		return k.ecKey()
+	case "OKP":
+		return k.okpKey()
+	}
+	return nil, fmt.Errorf("unsupported key type %q", k.Kty)
+}
+
+// 取得公钥，oct类型出错
+func (k JWK) PublicKey() (crypto.PublicKey, error) {
+	key, err := k.Key()
+	if err != nil {
+		return nil, err
+	}
+	switch key := key.(type) {
+	case *rsa.PrivateKey:
+		return &key.PublicKey, nil
+	case *ecdsa.PrivateKey:
+		return &key.PublicKey, nil
+	case ed25519.PrivateKey:
+		return key.Public(), nil
+	case []byte:
+		return nil, errors.New("symmetric key has no public part")
+	}
+	return key, nil
+}
+
+func (k JWK) rsaKey() (interface{}, error) {
+	n, err := b64BigInt(k.N)
+	if err != nil {
+		return nil, err
+	}
+	e, err := b64BigInt(k.E)
+	if err != nil {
+		return nil, err
+	}
+	pub := &rsa.PublicKey{N: n, E: int(e.Int64())}
+	if k.D == "" {
+		return pub, nil
+	}
+	priv := &rsa.PrivateKey{PublicKey: *pub}
+	if priv.D, err = b64BigInt(k.D); err != nil {
+		return nil, err
+	}
+	if k.P != "" && k.Q != "" {
+		p, err := b64BigInt(k.P)
+		if err != nil {
+			return nil, err
+		}
+		q, err := b64BigInt(k.Q)
+		if err != nil {
+			return nil, err
+		}
+		priv.Primes = []*big.Int{p, q}
+		if err = priv.Validate(); err != nil {
+			return nil, err
+		}
+		priv.Precompute()
+	}
+	return priv, nil
+}
+
+func (k JWK) ecKey() (interface{}, error) {
+	var curve elliptic.Curve
+	switch k.Crv {
+	case "P-256":
+		curve = elliptic.P256()
+	case "P-384":
+		curve = elliptic.P384()
+	case "P-521":
+		curve = elliptic.P521()
+	default:
+		return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+	}
+	x, err := b64BigInt(k.X)
+	if err != nil {
+		return nil, err
+	}
+	y, err := b64BigInt(k.Y)
+	if err != nil {
+		return nil, err
+	}
+	if !curve.IsOnCurve(x, y) {
+		return nil, errors.New("point is not on curve")
+	}
+	pub := &ecdsa.PublicKey{Curve: curve, X: x, Y: y}
+	if k.D == "" {
+		return pub, nil
+	}
+	d, err := b64BigInt(k.D)
+	if err != nil {
+		return nil, err
+	}
+	return &ecdsa.PrivateKey{PublicKey: *pub, D: d}, nil
+}
+
+func (k JWK) okpKey() (interface{}, error) {
+	if k.Crv != "Ed25519" {
+		return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+	}
+	if k.D != "" {
+		seed, err := b64Dec(k.D)
+		if err != nil {
+			return nil, err
+		}
+		if len(seed) != ed25519.SeedSize {
+			return nil, errors.New("bad Ed25519 seed size")
+		}
+		return ed25519.NewKeyFromSeed(seed), nil
+	}
+	x, err := b64Dec(k.X)
+	if err != nil {
+		return nil, err
+	}
+	if len(x) != ed25519.PublicKeySize {
+		return nil, errors.New("bad Ed25519 public key size")
+	}
+	return ed25519.PublicKey(x), nil
+}
+
+func curveBytes(curve elliptic.Curve) int {
+	return (curve.Params().BitSize + 7) / 8
+}
+
+func b64Enc(data []byte) string {
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func b64Dec(s string) ([]byte, error) {
+	return base64.RawURLEncoding.DecodeString(s)
+}
+
+func b64Int(n *big.Int) string {
+	return b64Enc(n.Bytes())
+}
+
+// 定长大端编码，不足时左侧补零
+func b64Fixed(n *big.Int, size int) string {
+	buf := make([]byte, size)
+	return b64Enc(n.FillBytes(buf))
+}
+
+func b64BigInt(s string) (*big.Int, error) {
+	if s == "" {
+		return nil, errors.New("missing key parameter")
+	}
+	data, err := b64Dec(s)
+	if err != nil {
+		return nil, err
+	}
+	return new(big.Int).SetBytes(data), nil
+}
@@ -0,0 +1,148 @@
+package cryptogy
+
+import (
+	"crypto"
+	"crypto/hmac"
+	"crypto/rand"
+	_ "crypto/sha1"
+	_ "crypto/sha256"
+	_ "crypto/sha512"
+	"encoding/base32"
+	"encoding/binary"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var otpEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// 产生随机的base32密钥，size为字节数，RFC 4226建议至少20字节
+func RandSecret(size int) string {
+	buf := make([]byte, size)
+	if _, err := rand.Read(buf); err == nil {
+		return otpEncoding.EncodeToString(buf)
+	}
+	return ""
+}
+
+// 解码base32密钥，忽略大小写、空格和填充
+func DecodeSecret(secret string) ([]byte, error) {
+	secret = strings.ToUpper(strings.ReplaceAll(secret, " ", ""))
+	return otpEncoding.DecodeString(strings.TrimRight(secret, "="))
+}
+
+// 一次性密码，HOTP (RFC 4226) 和 TOTP (RFC 6238)
+// 用法: NewTOTP(RandSecret(20)).Generate(time.Now())
+type OTP struct {
+	Secret []byte
+	Hash   crypto.Hash // 默认SHA1，可选SHA256、SHA512
+	Digits int         // 位数，默认6
+	Period int         // TOTP时间步长（秒），默认30
+	Skew   int         // 校验时前后各允许的步数
+}
+
+// 使用base32密钥，默认SHA1、6位、30秒、前后1步
+func NewTOTP(secret string) (*OTP, error) {
+	key, err := DecodeSecret(secret)
+	if err != nil {
+		return nil, err
+	}
+	return &OTP{Secret: key, Hash: crypto.SHA1, Digits: 6, Period: 30, Skew: 1}, nil
+}
+
+func (o *OTP) digits() int {
+	if o.Digits <= 0 {
+		return 6
+	}
+	return o.Digits
+}
+
+func (o *OTP) period() int64 {
+	if o.Period <= 0 {
+		return 30
+	}
+	return int64(o.Period)
+}
+
+func (o *OTP) hash() crypto.Hash {
+	if o.Hash == 0 {
+		return crypto.SHA1
+	}
+	return o.Hash
+}
+
+// 计数器对应的HOTP
+func (o *OTP) HOTP(counter uint64) string {
+	var msg [8]byte
+	binary.BigEndian.PutUint64(msg[:], counter)
+	h := NewMacHash(o.hash().New).SetKey(string(o.Secret))
+	sum := h.MacSum(string(msg[:]))
+	offset := sum[len(sum)-1] & 0x0f // 动态截取
+	code := binary.BigEndian.Uint32(sum[offset:]) & 0x7fffffff
+	if digits := o.digits(); digits < 10 { // 31位的数最多10位，不用再取模
+		mod := uint32(1)
+		for i := 0; i < digits; i++ {
+			mod *= 10
+		}
+		code %= mod
+	}
+	return fmt.Sprintf("%0*d", o.digits(), code)
+}
+
+// 时间对应的计数器
+func (o *OTP) Counter(t time.Time) uint64 {
+	return uint64(t.Unix() / o.period())
+}
+
+// 产生TOTP
+func (o *OTP) Generate(t time.Time) string {
+	return o.HOTP(o.Counter(t))
+}
+
+// 校验TOTP，允许前后Skew个时间步长
+func (o *OTP) Validate(code string, t time.Time) bool {
+	_, ok := o.ValidateCounter(code, o.Counter(t), o.Skew, o.Skew)
+	return ok
+}
+
+// 校验HOTP，在[counter-behind, counter+ahead]范围内查找，返回匹配的计数器
+// 调用方应保存返回的计数器，下次从其后一个开始，避免重复使用
+func (o *OTP) ValidateCounter(code string, counter uint64, behind, ahead int) (uint64, bool) {
+	if len(code) != o.digits() {
+		return 0, false
+	}
+	start := counter - uint64(behind)
+	if uint64(behind) > counter {
+		start = 0
+	}
+	for c := start; c <= counter+uint64(ahead); c++ {
+		if hmac.Equal([]byte(o.HOTP(c)), []byte(code)) {
+			return c, true
+		}
+	}
+	return 0, false
+}
+
+// 产生otpauth://链接，用于生成二维码，kind为totp或hotp
+func (o *OTP) URI(kind, issuer, account string, counter uint64) string {
+	label := url.PathEscape(account)
+	if issuer != "" {
+		label = url.PathEscape(issuer) + ":" + label
+	}
+	query := url.Values{}
+	query.Set("secret", otpEncoding.EncodeToString(o.Secret))
+	if issuer != "" {
+		query.Set("issuer", issuer)
+	}
+	query.Set("algorithm", strings.ReplaceAll(o.hash().String(), "-", ""))
+	query.Set("digits", strconv.Itoa(o.digits()))
+	if kind == "hotp" {
+		query.Set("counter", strconv.FormatUint(counter, 10))
+	} else {
+		kind = "totp"
+		query.Set("period", strconv.FormatInt(o.period(), 10))
+	}
+	return "otpauth://" + kind + "/" + label + "?" + query.Encode()
+}
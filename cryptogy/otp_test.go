@@ -0,0 +1,74 @@
+package cryptogy
+
+import (
+	"crypto"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// RFC 4226 附录D
+func TestHOTPVectors(t *testing.T) {
+	o := &OTP{Secret: []byte("12345678901234567890")}
+	codes := []string{"755224", "287082", "359152", "969429", "338314",
+		"254676", "287922", "162583", "399871", "520489"}
+	for i, code := range codes {
+		assert.Equal(t, code, o.HOTP(uint64(i)))
+	}
+	c, ok := o.ValidateCounter("969429", 1, 0, 5)
+	assert.True(t, ok)
+	assert.Equal(t, uint64(3), c)
+	_, ok = o.ValidateCounter("755224", 1, 0, 5)
+	assert.False(t, ok)
+	// 截取后的十进制数，附录D
+	o.Digits = 10
+	assert.Equal(t, "1284755224", o.HOTP(0))
+	assert.Equal(t, "1094287082", o.HOTP(1))
+	o.Digits = 12
+	assert.Equal(t, "001284755224", o.HOTP(0))
+}
+
+// RFC 6238 附录B
+func TestTOTPVectors(t *testing.T) {
+	secrets := map[crypto.Hash]string{
+		crypto.SHA1:   "12345678901234567890",
+		crypto.SHA256: "12345678901234567890123456789012",
+		crypto.SHA512: "1234567890123456789012345678901234567890123456789012345678901234",
+	}
+	vectors := []struct {
+		ts    int64
+		codes map[crypto.Hash]string
+	}{
+		{59, map[crypto.Hash]string{crypto.SHA1: "94287082", crypto.SHA256: "46119246", crypto.SHA512: "90693936"}},
+		{1111111109, map[crypto.Hash]string{crypto.SHA1: "07081804", crypto.SHA256: "68084774", crypto.SHA512: "25091201"}},
+		{1111111111, map[crypto.Hash]string{crypto.SHA1: "14050471", crypto.SHA256: "67062674", crypto.SHA512: "99943326"}},
+		{1234567890, map[crypto.Hash]string{crypto.SHA1: "89005924", crypto.SHA256: "91819424", crypto.SHA512: "93441116"}},
+		{2000000000, map[crypto.Hash]string{crypto.SHA1: "69279037", crypto.SHA256: "90698825", crypto.SHA512: "38618901"}},
+		{20000000000, map[crypto.Hash]string{crypto.SHA1: "65353130", crypto.SHA256: "77737706", crypto.SHA512: "47863826"}},
+	}
+	for _, v := range vectors {
+		for hash, code := range v.codes {
+			o := &OTP{Secret: []byte(secrets[hash]), Hash: hash, Digits: 8, Period: 30}
+			assert.Equal(t, code, o.Generate(time.Unix(v.ts, 0)), "%d %s", v.ts, hash)
+		}
+	}
+}
+
+func TestTOTPValidate(t *testing.T) {
+	secret := RandSecret(20)
+	assert.Len(t, secret, 32)
+	o, err := NewTOTP(secret)
+	assert.NoError(t, err)
+	now := time.Now()
+	code := o.Generate(now)
+	assert.True(t, o.Validate(code, now))
+	assert.True(t, o.Validate(code, now.Add(30*time.Second)))
+	assert.False(t, o.Validate(code, now.Add(90*time.Second)))
+	assert.False(t, o.Validate("12345", now))
+
+	o, _ = NewTOTP("JBSWY3DPEHPK3PXP")
+	uri := o.URI("totp", "ACME Co", "john@example.com", 0)
+	assert.Equal(t, "otpauth://totp/ACME%20Co:john@example.com?algorithm=SHA1&digits=6"+
+		"&issuer=ACME+Co&period=30&secret=JBSWY3DPEHPK3PXP", uri)
+}
@@ -0,0 +1,196 @@
+package cryptogy
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 签名相关的请求头
+const (
+	HEADER_TIMESTAMP = "X-Timestamp"
+	HEADER_NONCE     = "X-Nonce"
+	HEADER_SIGNATURE = "X-Signature"
+)
+
+var (
+	SignatureMissingError = errors.New("request signature is missing")
+	RequestExpiredError   = errors.New("request timestamp is out of range")
+	NonceReplayedError    = errors.New("request nonce has been used")
+)
+
+// nonce存储，用于防重放，可使用redisw.RedisNonceStore
+type NonceStore interface {
+	// 记录nonce，已存在时返回false
+	SaveNonce(nonce string, expire time.Duration) (bool, error)
+}
+
+// 内存中的nonce存储，适用于单机
+type MemoryNonceStore struct {
+	nonces map[string]time.Time
+	mutex  sync.Mutex
+}
+
+func NewMemoryNonceStore() *MemoryNonceStore {
+	return &MemoryNonceStore{nonces: make(map[string]time.Time)}
+}
+
+func (s *MemoryNonceStore) SaveNonce(nonce string, expire time.Duration) (bool, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	now := time.Now()
+	for key, deadline := range s.nonces { // 顺便清理过期的
+		if now.After(deadline) {
+			delete(s.nonces, key)
+		}
+	}
+	if _, ok := s.nonces[nonce]; ok {
+		return false, nil
+	}
+	s.nonces[nonce] = now.Add(expire)
+	return true, nil
+}
+
+// 规范化请求，每项一行：
+// 方法、路径、排序后的查询串、选定的头部（小写名称:值）、时间戳、nonce、body的sha256
+func CanonicalRequest(req *http.Request, headers []string, timestamp, nonce string) (string, error) {
+	var buf strings.Builder
+	buf.WriteString(strings.ToUpper(req.Method) + "\n")
+	path := req.URL.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	buf.WriteString(path + "\n")
+	buf.WriteString(CanonicalQuery(req.URL.Query()) + "\n")
+	for _, name := range headers {
+		var values []string // 不能修改请求中的头部
+		for _, v := range req.Header.Values(name) {
+			values = append(values, strings.TrimSpace(v))
+		}
+		buf.WriteString(strings.ToLower(name) + ":" + strings.Join(values, ",") + "\n")
+	}
+	buf.WriteString(timestamp + "\n" + nonce + "\n")
+	sum, err := BodyHash(req)
+	if err != nil {
+		return "", err
+	}
+	buf.WriteString(sum)
+	return buf.String(), nil
+}
+
+// 按参数名和值排序后拼接
+func CanonicalQuery(query url.Values) string {
+	var pairs []string
+	for key, values := range query {
+		for _, v := range values {
+			pairs = append(pairs, url.QueryEscape(key)+"="+url.QueryEscape(v))
+		}
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, "&")
+}
+
+// 计算body的sha256，读取后会重置body以便后续使用
+func BodyHash(req *http.Request) (string, error) {
+	var body []byte
+	if req.Body != nil && req.Body != http.NoBody {
+		var err error
+		if body, err = ioutil.ReadAll(req.Body); err != nil {
+			return "", err
+		}
+		req.Body.Close()
+		req.Body = ioutil.NopCloser(bytes.NewReader(body))
+	}
+	sum := sha256.Sum256(body)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// HTTP请求签名，可使用MacHash或RSACipher等
+// 用法: NewRequestSigner(NewMacHash(sha256.New).SetKey("secret")).Sign(req)
+type RequestSigner struct {
+	Signer   Signer
+	Verifier Verifier
+	Headers  []string      // 参与签名的头部，如Content-Type
+	MaxSkew  time.Duration // 时间戳允许的误差
+	Nonces   NonceStore    // 为nil时不检查重放
+	Now      func() time.Time
+}
+
+func NewRequestSigner(s SignVerifier) *RequestSigner {
+	return &RequestSigner{
+		Signer: s, Verifier: s, MaxSkew: 5 * time.Minute,
+		Nonces: NewMemoryNonceStore(), Now: time.Now,
+	}
+}
+
+func (rs *RequestSigner) now() time.Time {
+	if rs.Now != nil {
+		return rs.Now()
+	}
+	return time.Now()
+}
+
+// 签名，写入时间戳、nonce和签名三个头部
+func (rs *RequestSigner) Sign(req *http.Request) error {
+	timestamp := strconv.FormatInt(rs.now().Unix(), 10)
+	nonce := RandSalt(32)
+	text, err := CanonicalRequest(req, rs.Headers, timestamp, nonce)
+	if err != nil {
+		return err
+	}
+	sig, err := rs.Signer.SignData([]byte(text))
+	if err != nil {
+		return err
+	}
+	req.Header.Set(HEADER_TIMESTAMP, timestamp)
+	req.Header.Set(HEADER_NONCE, nonce)
+	req.Header.Set(HEADER_SIGNATURE, base64.StdEncoding.EncodeToString(sig))
+	return nil
+}
+
+// 校验签名、时间戳，并记录nonce防重放
+func (rs *RequestSigner) Verify(req *http.Request) error {
+	timestamp := req.Header.Get(HEADER_TIMESTAMP)
+	nonce := req.Header.Get(HEADER_NONCE)
+	signed := req.Header.Get(HEADER_SIGNATURE)
+	if timestamp == "" || nonce == "" || signed == "" {
+		return SignatureMissingError
+	}
+	ts, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return RequestExpiredError
+	}
+	if skew := rs.now().Sub(time.Unix(ts, 0)); skew > rs.MaxSkew || skew < -rs.MaxSkew {
+		return RequestExpiredError
+	}
+	sig, err := base64.StdEncoding.DecodeString(signed)
+	if err != nil {
+		return VerifyFailedError
+	}
+	text, err := CanonicalRequest(req, rs.Headers, timestamp, nonce)
+	if err != nil {
+		return err
+	}
+	if err = rs.Verifier.VerifyData([]byte(text), sig); err != nil {
+		return err
+	}
+	if rs.Nonces != nil { // 签名正确后才记录，有效期覆盖时间戳的误差范围
+		ok, err := rs.Nonces.SaveNonce(nonce, rs.MaxSkew*2)
+		if err != nil {
+			return err
+		} else if !ok {
+			return NonceReplayedError
+		}
+	}
+	return nil
+}
@@ -0,0 +1,57 @@
+package cryptogy
+
+import (
+	"crypto"
+	"crypto/sha256"
+	"io/ioutil"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCanonicalRequest(t *testing.T) {
+	req := httptest.NewRequest("post", "/api/v1/orders?b=2&a=3&a=1&c=x+y", strings.NewReader("{}"))
+	req.Header.Set("Content-Type", " application/json ")
+	text, err := CanonicalRequest(req, []string{"Content-Type"}, "1600000000", "abc")
+	assert.NoError(t, err)
+	lines := strings.Split(text, "\n")
+	assert.Equal(t, []string{"POST", "/api/v1/orders", "a=1&a=3&b=2&c=x+y",
+		"content-type:application/json", "1600000000", "abc"}, lines[:6])
+	assert.Equal(t, "44136fa355b3678a1146ad16f7e8649e94fb4fc21fe77e8310c060f61caaff8a", lines[6])
+	assert.Equal(t, " application/json ", req.Header.Get("Content-Type")) // 不修改请求
+	// body仍然可以读取
+	body, _ := ioutil.ReadAll(req.Body)
+	assert.Equal(t, "{}", string(body))
+}
+
+func TestRequestSigner(t *testing.T) {
+	signers := []SignVerifier{
+		NewMacHash(sha256.New).SetKey("secret"),
+		NewRSACipher(privKey, pubKey).WithHash(crypto.SHA256, false),
+	}
+	for _, s := range signers {
+		rs := NewRequestSigner(s)
+		rs.Headers = []string{"Content-Type"}
+		req := httptest.NewRequest("PUT", "/users/1?x=1", strings.NewReader(origDatas[2]))
+		req.Header.Set("Content-Type", "text/plain")
+		assert.NoError(t, rs.Sign(req))
+		assert.NoError(t, rs.Verify(req))
+		// 重放
+		assert.Equal(t, NonceReplayedError, rs.Verify(req))
+
+		// 篡改查询串
+		assert.NoError(t, rs.Sign(req))
+		req.URL.RawQuery = "x=2"
+		assert.Equal(t, VerifyFailedError, rs.Verify(req))
+
+		// 过期
+		assert.NoError(t, rs.Sign(req))
+		rs.Now = func() time.Time { return time.Now().Add(10 * time.Minute) }
+		assert.Equal(t, RequestExpiredError, rs.Verify(req))
+	}
+	req := httptest.NewRequest("GET", "/", nil)
+	assert.Equal(t, SignatureMissingError, NewRequestSigner(signers[0]).Verify(req))
+}
@@ -4,8 +4,6 @@ import (
 	"crypto"
 	"crypto/rand"
 	"crypto/rsa"
-	"crypto/sha256"
-	"crypto/x509"
 	"encoding/pem"
 	"errors"
 )
@@ -86,16 +84,34 @@ UqWHmhg2z2Itx8B0m9cORIsCAwEAAQ==
 `
 */
 
-// RSA加密，支持模式PKCS#1 v1.5，不支持OAEP/PSS
+// RSA加密，支持模式PKCS#1 v1.5，签名另支持PSS，不支持OAEP
 // 用法: NewRSACipher(privKey, pubKey).Sign(crypto.SHA256, []byte("hello world"))
 type RSACipher struct {
 	privKey, pubKey []byte
+	priv            *rsa.PrivateKey
+	pub             *rsa.PublicKey
+	hash            crypto.Hash // SignData/VerifyData使用的哈希，默认SHA256
+	usePSS          bool        // SignData/VerifyData是否使用PSS填充
 }
 
 func NewRSACipher(privKey, pubKey string) RSACipher {
 	return RSACipher{privKey: []byte(privKey), pubKey: []byte(pubKey)}
 }
 
+// 使用已解析的密钥，例如来自JWK，其中一个可以为nil
+func NewRSACipherKey(priv *rsa.PrivateKey, pub *rsa.PublicKey) RSACipher {
+	if pub == nil && priv != nil {
+		pub = &priv.PublicKey
+	}
+	return RSACipher{priv: priv, pub: pub}
+}
+
+// 设置SignData/VerifyData的哈希和填充方式
+func (c RSACipher) WithHash(hash crypto.Hash, usePSS bool) RSACipher {
+	c.hash, c.usePSS = hash, usePSS
+	return c
+}
+
 // 解密pem格式的公钥/私钥
 func (c RSACipher) GetBlock(key []byte, errmsg string) (*pem.Block, error) {
 	var err error
@@ -107,30 +123,34 @@ func (c RSACipher) GetBlock(key []byte, errmsg string) (*pem.Block, error) {
 }
 
 func (c RSACipher) GetPublicKey() (*rsa.PublicKey, error) {
-	block, err := c.GetBlock(c.pubKey, "public key error !")
-	if err != nil {
-		return nil, err
+	if c.pub != nil {
+		return c.pub, nil
 	}
 	// 解析公钥
-	face, err := x509.ParsePKIXPublicKey(block.Bytes)
+	face, err := ParsePublicKeyPEM(c.pubKey)
 	if err != nil {
 		return nil, err
 	}
 	// 类型断言
-	return face.(*rsa.PublicKey), nil
+	if key, ok := face.(*rsa.PublicKey); ok {
+		return key, nil
+	}
+	return nil, errors.New("public key is not RSA")
 }
 
 func (c RSACipher) GetPrivateKey() (*rsa.PrivateKey, error) {
-	block, err := c.GetBlock(c.privKey, "private key error!")
-	if err != nil {
-		return nil, err
+	if c.priv != nil {
+		return c.priv, nil
 	}
-	// 解析PKCS1格式的私钥
-	key, err := x509.ParsePKCS1PrivateKey(block.Bytes)
+	// 解析PKCS1或PKCS8格式的私钥
+	face, err := ParsePrivateKeyPEM(c.privKey)
 	if err != nil {
 		return nil, err
 	}
-	return key, nil
+	if key, ok := face.(*rsa.PrivateKey); ok {
+		return key, nil
+	}
+	return nil, errors.New("private key is not RSA")
 }
 
 // 加密
@@ -157,8 +177,11 @@ func (c RSACipher) Sign(hash crypto.Hash, msg []byte) ([]byte, error) {
 	if err != nil {
 		return nil, err
 	}
-	hashed := sha256.Sum256(msg)
-	return rsa.SignPKCS1v15(rand.Reader, key, hash, hashed[:])
+	hashed, err := HashSum(hash, msg)
+	if err != nil {
+		return nil, err
+	}
+	return rsa.SignPKCS1v15(rand.Reader, key, hash, hashed)
 }
 
 // 校验
@@ -167,6 +190,73 @@ func (c RSACipher) Verify(hash crypto.Hash, msg, sig []byte) error {
 	if err != nil {
 		return err
 	}
-	hashed := sha256.Sum256(msg)
-	return rsa.VerifyPKCS1v15(key, hash, hashed[:], sig)
+	hashed, err := HashSum(hash, msg)
+	if err != nil {
+		return err
+	}
+	return rsa.VerifyPKCS1v15(key, hash, hashed, sig)
+}
+
+// PSS签名
+func (c RSACipher) SignPSS(hash crypto.Hash, msg []byte) ([]byte, error) {
+	key, err := c.GetPrivateKey()
+	if err != nil {
+		return nil, err
+	}
+	hashed, err := HashSum(hash, msg)
+	if err != nil {
+		return nil, err
+	}
+	opts := &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash}
+	return rsa.SignPSS(rand.Reader, key, hash, hashed, opts)
+}
+
+// PSS校验
+func (c RSACipher) VerifyPSS(hash crypto.Hash, msg, sig []byte) error {
+	key, err := c.GetPublicKey()
+	if err != nil {
+		return err
+	}
+	hashed, err := HashSum(hash, msg)
+	if err != nil {
+		return err
+	}
+	opts := &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthAuto}
+	return rsa.VerifyPSS(key, hash, hashed, sig, opts)
+}
+
+func (c RSACipher) getHash() crypto.Hash {
+	if c.hash == 0 {
+		return crypto.SHA256
+	}
+	return c.hash
+}
+
+// 算法名称，采用JWA的写法，如RS256、PS256
+func (c RSACipher) Algorithm() string {
+	prefix := "RS"
+	if c.usePSS {
+		prefix = "PS"
+	}
+	return prefix + hashBits(c.getHash())
+}
+
+func (c RSACipher) SignData(msg []byte) ([]byte, error) {
+	if c.usePSS {
+		return c.SignPSS(c.getHash(), msg)
+	}
+	return c.Sign(c.getHash(), msg)
+}
+
+func (c RSACipher) VerifyData(msg, sig []byte) error {
+	var err error
+	if c.usePSS {
+		err = c.VerifyPSS(c.getHash(), msg, sig)
+	} else {
+		err = c.Verify(c.getHash(), msg, sig)
+	}
+	if err != nil {
+		return VerifyFailedError
+	}
+	return nil
 }
@@ -0,0 +1,157 @@
+package cryptogy
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/sha512"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+var (
+	VerifyFailedError = errors.New("signature verification failed")
+	UnknownAlgError   = errors.New("unknown or unsupported algorithm")
+)
+
+// 签名接口，算法名称采用JWA的写法，如HS256、RS256、PS256、ES256、EdDSA
+type Signer interface {
+	Algorithm() string
+	SignData(msg []byte) ([]byte, error)
+}
+
+// 校验接口，签名不符时返回VerifyFailedError
+type Verifier interface {
+	Algorithm() string
+	VerifyData(msg, sig []byte) error
+}
+
+// 同时可以签名和校验
+type SignVerifier interface {
+	Signer
+	Verifier
+}
+
+// 计算摘要
+func HashSum(hash crypto.Hash, msg []byte) ([]byte, error) {
+	if !hash.Available() {
+		return nil, fmt.Errorf("hash function #%d is unavailable", hash)
+	}
+	h := hash.New()
+	h.Write(msg)
+	return h.Sum(nil), nil
+}
+
+// 摘要位数，SHA256返回"256"
+func hashBits(hash crypto.Hash) string {
+	return strconv.Itoa(hash.Size() * 8)
+}
+
+// 根据算法名称取得哈希，如RS384返回SHA384
+func algHash(alg string) crypto.Hash {
+	switch {
+	case strings.HasSuffix(alg, "256"):
+		return crypto.SHA256
+	case strings.HasSuffix(alg, "384"):
+		return crypto.SHA384
+	case strings.HasSuffix(alg, "512"):
+		return crypto.SHA512
+	}
+	return 0
+}
+
+// 根据算法名称和密钥创建签名器，用于通过配置切换算法
+// HS系列的key为[]byte或string，其他为对应的私钥，可用ParsePrivateKeyPEM或JWK.Key()得到
+func NewSigner(alg string, key interface{}) (SignVerifier, error) {
+	if len(alg) < 2 {
+		return nil, UnknownAlgError
+	}
+	hash := algHash(alg)
+	switch prefix := alg[:2]; {
+	case prefix == "HS" && hash != 0:
+		return newMacHashKey(hash, key)
+	case (prefix == "RS" || prefix == "PS") && hash != 0:
+		if priv, ok := key.(*rsa.PrivateKey); ok {
+			return NewRSACipherKey(priv, nil).WithHash(hash, prefix == "PS"), nil
+		}
+	case prefix == "ES" && hash != 0:
+		if priv, ok := key.(*ecdsa.PrivateKey); ok {
+			c := NewECDSACipherKey(priv, nil)
+			return c, checkAlg(alg, c.Algorithm())
+		}
+	case alg == "EdDSA":
+		if priv, ok := key.(ed25519.PrivateKey); ok {
+			return NewEd25519CipherKey(priv, nil), nil
+		}
+	default:
+		return nil, UnknownAlgError
+	}
+	return nil, fmt.Errorf("key type %T does not match algorithm %s", key, alg)
+}
+
+// 根据算法名称和公钥创建校验器，也可传入私钥
+func NewVerifier(alg string, key interface{}) (Verifier, error) {
+	if len(alg) < 2 {
+		return nil, UnknownAlgError
+	}
+	hash := algHash(alg)
+	switch prefix := alg[:2]; {
+	case prefix == "HS" && hash != 0:
+		return newMacHashKey(hash, key)
+	case (prefix == "RS" || prefix == "PS") && hash != 0:
+		if priv, ok := key.(*rsa.PrivateKey); ok {
+			key = &priv.PublicKey
+		}
+		if pub, ok := key.(*rsa.PublicKey); ok {
+			return NewRSACipherKey(nil, pub).WithHash(hash, prefix == "PS"), nil
+		}
+	case prefix == "ES" && hash != 0:
+		if priv, ok := key.(*ecdsa.PrivateKey); ok {
+			key = &priv.PublicKey
+		}
+		if pub, ok := key.(*ecdsa.PublicKey); ok {
+			c := NewECDSACipherKey(nil, pub)
+			return c, checkAlg(alg, c.Algorithm())
+		}
+	case alg == "EdDSA":
+		if priv, ok := key.(ed25519.PrivateKey); ok {
+			key = priv.Public()
+		}
+		if pub, ok := key.(ed25519.PublicKey); ok {
+			return NewEd25519CipherKey(nil, pub), nil
+		}
+	default:
+		return nil, UnknownAlgError
+	}
+	return nil, fmt.Errorf("key type %T does not match algorithm %s", key, alg)
+}
+
+func checkAlg(want, got string) error {
+	if want != got {
+		return fmt.Errorf("algorithm %s does not match key (%s)", want, got)
+	}
+	return nil
+}
+
+func newMacHashKey(hash crypto.Hash, key interface{}) (*MacHash, error) {
+	var creator NewHashFunc
+	switch hash {
+	case crypto.SHA256:
+		creator = sha256.New
+	case crypto.SHA384:
+		creator = sha512.New384
+	default:
+		creator = sha512.New
+	}
+	switch k := key.(type) {
+	case []byte:
+		return NewMacHash(creator).SetKey(string(k)), nil
+	case string:
+		return NewMacHash(creator).SetKey(k), nil
+	}
+	return nil, fmt.Errorf("key type %T is not a HMAC secret", key)
+}
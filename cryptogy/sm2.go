@@ -0,0 +1,338 @@
+package cryptogy
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/asn1"
+	"encoding/binary"
+	"errors"
+	"io"
+	"math/big"
+	"sync"
+)
+
+// SM2椭圆曲线公钥密码算法 GB/T 32918-2016，使用推荐曲线sm2p256v1
+// 密钥沿用ecdsa.PrivateKey/PublicKey，其中Curve为P256Sm2()
+var (
+	sm2Once         sync.Once
+	sm2Params       *elliptic.CurveParams
+	sm2DefUID       = []byte("1234567812345678") // 默认用户标识
+	sm2DecryptError = errors.New("sm2: decryption error")
+)
+
+// 推荐曲线，a = p - 3，可以直接使用elliptic.CurveParams的通用实现
+func P256Sm2() elliptic.Curve {
+	sm2Once.Do(func() {
+		sm2Params = &elliptic.CurveParams{Name: "SM2-P-256", BitSize: 256}
+		sm2Params.P, _ = new(big.Int).SetString("FFFFFFFEFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFF00000000FFFFFFFFFFFFFFFF", 16)
+		sm2Params.N, _ = new(big.Int).SetString("FFFFFFFEFFFFFFFFFFFFFFFFFFFFFFFF7203DF6B21C6052B53BBF40939D54123", 16)
+		sm2Params.B, _ = new(big.Int).SetString("28E9FA9E9D9F5E344D5A9E4BCF6509A7F39789F515AB8F92DDBCBD414D940E93", 16)
+		sm2Params.Gx, _ = new(big.Int).SetString("32C4AE2C1F1981195F9904466A39C9948FE30BBFF2660BE1715A4589334C74C7", 16)
+		sm2Params.Gy, _ = new(big.Int).SetString("BC3736A2F4F6779C59BDCEE36B692153D0A9877CC62A474002DF32E52139F0A0", 16)
+	})
+	return sm2Params
+}
+
+// 产生SM2密钥
+func GenerateSM2Key(random io.Reader) (*ecdsa.PrivateKey, error) {
+	d, err := sm2RandInt(random)
+	if err != nil {
+		return nil, err
+	}
+	return NewSM2PrivateKey(d.Bytes())
+}
+
+// 根据私钥数值创建SM2密钥，d取值范围[1, n-2]
+func NewSM2PrivateKey(d []byte) (*ecdsa.PrivateKey, error) {
+	curve := P256Sm2()
+	k := new(big.Int).SetBytes(d)
+	max := new(big.Int).Sub(curve.Params().N, big.NewInt(1))
+	if k.Sign() <= 0 || k.Cmp(max) >= 0 {
+		return nil, errors.New("sm2: invalid private key")
+	}
+	priv := &ecdsa.PrivateKey{D: k}
+	priv.Curve = curve
+	priv.X, priv.Y = curve.ScalarBaseMult(k.Bytes())
+	return priv, nil
+}
+
+// 随机数k，取值范围[1, n-1]
+func sm2RandInt(random io.Reader) (*big.Int, error) {
+	n := P256Sm2().Params().N
+	max := new(big.Int).Sub(n, big.NewInt(1))
+	k, err := rand.Int(random, max)
+	if err != nil {
+		return nil, err
+	}
+	return k.Add(k, big.NewInt(1)), nil
+}
+
+// SM2签名和加密，签名为ASN.1格式
+type SM2Cipher struct {
+	priv *ecdsa.PrivateKey
+	pub  *ecdsa.PublicKey
+	UID  []byte // 用户标识，为空时使用默认值1234567812345678
+}
+
+// 其中一个可以为nil
+func NewSM2Cipher(priv *ecdsa.PrivateKey, pub *ecdsa.PublicKey) SM2Cipher {
+	if pub == nil && priv != nil {
+		pub = &priv.PublicKey
+	}
+	return SM2Cipher{priv: priv, pub: pub}
+}
+
+func (c SM2Cipher) GetPublicKey() (*ecdsa.PublicKey, error) {
+	if c.pub == nil {
+		return nil, errors.New("public key error !")
+	}
+	return c.pub, nil
+}
+
+func (c SM2Cipher) GetPrivateKey() (*ecdsa.PrivateKey, error) {
+	if c.priv == nil {
+		return nil, errors.New("private key error!")
+	}
+	return c.priv, nil
+}
+
+// 用户身份杂凑值 ZA = SM3(ENTLA || ID || a || b || xG || yG || xA || yA)
+func (c SM2Cipher) ZA(pub *ecdsa.PublicKey) []byte {
+	uid := c.UID
+	if len(uid) == 0 {
+		uid = sm2DefUID
+	}
+	params := P256Sm2().Params()
+	a := new(big.Int).Sub(params.P, big.NewInt(3))
+	d := NewSM3()
+	d.Write([]byte{byte(len(uid) >> 5), byte(len(uid) << 3)})
+	d.Write(uid)
+	for _, n := range []*big.Int{a, params.B, params.Gx, params.Gy, pub.X, pub.Y} {
+		d.Write(sm2Bytes(n))
+	}
+	return d.Sum(nil)
+}
+
+// 定长32字节大端编码
+func sm2Bytes(n *big.Int) []byte {
+	return n.FillBytes(make([]byte, 32))
+}
+
+func (c SM2Cipher) digest(pub *ecdsa.PublicKey, msg []byte) *big.Int {
+	d := NewSM3()
+	d.Write(c.ZA(pub))
+	d.Write(msg)
+	return new(big.Int).SetBytes(d.Sum(nil))
+}
+
+// 签名，返回r和s
+func (c SM2Cipher) SignRS(random io.Reader, msg []byte) (r, s *big.Int, err error) {
+	priv, err := c.GetPrivateKey()
+	if err != nil {
+		return nil, nil, err
+	}
+	e := c.digest(&priv.PublicKey, msg)
+	for {
+		var k *big.Int
+		if k, err = sm2RandInt(random); err != nil {
+			return nil, nil, err
+		}
+		if r, s = sm2SignWithK(priv, e, k); r != nil {
+			return r, s, nil
+		}
+	}
+}
+
+// 使用指定的k签名，不合格时返回nil
+func sm2SignWithK(priv *ecdsa.PrivateKey, e, k *big.Int) (r, s *big.Int) {
+	curve := P256Sm2()
+	n := curve.Params().N
+	x1, _ := curve.ScalarBaseMult(k.Bytes())
+	r = new(big.Int).Add(e, x1)
+	r.Mod(r, n)
+	if r.Sign() == 0 || new(big.Int).Add(r, k).Cmp(n) == 0 {
+		return nil, nil
+	}
+	// s = (1 + d)^-1 * (k - r*d) mod n
+	inv := new(big.Int).Add(priv.D, big.NewInt(1))
+	inv.ModInverse(inv, n)
+	s = new(big.Int).Mul(r, priv.D)
+	s.Sub(k, s)
+	s.Mul(s, inv)
+	s.Mod(s, n)
+	if s.Sign() == 0 {
+		return nil, nil
+	}
+	return r, s
+}
+
+// 校验r和s
+func (c SM2Cipher) VerifyRS(msg []byte, r, s *big.Int) bool {
+	pub, err := c.GetPublicKey()
+	if err != nil {
+		return false
+	}
+	curve := P256Sm2()
+	n := curve.Params().N
+	one := big.NewInt(1)
+	if r.Cmp(one) < 0 || s.Cmp(one) < 0 || r.Cmp(n) >= 0 || s.Cmp(n) >= 0 {
+		return false
+	}
+	t := new(big.Int).Add(r, s)
+	t.Mod(t, n)
+	if t.Sign() == 0 {
+		return false
+	}
+	x1, y1 := curve.ScalarBaseMult(s.Bytes())
+	x2, y2 := curve.ScalarMult(pub.X, pub.Y, t.Bytes())
+	x, _ := curve.Add(x1, y1, x2, y2)
+	e := c.digest(pub, msg)
+	x.Add(x, e)
+	x.Mod(x, n)
+	return x.Cmp(r) == 0
+}
+
+// 算法名称
+func (c SM2Cipher) Algorithm() string {
+	return "SM2"
+}
+
+// 签名，ASN.1格式
+func (c SM2Cipher) Sign(msg []byte) ([]byte, error) {
+	r, s, err := c.SignRS(rand.Reader, msg)
+	if err != nil {
+		return nil, err
+	}
+	return asn1.Marshal(ecdsaSignature{r, s})
+}
+
+// 校验，ASN.1格式
+func (c SM2Cipher) Verify(msg, sig []byte) error {
+	var es ecdsaSignature
+	if rest, err := asn1.Unmarshal(sig, &es); err != nil || len(rest) > 0 {
+		return VerifyFailedError
+	}
+	if !c.VerifyRS(msg, es.R, es.S) {
+		return VerifyFailedError
+	}
+	return nil
+}
+
+func (c SM2Cipher) SignData(msg []byte) ([]byte, error) {
+	return c.Sign(msg)
+}
+
+func (c SM2Cipher) VerifyData(msg, sig []byte) error {
+	return c.Verify(msg, sig)
+}
+
+// 密钥派生函数
+func sm2KDF(z []byte, size int) []byte {
+	var (
+		out []byte
+		ct  [4]byte
+	)
+	for i := uint32(1); len(out) < size; i++ {
+		binary.BigEndian.PutUint32(ct[:], i)
+		d := NewSM3()
+		d.Write(z)
+		d.Write(ct[:])
+		out = d.Sum(out)
+	}
+	return out[:size]
+}
+
+// 加密，密文格式为 C1 || C3 || C2，其中C1为非压缩点 04 || x || y
+func (c SM2Cipher) Encrypt(origData []byte) ([]byte, error) {
+	return c.EncryptRand(rand.Reader, origData)
+}
+
+func (c SM2Cipher) EncryptRand(random io.Reader, origData []byte) ([]byte, error) {
+	pub, err := c.GetPublicKey()
+	if err != nil {
+		return nil, err
+	}
+	curve := P256Sm2()
+	for {
+		k, err := sm2RandInt(random)
+		if err != nil {
+			return nil, err
+		}
+		if cipherText := sm2EncryptWithK(curve, pub, k, origData); cipherText != nil {
+			return cipherText, nil
+		}
+	}
+}
+
+// 使用指定的k加密，t全为0时返回nil
+func sm2EncryptWithK(curve elliptic.Curve, pub *ecdsa.PublicKey, k *big.Int, msg []byte) []byte {
+	x1, y1 := curve.ScalarBaseMult(k.Bytes())
+	x2, y2 := curve.ScalarMult(pub.X, pub.Y, k.Bytes())
+	xy := append(sm2Bytes(x2), sm2Bytes(y2)...)
+	t := sm2KDF(xy, len(msg))
+	if len(msg) > 0 && isAllZero(t) {
+		return nil
+	}
+	c2 := make([]byte, len(msg))
+	xorBytes(c2, msg, t)
+	d := NewSM3()
+	d.Write(xy[:32])
+	d.Write(msg)
+	d.Write(xy[32:])
+	result := append([]byte{4}, sm2Bytes(x1)...)
+	result = append(result, sm2Bytes(y1)...)
+	result = d.Sum(result)
+	return append(result, c2...)
+}
+
+// 解密
+func (c SM2Cipher) Decrypt(cipherText []byte) ([]byte, error) {
+	priv, err := c.GetPrivateKey()
+	if err != nil {
+		return nil, err
+	}
+	if len(cipherText) < 97 || cipherText[0] != 4 {
+		return nil, sm2DecryptError
+	}
+	curve := P256Sm2()
+	x1 := new(big.Int).SetBytes(cipherText[1:33])
+	y1 := new(big.Int).SetBytes(cipherText[33:65])
+	if !curve.IsOnCurve(x1, y1) {
+		return nil, sm2DecryptError
+	}
+	c3, c2 := cipherText[65:97], cipherText[97:]
+	x2, y2 := curve.ScalarMult(x1, y1, priv.D.Bytes())
+	xy := append(sm2Bytes(x2), sm2Bytes(y2)...)
+	t := sm2KDF(xy, len(c2))
+	if len(c2) > 0 && isAllZero(t) {
+		return nil, sm2DecryptError
+	}
+	msg := make([]byte, len(c2))
+	xorBytes(msg, c2, t)
+	d := NewSM3()
+	d.Write(xy[:32])
+	d.Write(msg)
+	d.Write(xy[32:])
+	if subtle.ConstantTimeCompare(d.Sum(nil), c3) != 1 {
+		return nil, sm2DecryptError
+	}
+	return msg, nil
+}
+
+// dst = a XOR b，三者长度相同
+func xorBytes(dst, a, b []byte) {
+	for i := range dst {
+		dst[i] = a[i] ^ b[i]
+	}
+}
+
+func isAllZero(data []byte) bool {
+	for _, b := range data {
+		if b != 0 {
+			return false
+		}
+	}
+	return true
+}
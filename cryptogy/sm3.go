@@ -0,0 +1,141 @@
+package cryptogy
+
+import (
+	"encoding/binary"
+	"hash"
+	"math/bits"
+)
+
+// SM3密码杂凑算法 GB/T 32905-2016
+// 可用于hmac，例如 NewMacHash(NewSM3).SetKey("nonce")
+const (
+	SM3Size      = 32
+	SM3BlockSize = 64
+)
+
+var sm3IV = [8]uint32{
+	0x7380166f, 0x4914b2b9, 0x172442d7, 0xda8a0600,
+	0xa96f30bc, 0x163138aa, 0xe38dee4d, 0xb0fb0e4e,
+}
+
+type SM3Digest struct {
+	h   [8]uint32
+	buf [SM3BlockSize]byte
+	nx  int
+	len uint64
+}
+
+func NewSM3() hash.Hash {
+	d := new(SM3Digest)
+	d.Reset()
+	return d
+}
+
+// 计算SM3摘要
+func SM3Sum(data []byte) [SM3Size]byte {
+	var sum [SM3Size]byte
+	d := NewSM3()
+	d.Write(data)
+	d.Sum(sum[:0])
+	return sum
+}
+
+func (d *SM3Digest) Reset() {
+	d.h = sm3IV
+	d.nx, d.len = 0, 0
+}
+
+func (d *SM3Digest) Size() int {
+	return SM3Size
+}
+
+func (d *SM3Digest) BlockSize() int {
+	return SM3BlockSize
+}
+
+func (d *SM3Digest) Write(p []byte) (int, error) {
+	n := len(p)
+	d.len += uint64(n)
+	if d.nx > 0 {
+		c := copy(d.buf[d.nx:], p)
+		d.nx += c
+		if d.nx == SM3BlockSize {
+			d.block(d.buf[:])
+			d.nx = 0
+		}
+		p = p[c:]
+	}
+	for len(p) >= SM3BlockSize {
+		d.block(p[:SM3BlockSize])
+		p = p[SM3BlockSize:]
+	}
+	if len(p) > 0 {
+		d.nx = copy(d.buf[:], p)
+	}
+	return n, nil
+}
+
+func (d *SM3Digest) Sum(in []byte) []byte {
+	c := *d // 复制一份，不影响继续写入
+	length := c.len << 3
+	var tmp [SM3BlockSize + 8]byte
+	tmp[0] = 0x80
+	pad := 56 - int(c.len%64)
+	if pad <= 0 {
+		pad += 64
+	}
+	binary.BigEndian.PutUint64(tmp[pad:], length)
+	c.Write(tmp[:pad+8])
+	var out [SM3Size]byte
+	for i, v := range c.h {
+		binary.BigEndian.PutUint32(out[i*4:], v)
+	}
+	return append(in, out[:]...)
+}
+
+func sm3P0(x uint32) uint32 {
+	return x ^ bits.RotateLeft32(x, 9) ^ bits.RotateLeft32(x, 17)
+}
+
+func sm3P1(x uint32) uint32 {
+	return x ^ bits.RotateLeft32(x, 15) ^ bits.RotateLeft32(x, 23)
+}
+
+// 压缩函数
+func (d *SM3Digest) block(p []byte) {
+	var w [68]uint32
+	for i := 0; i < 16; i++ {
+		w[i] = binary.BigEndian.Uint32(p[i*4:])
+	}
+	for i := 16; i < 68; i++ {
+		w[i] = sm3P1(w[i-16]^w[i-9]^bits.RotateLeft32(w[i-3], 15)) ^
+			bits.RotateLeft32(w[i-13], 7) ^ w[i-6]
+	}
+	a, b, c, e := d.h[0], d.h[1], d.h[2], d.h[4]
+	dd, f, g, h := d.h[3], d.h[5], d.h[6], d.h[7]
+	for j := 0; j < 64; j++ {
+		var t, ff, gg uint32
+		if j < 16 {
+			t = 0x79cc4519
+			ff, gg = a^b^c, e^f^g
+		} else {
+			t = 0x7a879d8a
+			ff = (a & b) | (a & c) | (b & c)
+			gg = (e & f) | (^e & g)
+		}
+		ss1 := bits.RotateLeft32(bits.RotateLeft32(a, 12)+e+bits.RotateLeft32(t, j%32), 7)
+		ss2 := ss1 ^ bits.RotateLeft32(a, 12)
+		tt1 := ff + dd + ss2 + (w[j] ^ w[j+4])
+		tt2 := gg + h + ss1 + w[j]
+		dd, c, b, a = c, bits.RotateLeft32(b, 9), a, tt1
+		h, g, f, e = g, bits.RotateLeft32(f, 19), e, sm3P0(tt2)
+	}
+	d.h[0] ^= a
+	d.h[1] ^= b
+	d.h[2] ^= c
+	d.h[3] ^= dd
+	d.h[4] ^= e
+	d.h[5] ^= f
+	d.h[6] ^= g
+	d.h[7] ^= h
+}
@@ -0,0 +1,128 @@
+package cryptogy
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func hexBytes(s string) []byte {
+	data, _ := hex.DecodeString(strings.ReplaceAll(s, " ", ""))
+	return data
+}
+
+func hexInt(s string) *big.Int {
+	n, _ := new(big.Int).SetString(strings.ReplaceAll(s, " ", ""), 16)
+	return n
+}
+
+// GB/T 32905-2016 附录A
+func TestSM3Vectors(t *testing.T) {
+	sum := SM3Sum([]byte("abc"))
+	assert.Equal(t, hexBytes("66c7f0f4 62eeedd9 d1f2d46b dc10e4e2 4167c487 5cf2f7a2 297da02b 8f4ba8e0"), sum[:])
+	sum = SM3Sum([]byte(strings.Repeat("abcd", 16)))
+	assert.Equal(t, hexBytes("debe9ff9 2275b8a1 38604889 c18e5a4d 6fdb70e5 387e5765 293dcba3 9c0c5732"), sum[:])
+	// 分多次写入结果相同
+	d := NewSM3()
+	d.Write([]byte(strings.Repeat("abcd", 10)))
+	d.Write([]byte(strings.Repeat("abcd", 6)))
+	assert.Equal(t, sum[:], d.Sum(nil))
+
+	h := NewMacHash(NewSM3).SetKey("nonce")
+	assert.Equal(t, "HMAC-SM3", h.Algorithm())
+	signed := h.Sign(origDatas[2])
+	assert.True(t, h.Verify(origDatas[2], signed))
+}
+
+// GB/T 32907-2016 附录A
+func TestSM4Vectors(t *testing.T) {
+	key := hexBytes("01 23 45 67 89 ab cd ef fe dc ba 98 76 54 32 10")
+	block, err := NewSM4Block(key)
+	assert.NoError(t, err)
+	out := make([]byte, SM4BlockSize)
+	block.Encrypt(out, key)
+	assert.Equal(t, hexBytes("68 1e df 34 d2 06 96 5e 86 b3 e9 4f 53 6e 42 46"), out)
+	block.Decrypt(out, out)
+	assert.Equal(t, key, out)
+	copy(out, key)
+	for i := 0; i < 1000000; i++ {
+		block.Encrypt(out, out)
+	}
+	assert.Equal(t, hexBytes("59 52 98 c7 c6 fd 27 1f 04 02 f8 04 c3 3d 3f 66"), out)
+}
+
+func TestSM4Modes(t *testing.T) {
+	key := []byte("1234567890abcdef")
+	for _, mode := range []string{"CBC", "CFB", "CTR", "OFB"} {
+		c, err := NewSM4Cipher(mode, key)
+		assert.NoError(t, err)
+		if mode == "CBC" {
+			c.SetPaddingFunc("PKCS7")
+		}
+		for _, data := range origDatas {
+			secret, err := c.Encrypt([]byte(data))
+			assert.NoError(t, err)
+			plain, err := c.Decrypt(secret)
+			assert.NoError(t, err)
+			assert.Equal(t, data, string(plain), mode)
+		}
+	}
+	_, err := NewSM4Cipher("CBC", key[:8])
+	assert.Error(t, err)
+}
+
+// GB/T 32918.5-2017 示例
+func TestSM2Vectors(t *testing.T) {
+	priv, err := NewSM2PrivateKey(hexBytes("3945208F 7B2144B1 3F36E38A C6D39F95 88939369 2860B51A 42FB81EF 4DF7C5B8"))
+	assert.NoError(t, err)
+	assert.Equal(t, hexInt("09F9DF31 1E5421A1 50DD7D16 1E4BC5C6 72179FAD 1833FC07 6BB08FF3 56F35020"), priv.X)
+	assert.Equal(t, hexInt("CCEA490C E26775A5 2DC6EA71 8CC1AA60 0AED05FB F35E084A 6632F607 2DA9AD13"), priv.Y)
+	c := NewSM2Cipher(priv, nil)
+	k := hexInt("59276E27 D506861A 16680F3A D9C02DCC EF3CC1FA 3CDBE4CE 6D54B80D EAC1BC21")
+
+	msg := []byte("message digest")
+	r, s := sm2SignWithK(priv, c.digest(&priv.PublicKey, msg), k)
+	assert.Equal(t, hexInt("F5A03B06 48D2C463 0EEAC513 E1BB81A1 5944DA38 27D5B741 43AC7EAC EEE720B3"), r)
+	assert.Equal(t, hexInt("B1B6AA29 DF212FD8 763182BC 0D421CA1 BB9038FD 1F7F42D4 840B69C4 85BBC1AA"), s)
+	assert.True(t, c.VerifyRS(msg, r, s))
+	assert.False(t, c.VerifyRS([]byte("message digesT"), r, s))
+
+	msg = []byte("encryption standard")
+	cipherText := sm2EncryptWithK(P256Sm2(), &priv.PublicKey, k, msg)
+	assert.Equal(t, hexBytes("04"+
+		"04EBFC71 8E8D1798 62043226 8E77FEB6 415E2EDE 0E073C0F 4F640ECD 2E149A73"+
+		"E858F9D8 1E5430A5 7B36DAAB 8F950A3C 64E6EE6A 63094D99 283AFF76 7E124DF0"+
+		"59983C18 F809E262 923C53AE C295D303 83B54E39 D609D160 AFCB1908 D0BD8766"+
+		"21886CA9 89CA9C7D 58087307 CA93092D 651EFA"), cipherText)
+	plain, err := c.Decrypt(cipherText)
+	assert.NoError(t, err)
+	assert.Equal(t, msg, plain)
+}
+
+func TestSM2Cipher(t *testing.T) {
+	priv, err := GenerateSM2Key(rand.Reader)
+	assert.NoError(t, err)
+	c := NewSM2Cipher(priv, nil)
+	c.UID = []byte("ALICE123@YAHOO.COM")
+	for _, data := range origDatas {
+		signed, err := c.SignData([]byte(data))
+		assert.NoError(t, err)
+		assert.NoError(t, c.VerifyData([]byte(data), signed))
+		secret, err := c.Encrypt([]byte(data))
+		assert.NoError(t, err)
+		plain, err := c.Decrypt(secret)
+		assert.NoError(t, err)
+		assert.Equal(t, data, string(plain))
+		secret[len(secret)-1] ^= 1
+		_, err = c.Decrypt(secret)
+		assert.Error(t, err)
+	}
+	// 用户标识不同时校验失败
+	signed, _ := c.Sign([]byte(origDatas[1]))
+	other := NewSM2Cipher(nil, &priv.PublicKey)
+	assert.Equal(t, VerifyFailedError, other.Verify([]byte(origDatas[1]), signed))
+}
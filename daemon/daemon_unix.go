@@ -1,12 +1,337 @@
+//go:build android || darwin || dragonfly || freebsd || linux || netbsd || openbsd || solaris
 // +build android darwin dragonfly freebsd linux netbsd openbsd solaris
 
 package daemon
 
-/*
-本文件用作说明unix系统的build tag
-请先将原来的main()改名为run()，新建main_unix.go文件并加入如下代码：
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"syscall"
+	"time"
+)
 
+/*
+如果程序要作为Linux服务启动，需要将原来的main()改名为run()
+新加一个文件main_unix.go，开头加入和本文件一样的build tag，导入本包
+然后参考下面的程序写法：
 func main() {
-	run()
+	name := "my-example-service"
+	desc := "一个样例服务（重要勿删）"
+	daemon.UnixMain(name, desc, run)
 }
+
+命令行用法：
+./my_service          前台运行
+./my_service start    后台运行
+./my_service stop     停止后台进程
+./my_service restart  重启后台进程
+./my_service status   查看状态
+./my_service install  安装为systemd服务（需要root）
+./my_service uninstall 卸载systemd服务
 */
+
+const ENV_DAEMON_CHILD = "GOZZO_DAEMON_CHILD" // 标记后台运行的子进程
+
+var (
+	NotRunningError  = errors.New("daemon is not running")
+	UnsupportedError = errors.New("systemd is only supported on linux")
+)
+
+func UnixMain(name, desc string, run func()) {
+	info := NewConfig(name, desc)
+	prg := &Program{Main: run}
+	var cmd string
+	if len(os.Args) > 1 {
+		cmd = os.Args[1]
+	}
+	if err := RunCommand(info, prg, cmd); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+type Config struct {
+	Name        string
+	DisplayName string
+	Description string
+	Executable  string        // 可执行文件，默认为当前程序
+	Arguments   []string      // 前台运行时的参数
+	WorkDir     string        // 工作目录
+	User        string        // systemd中的运行用户
+	PidFile     string        // 默认为 /var/run/<name>.pid
+	LogFile     string        // 后台运行时stdout和stderr的去向，默认丢弃
+	StopTimeout time.Duration // 优雅停止的最长等待时间
+	NotifyType  bool          // systemd中使用Type=notify
+	WatchdogSec int           // systemd看门狗间隔，需要NotifyType
+	UnitDir     string        // systemd单元文件目录
+	Systemctl   string        // systemctl命令
+}
+
+func NewConfig(name, desc string) *Config {
+	exe, _ := os.Executable()
+	pidDir := "/var/run"
+	if os.Geteuid() != 0 {
+		pidDir = os.TempDir()
+	}
+	return &Config{
+		Name:        name,
+		DisplayName: name,
+		Description: desc,
+		Executable:  exe,
+		WorkDir:     filepath.Dir(exe),
+		PidFile:     filepath.Join(pidDir, name+".pid"),
+		StopTimeout: 10 * time.Second,
+		UnitDir:     "/etc/systemd/system",
+		Systemctl:   "systemctl",
+	}
+}
+
+// 服务程序，Setup完成后通知systemd就绪，Main在协程中运行，返回后进程结束
+// 收到SIGTERM或SIGINT时调用OnStop，收到SIGHUP时调用OnReload
+// 设置了Upgrader时，收到SIGUSR2启动新进程，新进程就绪后调用OnStop
+type Program struct {
+	Setup    func() error
+	Main     func()
+	OnStop   func()
+	OnReload func()
+	Upgrader *Upgrader
+}
+
+// 执行命令，空命令或run为前台运行
+func RunCommand(c *Config, p *Program, cmd string) error {
+	switch cmd {
+	case "", "run":
+		return p.Serve(c)
+	case "start":
+		return c.Start()
+	case "stop":
+		return c.Stop()
+	case "restart":
+		if err := c.Stop(); err != nil && err != NotRunningError {
+			return err
+		}
+		return c.Start()
+	case "status":
+		if pid, ok := NewPidFile(c.PidFile).Running(); ok {
+			fmt.Printf("%s is running, pid %d\n", c.Name, pid)
+		} else {
+			fmt.Printf("%s is stopped\n", c.Name)
+		}
+		return nil
+	case "install":
+		return c.Install()
+	case "uninstall":
+		return c.Uninstall()
+	}
+	return fmt.Errorf("unknown command %q", cmd)
+}
+
+// 前台运行，写入PID文件，处理信号
+func (p *Program) Serve(c *Config) error {
+	pf := NewPidFile(c.PidFile)
+	upgraded := p.Upgrader != nil && p.Upgrader.Inherited()
+	if upgraded { // 接管旧进程的PID文件
+		if err := pf.Write(); err != nil {
+			return err
+		}
+	} else if err := pf.Lock(); err != nil {
+		return err
+	}
+	defer pf.Unlock()
+	signs := make(chan os.Signal, 1)
+	signal.Notify(signs, syscall.SIGTERM, syscall.SIGINT, syscall.SIGHUP)
+	if p.Upgrader != nil {
+		signal.Notify(signs, syscall.SIGUSR2)
+	}
+	defer signal.Stop(signs)
+	if p.Setup != nil {
+		if err := p.Setup(); err != nil {
+			return err
+		}
+	}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		p.Main()
+	}()
+	if p.Upgrader != nil {
+		if err := p.Upgrader.Ready(); err != nil {
+			return err
+		}
+	}
+	if upgraded {
+		SdNotify(fmt.Sprintf("MAINPID=%d\n%s", os.Getpid(), SD_READY))
+	} else {
+		SdNotify(SD_READY)
+	}
+	stopWatchdog := StartWatchdog()
+	defer stopWatchdog()
+	for {
+		select {
+		case <-done:
+			stopWatchdog()
+			SdNotify(SD_STOPPING)
+			return nil
+		case sig := <-signs:
+			if sig == syscall.SIGHUP {
+				SdNotify(SD_RELOADING)
+				if p.OnReload != nil {
+					p.OnReload()
+				}
+				SdNotify(SD_READY)
+				continue
+			}
+			if sig == syscall.SIGUSR2 {
+				if err := p.Upgrader.Upgrade(); err != nil {
+					fmt.Fprintln(os.Stderr, "upgrade failed:", err)
+					continue
+				}
+				stopWatchdog() // 由新进程接管
+			} else {
+				stopWatchdog() // 停止期间由TimeoutStopSec控制
+				SdNotify(SD_STOPPING)
+			}
+			if p.OnStop != nil {
+				p.OnStop()
+			}
+			select { // 等待Main退出
+			case <-done:
+			case <-time.After(c.StopTimeout):
+			}
+			return nil
+		}
+	}
+}
+
+// 后台运行，重新执行当前程序并脱离终端
+func (c *Config) Start() error {
+	if pid, ok := NewPidFile(c.PidFile).Running(); ok {
+		return fmt.Errorf("%s is already running, pid %d", c.Name, pid)
+	}
+	cmd := exec.Command(c.Executable, append([]string{"run"}, c.Arguments...)...)
+	cmd.Dir = c.WorkDir
+	cmd.Env = append(os.Environ(), ENV_DAEMON_CHILD+"=1")
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setsid: true}
+	if c.LogFile != "" {
+		flag := os.O_WRONLY | os.O_CREATE | os.O_APPEND
+		out, err := os.OpenFile(c.LogFile, flag, 0644)
+		if err != nil {
+			return err
+		}
+		defer out.Close()
+		cmd.Stdout, cmd.Stderr = out, out
+	}
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	return cmd.Process.Release()
+}
+
+// 停止后台进程，等待其退出
+func (c *Config) Stop() error {
+	pf := NewPidFile(c.PidFile)
+	pid, ok := pf.Running()
+	if !ok {
+		return NotRunningError
+	}
+	if err := syscall.Kill(pid, syscall.SIGTERM); err != nil {
+		return err
+	}
+	deadline := time.Now().Add(c.StopTimeout + time.Second)
+	for time.Now().Before(deadline) {
+		if _, ok = pf.Running(); !ok {
+			return nil
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	return fmt.Errorf("%s did not stop in %s, pid %d", c.Name, c.StopTimeout, pid)
+}
+
+// 是否后台运行的子进程
+func IsDaemonChild() bool {
+	return os.Getenv(ENV_DAEMON_CHILD) == "1"
+}
+
+// 生成systemd单元文件
+func (c *Config) SystemdUnit() string {
+	var buf strings.Builder
+	buf.WriteString("[Unit]\n")
+	buf.WriteString("Description=" + c.Description + "\n")
+	buf.WriteString("After=network.target\n\n")
+	buf.WriteString("[Service]\n")
+	if c.NotifyType {
+		buf.WriteString("Type=notify\n")
+		buf.WriteString("NotifyAccess=all\n") // 升级后由新进程通知
+		if c.WatchdogSec > 0 {
+			buf.WriteString(fmt.Sprintf("WatchdogSec=%d\n", c.WatchdogSec))
+		}
+	} else {
+		buf.WriteString("Type=simple\n")
+	}
+	args := append([]string{c.Executable, "run"}, c.Arguments...)
+	buf.WriteString("ExecStart=" + strings.Join(args, " ") + "\n")
+	buf.WriteString("ExecReload=/bin/kill -HUP $MAINPID\n")
+	buf.WriteString("KillSignal=SIGTERM\n")
+	buf.WriteString(fmt.Sprintf("TimeoutStopSec=%d\n", int(c.StopTimeout/time.Second)+1))
+	if c.WorkDir != "" {
+		buf.WriteString("WorkingDirectory=" + c.WorkDir + "\n")
+	}
+	if c.User != "" {
+		buf.WriteString("User=" + c.User + "\n")
+	}
+	buf.WriteString("PIDFile=" + c.PidFile + "\n")
+	buf.WriteString("Restart=on-failure\n\n")
+	buf.WriteString("[Install]\n")
+	buf.WriteString("WantedBy=multi-user.target\n")
+	return buf.String()
+}
+
+func (c *Config) UnitPath() string {
+	return filepath.Join(c.UnitDir, c.Name+".service")
+}
+
+func (c *Config) systemctl(args ...string) error {
+	out, err := exec.Command(c.Systemctl, args...).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("%s %s: %s %s", c.Systemctl,
+			strings.Join(args, " "), err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+// 安装为systemd服务并设置开机启动
+func (c *Config) Install() error {
+	if runtime.GOOS != "linux" {
+		return UnsupportedError
+	}
+	err := ioutil.WriteFile(c.UnitPath(), []byte(c.SystemdUnit()), 0644)
+	if err != nil {
+		return err
+	}
+	if err = c.systemctl("daemon-reload"); err != nil {
+		return err
+	}
+	return c.systemctl("enable", c.Name+".service")
+}
+
+// 卸载systemd服务
+func (c *Config) Uninstall() error {
+	if runtime.GOOS != "linux" {
+		return UnsupportedError
+	}
+	_ = c.systemctl("stop", c.Name+".service")
+	if err := c.systemctl("disable", c.Name+".service"); err != nil {
+		return err
+	}
+	if err := os.Remove(c.UnitPath()); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return c.systemctl("daemon-reload")
+}
@@ -0,0 +1,101 @@
+//go:build android || darwin || dragonfly || freebsd || linux || netbsd || openbsd || solaris
+// +build android darwin dragonfly freebsd linux netbsd openbsd solaris
+
+package daemon
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPidFile(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "daemon")
+	defer os.RemoveAll(dir)
+	pf := NewPidFile(filepath.Join(dir, "run", "test.pid"))
+	_, ok := pf.Running()
+	assert.False(t, ok)
+	assert.NoError(t, pf.Lock())
+	pid, ok := pf.Running()
+	assert.True(t, ok)
+	assert.Equal(t, os.Getpid(), pid)
+
+	// 锁被持有时不能再次启动，其他实例也能看到在运行
+	other := NewPidFile(pf.Path)
+	pid, ok = other.Running()
+	assert.True(t, ok)
+	assert.Equal(t, os.Getpid(), pid)
+	assert.Error(t, other.Lock())
+	assert.NoError(t, pf.Unlock())
+	_, err := os.Stat(pf.Path)
+	assert.True(t, os.IsNotExist(err))
+
+	// 没有被锁住的PID文件是过期的，与记录的进程是否存在无关
+	ioutil.WriteFile(pf.Path, []byte("1\n"), 0644)
+	_, ok = pf.Running()
+	assert.False(t, ok)
+	assert.NoError(t, pf.Lock())
+	assert.Equal(t, os.Getpid(), pf.Read())
+
+	// 升级时新进程替换PID文件，旧进程退出时不删除
+	upgraded := NewPidFile(pf.Path)
+	assert.NoError(t, upgraded.Write())
+	assert.NoError(t, pf.Unlock())
+	_, ok = other.Running()
+	assert.True(t, ok)
+	assert.NoError(t, upgraded.Unlock())
+	_, ok = other.Running()
+	assert.False(t, ok)
+}
+
+func TestServeSignals(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "daemon")
+	defer os.RemoveAll(dir)
+	c := NewConfig("gozzo-test", "测试服务")
+	c.PidFile = filepath.Join(dir, "test.pid")
+	quit := make(chan bool)
+	reloads := 0
+	p := &Program{
+		Main:     func() { <-quit },
+		OnStop:   func() { close(quit) },
+		OnReload: func() { reloads++ },
+	}
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		syscall.Kill(os.Getpid(), syscall.SIGHUP)
+		time.Sleep(100 * time.Millisecond)
+		syscall.Kill(os.Getpid(), syscall.SIGTERM)
+	}()
+	assert.NoError(t, p.Serve(c))
+	assert.Equal(t, 1, reloads)
+	_, ok := NewPidFile(c.PidFile).Running()
+	assert.False(t, ok)
+}
+
+func TestSystemdUnit(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "daemon")
+	defer os.RemoveAll(dir)
+	c := NewConfig("gozzo-test", "测试服务")
+	c.Executable, c.Arguments = "/usr/local/bin/gozzo", []string{"-c", "app.yml"}
+	c.User, c.UnitDir, c.Systemctl = "nobody", dir, "true"
+	unit := c.SystemdUnit()
+	assert.Contains(t, unit, "Description=测试服务\n")
+	assert.Contains(t, unit, "ExecStart=/usr/local/bin/gozzo run -c app.yml\n")
+	assert.Contains(t, unit, "User=nobody\n")
+	if runtime.GOOS != "linux" {
+		return
+	}
+	assert.NoError(t, c.Install())
+	data, err := ioutil.ReadFile(c.UnitPath())
+	assert.NoError(t, err)
+	assert.Equal(t, unit, string(data))
+	assert.NoError(t, c.Uninstall())
+	_, err = os.Stat(c.UnitPath())
+	assert.True(t, os.IsNotExist(err))
+}
@@ -0,0 +1,134 @@
+//go:build android || darwin || dragonfly || freebsd || linux || netbsd || openbsd || solaris
+// +build android darwin dragonfly freebsd linux netbsd openbsd solaris
+
+package daemon
+
+import (
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// systemd的通知状态
+const (
+	SD_READY     = "READY=1"
+	SD_STOPPING  = "STOPPING=1"
+	SD_RELOADING = "RELOADING=1"
+	SD_WATCHDOG  = "WATCHDOG=1"
+
+	SD_LISTEN_FDS_START = 3 // 套接字激活时第一个文件描述符
+)
+
+// 向systemd发送通知，不在Type=notify下运行时返回false
+func SdNotify(state string) (bool, error) {
+	name := os.Getenv("NOTIFY_SOCKET")
+	if name == "" {
+		return false, nil
+	}
+	if name[0] == '@' { // 抽象命名空间
+		name = "\x00" + name[1:]
+	}
+	addr := &net.UnixAddr{Name: name, Net: "unixgram"}
+	conn, err := net.DialUnix("unixgram", nil, addr)
+	if err != nil {
+		return false, err
+	}
+	defer conn.Close()
+	if _, err = conn.Write([]byte(state)); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// 看门狗的间隔，未启用时返回0
+func WatchdogInterval() time.Duration {
+	usec, err := strconv.ParseInt(os.Getenv("WATCHDOG_USEC"), 10, 64)
+	if err != nil || usec <= 0 {
+		return 0
+	}
+	if pid := os.Getenv("WATCHDOG_PID"); pid != "" {
+		if pid != strconv.Itoa(os.Getpid()) {
+			return 0
+		}
+	}
+	return time.Duration(usec) * time.Microsecond
+}
+
+// 按看门狗间隔的一半定时发送WATCHDOG=1，返回停止函数，停止后不会再发送
+func StartWatchdog() (stop func()) {
+	interval := WatchdogInterval()
+	if interval <= 0 {
+		return func() {}
+	}
+	quit, done := make(chan struct{}), make(chan struct{})
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(interval / 2)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-quit:
+				return
+			case <-ticker.C:
+				SdNotify(SD_WATCHDOG)
+			}
+		}
+	}()
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			close(quit)
+			<-done
+		})
+	}
+}
+
+// 套接字激活传入的文件，名称来自LISTEN_FDNAMES
+func ListenFiles() []*os.File {
+	return listenFilesFrom(SD_LISTEN_FDS_START)
+}
+
+func listenFilesFrom(start int) []*os.File {
+	if os.Getenv("LISTEN_PID") != strconv.Itoa(os.Getpid()) {
+		return nil
+	}
+	count, err := strconv.Atoi(os.Getenv("LISTEN_FDS"))
+	if err != nil || count <= 0 {
+		return nil
+	}
+	names := strings.Split(os.Getenv("LISTEN_FDNAMES"), ":")
+	files := make([]*os.File, 0, count)
+	for fd := start; fd < start+count; fd++ {
+		syscall.CloseOnExec(fd)
+		name := "LISTEN_FD_" + strconv.Itoa(fd)
+		if i := fd - start; i < len(names) && names[i] != "" {
+			name = names[i]
+		}
+		files = append(files, os.NewFile(uintptr(fd), name))
+	}
+	// 避免传给子进程
+	os.Unsetenv("LISTEN_PID")
+	os.Unsetenv("LISTEN_FDS")
+	os.Unsetenv("LISTEN_FDNAMES")
+	return files
+}
+
+// 套接字激活传入的监听器，不是监听套接字的位置为nil
+func Listeners() []net.Listener {
+	return filesToListeners(ListenFiles())
+}
+
+func filesToListeners(files []*os.File) []net.Listener {
+	result := make([]net.Listener, len(files))
+	for i, f := range files {
+		if ln, err := net.FileListener(f); err == nil {
+			result[i] = ln
+			f.Close() // FileListener复制了描述符
+		}
+	}
+	return result
+}
@@ -0,0 +1,98 @@
+//go:build android || darwin || dragonfly || freebsd || linux || netbsd || openbsd || solaris
+// +build android darwin dragonfly freebsd linux netbsd openbsd solaris
+
+package daemon
+
+import (
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// 用本地的unixgram套接字代替systemd
+func fakeSystemd(t *testing.T, dir string) (*net.UnixConn, chan string) {
+	addr := &net.UnixAddr{Name: filepath.Join(dir, "notify.sock"), Net: "unixgram"}
+	conn, err := net.ListenUnixgram("unixgram", addr)
+	assert.NoError(t, err)
+	os.Setenv("NOTIFY_SOCKET", addr.Name)
+	states := make(chan string, 100)
+	go func() {
+		buf := make([]byte, 256)
+		for {
+			n, err := conn.Read(buf)
+			if err != nil {
+				close(states)
+				return
+			}
+			states <- string(buf[:n])
+		}
+	}()
+	return conn, states
+}
+
+func TestSdNotify(t *testing.T) {
+	os.Unsetenv("NOTIFY_SOCKET")
+	sent, err := SdNotify(SD_READY)
+	assert.False(t, sent)
+	assert.NoError(t, err)
+
+	dir, _ := ioutil.TempDir("", "daemon")
+	defer os.RemoveAll(dir)
+	conn, states := fakeSystemd(t, dir)
+	defer os.Unsetenv("NOTIFY_SOCKET")
+	os.Setenv("WATCHDOG_USEC", "40000")
+	os.Setenv("WATCHDOG_PID", strconv.Itoa(os.Getpid()))
+	defer os.Unsetenv("WATCHDOG_USEC")
+	assert.Equal(t, 40*time.Millisecond, WatchdogInterval())
+
+	c := NewConfig("gozzo-test", "测试服务")
+	c.PidFile = filepath.Join(dir, "test.pid")
+	p := &Program{
+		Setup: func() error { return nil },
+		Main:  func() { time.Sleep(200 * time.Millisecond) },
+	}
+	assert.NoError(t, p.Serve(c))
+	time.Sleep(50 * time.Millisecond)
+	conn.Close()
+	var got []string
+	for s := range states {
+		got = append(got, s)
+	}
+	assert.Equal(t, SD_READY, got[0])
+	assert.Equal(t, SD_STOPPING, got[len(got)-1])
+	assert.True(t, len(got) >= 5, "watchdog pings: %v", got)
+	for _, s := range got[1 : len(got)-1] {
+		assert.Equal(t, SD_WATCHDOG, s)
+	}
+	os.Setenv("WATCHDOG_PID", "1")
+	assert.Equal(t, time.Duration(0), WatchdogInterval())
+}
+
+func TestListenFiles(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	defer ln.Close()
+	f, err := ln.(*net.TCPListener).File()
+	assert.NoError(t, err)
+	fd, err := syscall.Dup(int(f.Fd()))
+	assert.NoError(t, err)
+	f.Close()
+	os.Setenv("LISTEN_PID", strconv.Itoa(os.Getpid()))
+	os.Setenv("LISTEN_FDS", "1")
+	os.Setenv("LISTEN_FDNAMES", "http")
+	files := listenFilesFrom(fd)
+	assert.Len(t, files, 1)
+	assert.Equal(t, "http", files[0].Name())
+	assert.Equal(t, "", os.Getenv("LISTEN_FDS"))
+	listeners := filesToListeners(files)
+	assert.Equal(t, ln.Addr().String(), listeners[0].Addr().String())
+	listeners[0].Close()
+	assert.Empty(t, ListenFiles())
+}
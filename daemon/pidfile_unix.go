@@ -0,0 +1,150 @@
+//go:build android || darwin || dragonfly || freebsd || linux || netbsd || openbsd || solaris
+// +build android darwin dragonfly freebsd linux netbsd openbsd solaris
+
+package daemon
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/azhai/gozzo-utils/filesystem"
+)
+
+// PID文件，运行期间一直持有文件锁，进程退出后锁自动释放
+// 以是否被锁住判断进程是否在运行，不受PID重用的影响
+type PidFile struct {
+	Path string
+	lock *filesystem.FileLock
+}
+
+// 等待其他进程短暂探测锁的时间
+const pidLockWait = 200 * time.Millisecond
+
+func NewPidFile(path string) *PidFile {
+	return &PidFile{Path: path}
+}
+
+// 读取PID，文件不存在或内容错误时返回0
+func (f *PidFile) Read() int {
+	data, err := ioutil.ReadFile(f.Path)
+	if err != nil {
+		return 0
+	}
+	pid, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	if err != nil {
+		return 0
+	}
+	return pid
+}
+
+// 记录的进程是否还在运行，即PID文件是否被锁住
+func (f *PidFile) Running() (int, bool) {
+	if f.lock != nil {
+		return f.Read(), true
+	}
+	// 只读打开，没有写权限的用户也能查看状态
+	if locked, _ := filesystem.IsFileLocked(f.Path); !locked {
+		return 0, false
+	}
+	pid := f.Read()
+	return pid, pid > 0
+}
+
+// 锁住PID文件并写入当前进程号，已被其他运行中的进程占用时出错
+func (f *PidFile) Lock() error {
+	if f.lock != nil {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(f.Path), 0755); err != nil {
+		return err
+	}
+	for {
+		lock, err := filesystem.LockFile(f.Path, pidLockWait)
+		if err == filesystem.ErrLockTimeout {
+			return fmt.Errorf("pid file %s is locked by process %d", f.Path, f.Read())
+		} else if err != nil {
+			return err
+		}
+		// 等待期间文件可能被上一个进程删除或替换，锁住的不是当前文件时重试
+		if sameFile(lock.File(), f.Path) {
+			f.lock = lock
+			break
+		}
+		lock.Unlock()
+	}
+	if err := writePid(f.lock.File()); err != nil {
+		f.lock.Unlock()
+		f.lock = nil
+		return err
+	}
+	return nil
+}
+
+// 用新文件替换PID文件并锁住，用于升级时接管旧进程的PID文件
+// 旧进程锁住的是被替换的文件，退出时不会删除新文件
+func (f *PidFile) Write() error {
+	if err := os.MkdirAll(filepath.Dir(f.Path), 0755); err != nil {
+		return err
+	}
+	tmp := fmt.Sprintf("%s.%d.tmp", f.Path, os.Getpid())
+	lock, err := filesystem.TryLockFile(tmp)
+	if err == nil {
+		if err = writePid(lock.File()); err == nil {
+			err = os.Rename(tmp, f.Path)
+		}
+	}
+	if err != nil {
+		if lock != nil {
+			lock.Unlock()
+		}
+		os.Remove(tmp)
+		return err
+	}
+	if f.lock != nil {
+		f.lock.Unlock()
+	}
+	lock.Path, f.lock = f.Path, lock
+	return nil
+}
+
+// 删除并解锁PID文件，文件已被新进程接管时只解锁
+func (f *PidFile) Unlock() error {
+	if f.lock == nil {
+		return nil
+	}
+	var err error
+	if sameFile(f.lock.File(), f.Path) {
+		err = os.Remove(f.Path)
+	}
+	if uerr := f.lock.Unlock(); err == nil {
+		err = uerr
+	}
+	f.lock = nil
+	return err
+}
+
+func writePid(fp *os.File) error {
+	if err := fp.Truncate(0); err != nil {
+		return err
+	}
+	data := []byte(strconv.Itoa(os.Getpid()) + "\n")
+	if _, err := fp.WriteAt(data, 0); err != nil {
+		return err
+	}
+	return fp.Sync()
+}
+
+// 打开的文件与路径是否为同一个文件
+func sameFile(fp *os.File, path string) bool {
+	a, err := fp.Stat()
+	if err != nil {
+		return false
+	}
+	b, err := os.Stat(path)
+	return err == nil && os.SameFile(a, b)
+}
@@ -0,0 +1,260 @@
+//go:build android || darwin || dragonfly || freebsd || linux || netbsd || openbsd || solaris
+// +build android darwin dragonfly freebsd linux netbsd openbsd solaris
+
+package daemon
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/azhai/gozzo-utils/logging"
+	"github.com/azhai/gozzo-utils/metrics"
+)
+
+var TooManyRestartsError = errors.New("too many restarts")
+
+// 监控的计数项
+var SupervisorMetrics = []string{"starts", "restarts", "panics", "failures"}
+
+// 进程监管，运行函数或子进程，出错（panic或非零退出）时按指数退避重启
+// 用法: NewFuncSupervisor("collector", run).Serve()
+type Supervisor struct {
+	Name        string
+	Run         func(quit <-chan struct{}) error // 函数模式，quit关闭时应尽快返回
+	Command     string                           // 子进程模式
+	Args        []string
+	MinBackoff  time.Duration // 首次重启的等待时间
+	MaxBackoff  time.Duration // 最长等待时间
+	MaxRestarts int           // Window时间内最多重启次数，0为不限
+	Window      time.Duration
+	Signals     []os.Signal // 转发给子进程的信号，其中SIGTERM和SIGINT同时停止监管
+	Logger      logging.ILogger
+	Reporter    metrics.Reporter
+	quit        chan struct{}
+	stopOnce    sync.Once
+	mutex       sync.Mutex
+	proc        *os.Process
+	restarts    []time.Time
+}
+
+func newSupervisor(name string) *Supervisor {
+	return &Supervisor{
+		Name:        name,
+		MinBackoff:  time.Second,
+		MaxBackoff:  time.Minute,
+		MaxRestarts: 10,
+		Window:      5 * time.Minute,
+		Signals: []os.Signal{syscall.SIGTERM, syscall.SIGINT,
+			syscall.SIGHUP, syscall.SIGUSR1, syscall.SIGUSR2},
+		Logger:   logging.NewLogger("info", ""),
+		Reporter: metrics.NewDummyReporter(SupervisorMetrics),
+		quit:     make(chan struct{}),
+	}
+}
+
+func NewFuncSupervisor(name string, run func(quit <-chan struct{}) error) *Supervisor {
+	s := newSupervisor(name)
+	s.Run = run
+	return s
+}
+
+func NewProcSupervisor(name, command string, args ...string) *Supervisor {
+	s := newSupervisor(name)
+	s.Command, s.Args = command, args
+	return s
+}
+
+// 作为daemon的Program运行
+func (s *Supervisor) Program() *Program {
+	return &Program{
+		Main: func() {
+			if err := s.Serve(); err != nil {
+				s.Logger.Error(s.Name, " supervisor exit: ", err)
+			}
+		},
+		OnStop: s.Stop,
+	}
+}
+
+// 停止监管，子进程会收到SIGTERM
+func (s *Supervisor) Stop() {
+	s.stopOnce.Do(func() {
+		close(s.quit)
+		s.signal(syscall.SIGTERM)
+	})
+}
+
+func (s *Supervisor) stopped() bool {
+	select {
+	case <-s.quit:
+		return true
+	default:
+		return false
+	}
+}
+
+func (s *Supervisor) signal(sig os.Signal) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.proc != nil {
+		s.proc.Signal(sig)
+	}
+}
+
+// 开始监管，正常结束或调用Stop后返回nil，重启过多时返回TooManyRestartsError
+func (s *Supervisor) Serve() error {
+	if s.Command != "" && len(s.Signals) > 0 {
+		signs := make(chan os.Signal, 1)
+		signal.Notify(signs, s.Signals...)
+		defer signal.Stop(signs)
+		go s.forward(signs)
+	}
+	backoff := s.MinBackoff
+	for !s.stopped() {
+		s.Reporter.IncrCount("starts", 1)
+		begin := time.Now()
+		err := s.runOnce()
+		if err == nil || s.stopped() {
+			return nil
+		}
+		s.Reporter.IncrCount("failures", 1)
+		s.Logger.Warn(s.Name, " failed: ", err)
+		if !s.allowRestart() {
+			return TooManyRestartsError
+		}
+		if time.Since(begin) > s.MaxBackoff { // 运行了足够长时间，重新计算退避
+			backoff = s.MinBackoff
+		}
+		s.Logger.Info(s.Name, " restart after ", backoff)
+		select {
+		case <-s.quit:
+			return nil
+		case <-time.After(backoff):
+		}
+		s.Reporter.IncrCount("restarts", 1)
+		if backoff *= 2; backoff > s.MaxBackoff {
+			backoff = s.MaxBackoff
+		}
+	}
+	return nil
+}
+
+func (s *Supervisor) forward(signs chan os.Signal) {
+	for {
+		select {
+		case <-s.quit:
+			return
+		case sig := <-signs:
+			if sig == syscall.SIGTERM || sig == syscall.SIGINT {
+				s.Stop()
+				return
+			}
+			s.signal(sig)
+		}
+	}
+}
+
+// 窗口期内的重启次数是否超限
+func (s *Supervisor) allowRestart() bool {
+	if s.MaxRestarts <= 0 {
+		return true
+	}
+	now := time.Now()
+	var recent []time.Time
+	for _, t := range s.restarts {
+		if now.Sub(t) < s.Window {
+			recent = append(recent, t)
+		}
+	}
+	s.restarts = append(recent, now)
+	return len(s.restarts) <= s.MaxRestarts
+}
+
+func (s *Supervisor) runOnce() error {
+	if s.Command != "" {
+		return s.runProc()
+	}
+	return s.runFunc()
+}
+
+// 运行函数，将panic转为错误
+func (s *Supervisor) runFunc() (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			s.Reporter.IncrCount("panics", 1)
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	return s.Run(s.quit)
+}
+
+// 运行子进程，stdout和stderr按行写入日志
+func (s *Supervisor) runProc() error {
+	cmd := exec.Command(s.Command, s.Args...)
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	stderr, err := cmd.StderrPipe()
+	if err != nil {
+		return err
+	}
+	if err = cmd.Start(); err != nil {
+		return err
+	}
+	s.mutex.Lock()
+	s.proc = cmd.Process
+	s.mutex.Unlock()
+	if s.stopped() { // Stop在启动过程中被调用
+		s.signal(syscall.SIGTERM)
+	}
+	wg := new(sync.WaitGroup)
+	wg.Add(2)
+	go s.capture(wg, stdout, s.Logger.Info)
+	go s.capture(wg, stderr, s.Logger.Warn)
+	wg.Wait()
+	err = cmd.Wait()
+	s.mutex.Lock()
+	s.proc = nil
+	s.mutex.Unlock()
+	return err
+}
+
+// 日志中一行的最大长度，超出部分丢弃
+const maxLogLine = 64 * 1024
+
+// 逐行输出，必须读到结束，否则子进程写满管道后会阻塞
+func (s *Supervisor) capture(wg *sync.WaitGroup, rd io.Reader, output func(args ...interface{})) {
+	defer wg.Done()
+	reader := bufio.NewReader(rd)
+	var line []byte
+	cut := false
+	for {
+		frag, isPrefix, err := reader.ReadLine()
+		if err != nil {
+			break
+		}
+		if room := maxLogLine - len(line); len(frag) > room {
+			frag, cut = frag[:room], true
+		}
+		line = append(line, frag...)
+		if isPrefix {
+			continue
+		}
+		if cut {
+			line = append(line, "..."...)
+		}
+		output(s.Name, ": ", string(line))
+		line, cut = line[:0], false
+	}
+	io.Copy(ioutil.Discard, reader)
+}
@@ -0,0 +1,102 @@
+//go:build android || darwin || dragonfly || freebsd || linux || netbsd || openbsd || solaris
+// +build android darwin dragonfly freebsd linux netbsd openbsd solaris
+
+package daemon
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func newTestSupervisor(s *Supervisor) (*Supervisor, *observer.ObservedLogs) {
+	core, logs := observer.New(zap.InfoLevel)
+	s.Logger = zap.New(core).Sugar()
+	s.MinBackoff, s.MaxBackoff = 10*time.Millisecond, 40*time.Millisecond
+	return s, logs
+}
+
+func TestSupervisorFunc(t *testing.T) {
+	times := 0
+	s, _ := newTestSupervisor(NewFuncSupervisor("func", func(quit <-chan struct{}) error {
+		times++
+		if times == 1 {
+			panic("boom")
+		} else if times == 2 {
+			return errors.New("failed")
+		}
+		return nil
+	}))
+	assert.NoError(t, s.Serve())
+	assert.Equal(t, 3, times)
+	assert.Equal(t, int64(3), s.Reporter.GetCount("starts"))
+	assert.Equal(t, int64(2), s.Reporter.GetCount("restarts"))
+	assert.Equal(t, int64(1), s.Reporter.GetCount("panics"))
+}
+
+func TestSupervisorLimit(t *testing.T) {
+	s, _ := newTestSupervisor(NewFuncSupervisor("func", func(quit <-chan struct{}) error {
+		return errors.New("failed")
+	}))
+	s.MaxRestarts, s.Window = 3, time.Minute
+	begin := time.Now()
+	assert.Equal(t, TooManyRestartsError, s.Serve())
+	assert.Equal(t, int64(4), s.Reporter.GetCount("starts"))
+	// 退避 10+20+40 毫秒
+	assert.True(t, time.Since(begin) >= 70*time.Millisecond)
+}
+
+func TestSupervisorStop(t *testing.T) {
+	s, _ := newTestSupervisor(NewFuncSupervisor("func", func(quit <-chan struct{}) error {
+		<-quit
+		return errors.New("stopped")
+	}))
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		s.Stop()
+	}()
+	assert.NoError(t, s.Serve())
+	assert.Equal(t, int64(1), s.Reporter.GetCount("starts"))
+}
+
+func TestSupervisorProc(t *testing.T) {
+	s, logs := newTestSupervisor(NewProcSupervisor("proc", "sh", "-c", "echo hello; echo oops >&2; exit 3"))
+	s.MaxRestarts = 1
+	assert.Equal(t, TooManyRestartsError, s.Serve())
+	assert.Equal(t, int64(2), s.Reporter.GetCount("starts"))
+	assert.Equal(t, 2, logs.FilterMessage("proc: hello").Len())
+	oops := logs.FilterMessage("proc: oops").All()
+	assert.Len(t, oops, 2)
+	assert.Equal(t, zap.WarnLevel, oops[0].Level)
+
+	// 超长的行截断，后面的输出照常记录，子进程不会因为管道写满而阻塞
+	script := "head -c 200000 /dev/zero | tr '\\0' x; echo; echo done"
+	s, logs = newTestSupervisor(NewProcSupervisor("long", "sh", "-c", script))
+	s.MaxRestarts = 0
+	done := make(chan error, 1)
+	go func() { done <- s.Serve() }()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		s.Stop()
+		t.Fatal("supervisor hangs on long line")
+	}
+	entries := logs.All()
+	if assert.Len(t, entries, 2) {
+		assert.Equal(t, len("long: ")+maxLogLine+3, len(entries[0].Message))
+		assert.Equal(t, "long: done", entries[1].Message)
+	}
+
+	s, _ = newTestSupervisor(NewProcSupervisor("sleep", "sleep", "10"))
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		s.Stop()
+	}()
+	begin := time.Now()
+	assert.NoError(t, s.Serve())
+	assert.True(t, time.Since(begin) < 5*time.Second)
+}
@@ -0,0 +1,230 @@
+//go:build android || darwin || dragonfly || freebsd || linux || netbsd || openbsd || solaris
+// +build android darwin dragonfly freebsd linux netbsd openbsd solaris
+
+package daemon
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"strconv"
+	"sync"
+	"time"
+)
+
+/*
+零停机升级：旧进程收到SIGUSR2后，启动新的可执行文件并传递监听套接字，
+新进程就绪后通知旧进程，旧进程停止监听，处理完已有连接后退出。
+
+func main() {
+	up := daemon.NewUpgrader()
+	ln, _ := up.Listen("tcp", ":8080") // 新进程会继承同一个套接字
+	prg := &daemon.Program{Main: func() { serve(ln) }, Upgrader: up}
+	prg.OnStop = func() { drain() } // 等待已有连接结束，可调用parallel.Scheduler的收尾工作
+	prg.Serve(daemon.NewConfig(name, desc))
+}
+*/
+
+const (
+	ENV_UPGRADE_FDS   = "GOZZO_UPGRADE_FDS"   // 继承的监听套接字，network:addr的JSON数组，地址中可能有逗号
+	ENV_UPGRADE_READY = "GOZZO_UPGRADE_READY" // 就绪通知管道的描述符
+)
+
+var UpgradingError = errors.New("upgrade is in progress")
+
+type Upgrader struct {
+	Executable   string   // 新的可执行文件，默认为当前程序
+	Args         []string // 默认为当前参数
+	ReadyTimeout time.Duration
+	inherited    map[string]*os.File
+	listeners    map[string]net.Listener
+	keys         []string
+	ready        *os.File
+	upgrading    bool
+	mutex        sync.Mutex
+}
+
+func NewUpgrader() *Upgrader {
+	exe, _ := os.Executable()
+	u := &Upgrader{
+		Executable:   exe,
+		Args:         os.Args[1:],
+		ReadyTimeout: 30 * time.Second,
+		inherited:    make(map[string]*os.File),
+		listeners:    make(map[string]net.Listener),
+	}
+	u.inherit()
+	return u
+}
+
+// 是否由升级启动的新进程，调用Ready之后返回false
+func (u *Upgrader) Inherited() bool {
+	u.mutex.Lock()
+	defer u.mutex.Unlock()
+	return u.ready != nil
+}
+
+// 读取继承的描述符，管道在前，监听套接字在后
+func (u *Upgrader) inherit() {
+	fd, err := strconv.Atoi(os.Getenv(ENV_UPGRADE_READY))
+	if err != nil || fd < 3 {
+		return
+	}
+	u.ready = os.NewFile(uintptr(fd), "upgrade-ready")
+	var keys []string
+	if data := os.Getenv(ENV_UPGRADE_FDS); data != "" {
+		json.Unmarshal([]byte(data), &keys)
+	}
+	for i, key := range keys {
+		u.inherited[key] = os.NewFile(uintptr(fd+1+i), key)
+	}
+	os.Unsetenv(ENV_UPGRADE_READY)
+	os.Unsetenv(ENV_UPGRADE_FDS)
+}
+
+// 监听地址，优先使用从旧进程继承的套接字
+func (u *Upgrader) Listen(network, addr string) (net.Listener, error) {
+	u.mutex.Lock()
+	defer u.mutex.Unlock()
+	key := network + ":" + addr
+	if ln, ok := u.listeners[key]; ok {
+		return ln, nil
+	}
+	var (
+		ln  net.Listener
+		err error
+	)
+	if f, ok := u.inherited[key]; ok {
+		delete(u.inherited, key)
+		ln, err = net.FileListener(f)
+		f.Close()
+	} else {
+		ln, err = net.Listen(network, addr)
+	}
+	if err != nil {
+		return nil, err
+	}
+	u.listeners[key] = ln
+	u.keys = append(u.keys, key)
+	return ln, nil
+}
+
+// 新进程启动完成后调用，通知旧进程退出，并关闭没有用到的继承套接字
+func (u *Upgrader) Ready() error {
+	u.mutex.Lock()
+	defer u.mutex.Unlock()
+	for key, f := range u.inherited {
+		f.Close()
+		delete(u.inherited, key)
+	}
+	if u.ready == nil {
+		return nil
+	}
+	_, err := u.ready.Write([]byte{1})
+	u.ready.Close()
+	u.ready = nil
+	return err
+}
+
+// 启动新进程并等待其就绪，成功后关闭本进程的监听套接字
+// 之后调用方应处理完已有连接再退出
+func (u *Upgrader) Upgrade() error {
+	u.mutex.Lock()
+	if u.upgrading {
+		u.mutex.Unlock()
+		return UpgradingError
+	}
+	u.upgrading = true
+	defer func() {
+		u.mutex.Lock()
+		u.upgrading = false
+		u.mutex.Unlock()
+	}()
+	files, err := u.listenerFiles()
+	keys, _ := json.Marshal(u.keys)
+	u.mutex.Unlock()
+	if err != nil {
+		return err
+	}
+	defer closeFiles(files)
+	rd, wr, err := os.Pipe()
+	if err != nil {
+		return err
+	}
+	defer rd.Close()
+	cmd := exec.Command(u.Executable, u.Args...)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	cmd.ExtraFiles = append([]*os.File{wr}, files...)
+	cmd.Env = append(os.Environ(),
+		ENV_UPGRADE_READY+"=3", ENV_UPGRADE_FDS+"="+string(keys))
+	err = cmd.Start()
+	wr.Close()
+	if err != nil {
+		return err
+	}
+	go cmd.Wait() // 回收失败退出的子进程
+	if err = waitReady(rd, u.ReadyTimeout); err != nil {
+		cmd.Process.Kill()
+		return err
+	}
+	u.closeListeners()
+	return nil
+}
+
+func waitReady(rd *os.File, timeout time.Duration) error {
+	result := make(chan error, 1)
+	go func() {
+		buf := make([]byte, 1)
+		_, err := rd.Read(buf)
+		result <- err
+	}()
+	select {
+	case err := <-result:
+		if err != nil {
+			return fmt.Errorf("new process exited before ready: %s", err)
+		}
+		return nil
+	case <-time.After(timeout):
+		return fmt.Errorf("new process is not ready in %s", timeout)
+	}
+}
+
+// 复制监听套接字的描述符
+func (u *Upgrader) listenerFiles() ([]*os.File, error) {
+	var files []*os.File
+	for _, key := range u.keys {
+		ln, ok := u.listeners[key].(interface{ File() (*os.File, error) })
+		if !ok {
+			closeFiles(files)
+			return nil, fmt.Errorf("listener %s can not be passed", key)
+		}
+		f, err := ln.File()
+		if err != nil {
+			closeFiles(files)
+			return nil, err
+		}
+		files = append(files, f)
+	}
+	return files, nil
+}
+
+// 停止接受新连接，新进程持有的副本不受影响
+func (u *Upgrader) closeListeners() {
+	u.mutex.Lock()
+	defer u.mutex.Unlock()
+	for _, key := range u.keys {
+		if ln, ok := u.listeners[key].(*net.UnixListener); ok {
+			ln.SetUnlinkOnClose(false) // 不要删除新进程在用的socket文件
+		}
+		u.listeners[key].Close()
+	}
+}
+
+func closeFiles(files []*os.File) {
+	for _, f := range files {
+		f.Close()
+	}
+}
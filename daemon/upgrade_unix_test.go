@@ -0,0 +1,87 @@
+//go:build android || darwin || dragonfly || freebsd || linux || netbsd || openbsd || solaris
+// +build android darwin dragonfly freebsd linux netbsd openbsd solaris
+
+package daemon
+
+import (
+	"bufio"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const ENV_TEST_UPGRADE = "GOZZO_TEST_UPGRADE"
+
+// 升级后的新进程，由TestUpgrade重新执行测试程序启动
+func TestUpgradeChild(t *testing.T) {
+	if os.Getenv(ENV_TEST_UPGRADE) == "" {
+		t.Skip("only run as upgraded process")
+	}
+	u := NewUpgrader()
+	assert.True(t, u.Inherited())
+	ln, err := u.Listen("tcp", "127.0.0.1:0") // 与旧进程的参数相同
+	assert.NoError(t, err)
+	uln, err := u.Listen("unix", os.Getenv(ENV_TEST_UPGRADE))
+	assert.NoError(t, err)
+	assert.NoError(t, u.Ready())
+	for _, l := range []net.Listener{ln, uln} {
+		conn, err := l.Accept()
+		assert.NoError(t, err)
+		conn.Write([]byte("new " + l.Addr().Network() + "\n"))
+		conn.Close()
+	}
+}
+
+func TestUpgrade(t *testing.T) {
+	u := NewUpgrader()
+	assert.False(t, u.Inherited())
+	ln, err := u.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	addr := ln.Addr().String()
+	// 同一地址返回同一个监听器
+	ln2, _ := u.Listen("tcp", "127.0.0.1:0")
+	assert.Equal(t, ln, ln2)
+	// 地址中有逗号的unix socket
+	tmp, _ := ioutil.TempDir("", "upgrade")
+	defer os.RemoveAll(tmp)
+	sock := filepath.Join(tmp, "a,b.sock")
+	_, err = u.Listen("unix", sock)
+	assert.NoError(t, err)
+
+	u.Executable = os.Args[0]
+	u.Args = []string{"-test.run=^TestUpgradeChild$"}
+	u.ReadyTimeout = 10 * time.Second
+	os.Setenv(ENV_TEST_UPGRADE, sock)
+	defer os.Unsetenv(ENV_TEST_UPGRADE)
+	assert.NoError(t, u.Upgrade())
+
+	// 旧的监听器已关闭，新进程继续在同一地址服务
+	_, err = ln.Accept()
+	assert.Error(t, err)
+	for _, network := range []string{"tcp", "unix"} {
+		if network == "unix" {
+			addr = sock
+		}
+		conn, err := net.Dial(network, addr)
+		if !assert.NoError(t, err) {
+			return
+		}
+		line, err := bufio.NewReader(conn).ReadString('\n')
+		conn.Close()
+		assert.NoError(t, err)
+		assert.Equal(t, "new "+network+"\n", line)
+	}
+}
+
+func TestUpgradeNotReady(t *testing.T) {
+	u := NewUpgrader()
+	_, err := u.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	u.Executable, u.Args = "sh", []string{"-c", "exit 0"}
+	assert.Error(t, u.Upgrade())
+}
@@ -0,0 +1,91 @@
+//go:build darwin || linux
+// +build darwin linux
+
+package dynlib
+
+import (
+	"errors"
+	"io"
+	"plugin"
+	"sync"
+)
+
+// Go插件无法卸载
+var ErrCannotUnload = errors.New("go plugin can not be unloaded")
+
+// 已打开的插件，*plugin.Plugin 和 *ProcessLibrary 都符合
+type Library interface {
+	Lookup(name string) (plugin.Symbol, error)
+}
+
+// 插件的加载方式
+type Backend interface {
+	Open(path string) (Library, error)
+}
+
+type goPluginBackend struct{}
+
+func (goPluginBackend) Open(path string) (Library, error) {
+	plug, err := OpenPlugin(path)
+	if err != nil {
+		return nil, err
+	}
+	return plug, nil
+}
+
+// 进程内加载Go插件，默认方式
+var GoPluginBackend Backend = goPluginBackend{}
+
+var (
+	libraries    sync.Map
+	backend      = GoPluginBackend
+	backendMutex sync.RWMutex
+)
+
+// 切换加载方式，返回原来的，已打开的插件不受影响
+// LoadSymbol、LookupSymbol 和 Lookup[T] 随之切换，调用处不用修改
+func SetBackend(b Backend) Backend {
+	backendMutex.Lock()
+	defer backendMutex.Unlock()
+	old := backend
+	backend = b
+	return old
+}
+
+func currentBackend() Backend {
+	backendMutex.RLock()
+	defer backendMutex.RUnlock()
+	return backend
+}
+
+// 使用当前加载方式打开插件
+func OpenLibrary(path string) (Library, error) {
+	if lib, ok := libraries.Load(path); ok {
+		return lib.(Library), nil
+	}
+	lib, err := currentBackend().Open(path)
+	if err != nil {
+		return nil, err
+	}
+	if old, loaded := libraries.LoadOrStore(path, lib); loaded {
+		if c, ok := lib.(io.Closer); ok {
+			c.Close()
+		}
+		return old.(Library), nil
+	}
+	return lib, nil
+}
+
+// 卸载插件，子进程插件会退出，Go插件只能返回ErrCannotUnload
+func Unload(path string) error {
+	lib, ok := libraries.Load(path)
+	if !ok {
+		return nil
+	}
+	c, ok := lib.(io.Closer)
+	if !ok {
+		return ErrCannotUnload
+	}
+	libraries.Delete(path)
+	return c.Close()
+}
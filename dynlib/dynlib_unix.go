@@ -1,3 +1,4 @@
+//go:build darwin || linux
 // +build darwin linux
 
 package dynlib
@@ -9,28 +10,36 @@ import (
 
 var registry sync.Map
 
-//加载插件
+// 加载插件，出错时返回nil，需要错误原因请使用OpenPlugin
 func LoadPlugin(path string) *plugin.Plugin {
+	plug, _ := OpenPlugin(path)
+	return plug
+}
+
+// 加载插件并返回错误
+func OpenPlugin(path string) (*plugin.Plugin, error) {
 	if plug, ok := registry.Load(path); ok {
-		return plug.(*plugin.Plugin)
+		return plug.(*plugin.Plugin), nil
 	}
 	plug, err := plugin.Open(path)
 	if err != nil {
-		return nil
+		return nil, err
 	}
 	registry.Store(path, plug)
-	return plug
+	return plug, nil
 }
 
-//加载对象或方法
+// 加载对象或方法
 func LoadSymbol(path, name string) plugin.Symbol {
-	plug := LoadPlugin(path)
-	if plug == nil {
-		return nil
-	}
-	symb, err := plug.Lookup(name)
+	symb, _ := LookupSymbol(path, name)
+	return symb
+}
+
+// 加载对象或方法并返回错误，使用SetBackend设置的加载方式
+func LookupSymbol(path, name string) (plugin.Symbol, error) {
+	lib, err := OpenLibrary(path)
 	if err != nil {
-		return nil
+		return nil, err
 	}
-	return symb
+	return lib.Lookup(name)
 }
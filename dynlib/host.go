@@ -0,0 +1,105 @@
+package dynlib
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// 宿主，向插件暴露服务，并接收插件提供的实现
+type Host interface {
+	Service(name string) (interface{}, bool)
+	Provide(name string, impl interface{})
+}
+
+// 服务不存在或类型不符
+type ServiceError struct {
+	Name   string
+	Reason string
+}
+
+func (e *ServiceError) Error() string {
+	return fmt.Sprintf("service %s %s", e.Name, e.Reason)
+}
+
+// 简单的宿主实现
+type ServiceHost struct {
+	services map[string]interface{}
+	provided map[string]interface{}
+	mutex    sync.RWMutex
+}
+
+func NewServiceHost() *ServiceHost {
+	return &ServiceHost{
+		services: make(map[string]interface{}),
+		provided: make(map[string]interface{}),
+	}
+}
+
+// 暴露服务给插件
+func (h *ServiceHost) Expose(name string, svc interface{}) {
+	h.mutex.Lock()
+	h.services[name] = svc
+	h.mutex.Unlock()
+}
+
+// 插件获取宿主的服务
+func (h *ServiceHost) Service(name string) (interface{}, bool) {
+	h.mutex.RLock()
+	defer h.mutex.RUnlock()
+	svc, ok := h.services[name]
+	return svc, ok
+}
+
+// 插件提供实现，同名的后注册者覆盖前者
+func (h *ServiceHost) Provide(name string, impl interface{}) {
+	h.mutex.Lock()
+	h.provided[name] = impl
+	h.mutex.Unlock()
+}
+
+// 宿主获取插件提供的实现
+func (h *ServiceHost) Provided(name string) (interface{}, bool) {
+	h.mutex.RLock()
+	defer h.mutex.RUnlock()
+	impl, ok := h.provided[name]
+	return impl, ok
+}
+
+// 插件提供的全部实现名称
+func (h *ServiceHost) ProvidedNames() []string {
+	h.mutex.RLock()
+	defer h.mutex.RUnlock()
+	names := make([]string, 0, len(h.provided))
+	for name := range h.provided {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// 获取指定类型的服务，插件中常用 dynlib.GetService[Logger](host, "logger")
+func GetService[T any](h Host, name string) (T, error) {
+	var zero T
+	svc, ok := h.Service(name)
+	if !ok {
+		return zero, &ServiceError{Name: name, Reason: "not found"}
+	}
+	if v, ok := svc.(T); ok {
+		return v, nil
+	}
+	return zero, &ServiceError{Name: name, Reason: fmt.Sprintf("is %T", svc)}
+}
+
+// 获取插件提供的指定类型的实现
+func GetProvided[T any](h *ServiceHost, name string) (T, error) {
+	var zero T
+	impl, ok := h.Provided(name)
+	if !ok {
+		return zero, &ServiceError{Name: name, Reason: "not provided"}
+	}
+	if v, ok := impl.(T); ok {
+		return v, nil
+	}
+	return zero, &ServiceError{Name: name, Reason: fmt.Sprintf("is %T", impl)}
+}
@@ -0,0 +1,268 @@
+//go:build darwin || linux
+// +build darwin linux
+
+package dynlib
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"plugin"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/azhai/gozzo-utils/filesystem"
+)
+
+// 插件必须导出的清单变量名
+const MANIFEST_SYMBOL = "Manifest"
+
+// Go插件无法重新加载，新版本必须使用新的文件名
+var ErrPluginReplaced = errors.New("plugin file changed after loading, use a new filename for the new version")
+
+// 插件清单，插件中这样导出：
+// var Manifest = dynlib.Manifest{Name: "geo", Version: "1.2.0", APIVersion: 1}
+type Manifest struct {
+	Name       string
+	Version    string // 形如1.2.3，用于比较新旧
+	APIVersion int    // 与宿主约定的接口版本，必须一致
+}
+
+// 已加载的插件
+type Loaded struct {
+	Path     string
+	Manifest Manifest
+	LoadedAt time.Time
+	ModTime  time.Time // 加载时文件的修改时间和大小，用于发现原地替换
+	Size     int64
+	*plugin.Plugin
+}
+
+// 文件在加载之后是否被修改或替换
+func (ld *Loaded) Changed(info os.FileInfo) bool {
+	return !info.ModTime().Equal(ld.ModTime) || info.Size() != ld.Size
+}
+
+// 比较版本号，按点分隔逐段比较数字
+func CompareVersion(a, b string) int {
+	pa, pb := strings.Split(a, "."), strings.Split(b, ".")
+	for i := 0; i < len(pa) || i < len(pb); i++ {
+		var x, y int
+		if i < len(pa) {
+			x, _ = strconv.Atoi(pa[i])
+		}
+		if i < len(pb) {
+			y, _ = strconv.Atoi(pb[i])
+		}
+		if x != y {
+			if x < y {
+				return -1
+			}
+			return 1
+		}
+	}
+	return 0
+}
+
+// 插件管理，同一插件的多个版本可以同时加载，调用方总是拿到最新版本
+// 用法: m := NewManager("./plugins", 1); m.Scan(); m.Lookup("geo", "Distance")
+type Manager struct {
+	Dir        string
+	Pattern    string // 插件文件的匹配模式
+	APIVersion int
+	OnSwitch   func(old, curr *Loaded) // 切换版本后回调，old可能为nil
+	Host       Host                    // 不为nil时，调用插件导出的Register
+	active     map[string]*Loaded      // 名称 -> 当前版本
+	loaded     map[string]*Loaded      // 路径 -> 插件
+	failed     map[string]time.Time    // 加载失败的文件和修改时间，避免反复加载
+	mutex      sync.RWMutex
+}
+
+func NewManager(dir string, apiVersion int) *Manager {
+	return &Manager{
+		Dir: dir, Pattern: "*.so", APIVersion: apiVersion,
+		active: make(map[string]*Loaded),
+		loaded: make(map[string]*Loaded),
+		failed: make(map[string]time.Time),
+	}
+}
+
+// 读取并检查清单
+func ReadManifest(plug *plugin.Plugin) (*Manifest, error) {
+	symb, err := plug.Lookup(MANIFEST_SYMBOL)
+	if err != nil {
+		return nil, err
+	}
+	mf, ok := symb.(*Manifest)
+	if !ok {
+		return nil, fmt.Errorf("symbol %s is %T, not *dynlib.Manifest", MANIFEST_SYMBOL, symb)
+	}
+	if mf.Name == "" || mf.Version == "" {
+		return nil, fmt.Errorf("manifest name and version are required")
+	}
+	return mf, nil
+}
+
+// 加载插件，版本比当前的新时切换过去
+// 已加载的文件被原地替换时返回ErrPluginReplaced，仍在使用的是旧版本
+func (m *Manager) Load(path string) (*Loaded, error) {
+	path, _ = filepath.Abs(path)
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	m.mutex.RLock()
+	ld, ok := m.loaded[path]
+	m.mutex.RUnlock()
+	if ok {
+		if ld.Changed(info) {
+			return ld, fmt.Errorf("%s: %w", path, ErrPluginReplaced)
+		}
+		return ld, nil
+	}
+	plug, err := OpenPlugin(path)
+	if err != nil {
+		return nil, err
+	}
+	mf, err := ReadManifest(plug)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", path, err)
+	}
+	if mf.APIVersion != m.APIVersion {
+		return nil, fmt.Errorf("%s: api version %d, want %d", path, mf.APIVersion, m.APIVersion)
+	}
+	if m.Host != nil {
+		if _, err = plug.Lookup(REGISTER_SYMBOL); err == nil {
+			if err = RegisterPlugin(plug, m.Host); err != nil {
+				return nil, fmt.Errorf("%s: register: %s", path, err)
+			}
+		}
+	}
+	ld = &Loaded{
+		Path: path, Manifest: *mf, LoadedAt: time.Now(),
+		ModTime: info.ModTime(), Size: info.Size(), Plugin: plug,
+	}
+	m.mutex.Lock()
+	m.loaded[path] = ld
+	old := m.active[mf.Name]
+	switched := old == nil || CompareVersion(mf.Version, old.Manifest.Version) > 0
+	if switched {
+		m.active[mf.Name] = ld
+	}
+	m.mutex.Unlock()
+	if switched && m.OnSwitch != nil {
+		m.OnSwitch(old, ld)
+	}
+	return ld, nil
+}
+
+// 加载目录下新出现的插件，返回新加载的插件和错误
+// 已加载的文件被原地覆盖时无法重新加载，返回一次ErrPluginReplaced，
+// 新版本请使用新的文件名，如 geo_v2.so
+func (m *Manager) Scan() ([]*Loaded, []error) {
+	var (
+		result []*Loaded
+		errs   []error
+	)
+	paths, err := filepath.Glob(filepath.Join(m.Dir, m.Pattern))
+	if err != nil {
+		return nil, []error{err}
+	}
+	sort.Strings(paths)
+	for _, path := range paths {
+		path, _ = filepath.Abs(path)
+		info, err := os.Stat(path)
+		if err != nil || info.IsDir() {
+			continue
+		}
+		m.mutex.RLock()
+		ld, ok := m.loaded[path]
+		failedAt, failed := m.failed[path]
+		m.mutex.RUnlock()
+		if (ok && !ld.Changed(info)) || (failed && failedAt.Equal(info.ModTime())) {
+			continue
+		}
+		ld, err = m.Load(path)
+		m.mutex.Lock()
+		if err != nil {
+			m.failed[path] = info.ModTime()
+			errs = append(errs, err)
+		} else {
+			delete(m.failed, path)
+			result = append(result, ld)
+		}
+		m.mutex.Unlock()
+	}
+	return result, errs
+}
+
+// 监视目录，有变化时扫描，直到quit关闭
+// 原地覆盖已加载的插件会通过onError报告ErrPluginReplaced，不会加载新内容
+// 优先使用inotify，interval作为合并变化的时间，不可用时按interval轮询
+func (m *Manager) Watch(interval time.Duration, quit <-chan struct{}, onError func(error)) {
+	report := func(errs ...error) {
+		if onError != nil {
+			for _, err := range errs {
+				onError(err)
+			}
+		}
+	}
+	scan := func() {
+		_, errs := m.Scan()
+		report(errs...)
+	}
+	scan()
+	w, err := filesystem.NewWatcher(&filesystem.WatchOptions{Debounce: interval, Interval: interval})
+	if err == nil {
+		defer w.Close()
+		err = w.Add(m.Dir)
+	}
+	if err != nil {
+		report(err)
+		return
+	}
+	for {
+		select {
+		case <-quit:
+			return
+		case _, ok := <-w.Events:
+			if !ok {
+				return
+			}
+			scan()
+		case err = <-w.Errors:
+			report(err)
+		}
+	}
+}
+
+// 插件的当前版本
+func (m *Manager) Get(name string) *Loaded {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+	return m.active[name]
+}
+
+// 全部插件的当前版本
+func (m *Manager) Actives() map[string]*Loaded {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+	result := make(map[string]*Loaded, len(m.active))
+	for name, ld := range m.active {
+		result[name] = ld
+	}
+	return result
+}
+
+// 在插件的当前版本中查找对象或方法
+func (m *Manager) Lookup(name, symbol string) (plugin.Symbol, error) {
+	ld := m.Get(name)
+	if ld == nil {
+		return nil, fmt.Errorf("plugin %s is not loaded", name)
+	}
+	return ld.Lookup(symbol)
+}
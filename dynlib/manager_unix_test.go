@@ -0,0 +1,113 @@
+//go:build darwin || linux
+// +build darwin linux
+
+package dynlib_test
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/azhai/gozzo-utils/dynlib"
+	"github.com/stretchr/testify/assert"
+)
+
+// 编译testdata下的插件，无法编译时跳过测试
+func buildPlugin(t *testing.T, dir, name string) string {
+	out := filepath.Join(dir, name+".so")
+	cmd := exec.Command("go", "build", "-buildmode=plugin", "-o", out, "./testdata/"+name)
+	if msg, err := cmd.CombinedOutput(); err != nil {
+		t.Skipf("can not build plugin %s: %s %s", name, err, msg)
+	}
+	return out
+}
+
+func TestCompareVersion(t *testing.T) {
+	assert.Equal(t, 0, dynlib.CompareVersion("1.2.0", "1.2"))
+	assert.Equal(t, -1, dynlib.CompareVersion("1.2.9", "1.10.0"))
+	assert.Equal(t, 1, dynlib.CompareVersion("2", "1.99"))
+}
+
+func TestManager(t *testing.T) {
+	tmp, _ := ioutil.TempDir("", "dynlib")
+	defer os.RemoveAll(tmp)
+	v1 := buildPlugin(t, tmp, "geo_v1")
+	v2 := buildPlugin(t, tmp, "geo_v2")
+	api2 := buildPlugin(t, tmp, "geo_api2")
+
+	dir := filepath.Join(tmp, "plugins")
+	os.Mkdir(dir, 0755)
+	m := dynlib.NewManager(dir, 1)
+	var switches []string
+	m.OnSwitch = func(old, curr *dynlib.Loaded) {
+		switches = append(switches, curr.Manifest.Version)
+	}
+	os.Rename(v1, filepath.Join(dir, "geo_v1.so"))
+	loaded, errs := m.Scan()
+	assert.Len(t, loaded, 1)
+	assert.Empty(t, errs)
+	symb, err := m.Lookup("geo", "Version")
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, "v1", symb.(func() string)())
+
+	// 新版本与旧版本同时加载，调用方切换到新版本
+	os.Rename(v2, filepath.Join(dir, "geo_v2.so"))
+	os.Rename(api2, filepath.Join(dir, "geo_api2.so"))
+	loaded, errs = m.Scan()
+	assert.Len(t, loaded, 1)
+	assert.Len(t, errs, 1)
+	assert.Contains(t, errs[0].Error(), "api version 2")
+	symb, err = m.Lookup("geo", "Version")
+	assert.NoError(t, err)
+	assert.Equal(t, "v2", symb.(func() string)())
+	assert.Equal(t, []string{"1.1.0", "1.2.0"}, switches)
+
+	// 失败的文件不会重复加载
+	loaded, errs = m.Scan()
+	assert.Empty(t, loaded)
+	assert.Empty(t, errs)
+
+	// 原地覆盖已加载的插件，报告一次错误，仍使用原来的版本
+	later := time.Now().Add(time.Minute)
+	os.Chtimes(filepath.Join(dir, "geo_v2.so"), later, later)
+	loaded, errs = m.Scan()
+	assert.Empty(t, loaded)
+	if assert.Len(t, errs, 1) {
+		assert.True(t, errors.Is(errs[0], dynlib.ErrPluginReplaced))
+	}
+	loaded, errs = m.Scan()
+	assert.Empty(t, errs)
+	assert.Equal(t, "1.2.0", m.Get("geo").Manifest.Version)
+
+	_, err = m.Lookup("none", "Version")
+	assert.Error(t, err)
+	_, err = dynlib.OpenPlugin(filepath.Join(dir, "missing.so"))
+	assert.Error(t, err)
+	assert.Nil(t, dynlib.LoadPlugin(filepath.Join(dir, "missing.so")))
+}
+
+func TestManagerWatch(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "dynlib")
+	defer os.RemoveAll(dir)
+	m := dynlib.NewManager(dir, 1)
+	quit := make(chan struct{})
+	errs := make(chan error, 4)
+	go m.Watch(50*time.Millisecond, quit, func(err error) { errs <- err })
+	defer close(quit)
+
+	// 新出现的文件会被加载，无效的插件报告错误
+	time.Sleep(100 * time.Millisecond)
+	ioutil.WriteFile(filepath.Join(dir, "bad.so"), []byte("not a plugin"), 0644)
+	select {
+	case err := <-errs:
+		assert.Contains(t, err.Error(), "bad.so")
+	case <-time.After(3 * time.Second):
+		t.Fatal("plugin was not scanned")
+	}
+}
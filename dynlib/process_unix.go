@@ -0,0 +1,317 @@
+//go:build darwin || linux
+// +build darwin linux
+
+package dynlib
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/rpc"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"plugin"
+	"reflect"
+	"sync"
+	"time"
+)
+
+var (
+	rpcTypes = make(map[string]reflect.Type)
+	rpcMutex sync.RWMutex
+)
+
+func init() {
+	RegisterType(false, "", []byte(nil), []string(nil), map[string]string(nil),
+		int(0), int8(0), int16(0), int32(0), int64(0), []int(nil), []int64(nil),
+		uint(0), uint8(0), uint16(0), uint32(0), uint64(0),
+		float32(0), float64(0), []float64(nil), time.Time{}, time.Duration(0),
+		Manifest{})
+	rpcTypes["error"] = errorType
+}
+
+// 登记子进程插件的参数和返回值类型，内置了常用的基本类型
+// 自定义类型在宿主中登记一次，如 dynlib.RegisterType(Point{}, []Point(nil))
+func RegisterType(samples ...interface{}) {
+	rpcMutex.Lock()
+	defer rpcMutex.Unlock()
+	for _, sample := range samples {
+		t := reflect.TypeOf(sample)
+		rpcTypes[t.String()] = t
+	}
+}
+
+func lookupType(name string) (reflect.Type, error) {
+	rpcMutex.RLock()
+	defer rpcMutex.RUnlock()
+	if t, ok := rpcTypes[name]; ok {
+		return t, nil
+	}
+	return nil, fmt.Errorf("type %s is not registered", name)
+}
+
+func lookupTypes(names []string) ([]reflect.Type, error) {
+	types := make([]reflect.Type, len(names))
+	for i, name := range names {
+		t, err := lookupType(name)
+		if err != nil {
+			return nil, err
+		}
+		types[i] = t
+	}
+	return types, nil
+}
+
+// 以子进程运行插件，通过RPC调用，可以随时卸载，也不要求依赖版本一致
+// 用法: dynlib.SetBackend(dynlib.NewProcessBackend()); dynlib.LoadSymbol("./plugins/geo", "Version")
+type ProcessBackend struct {
+	Args         []string
+	Env          []string
+	UseSocket    bool          // 使用unix socket通信，否则使用标准输入输出
+	StartTimeout time.Duration // 等待子进程握手的时间
+	Stderr       io.Writer     // 子进程的标准错误，默认为os.Stderr
+}
+
+func NewProcessBackend() *ProcessBackend {
+	return &ProcessBackend{StartTimeout: 5 * time.Second}
+}
+
+func (b *ProcessBackend) Open(path string) (Library, error) {
+	return b.Start(path)
+}
+
+// 启动子进程插件并握手
+func (b *ProcessBackend) Start(path string) (*ProcessLibrary, error) {
+	cmd := exec.Command(path, b.Args...)
+	cmd.Env = append(os.Environ(), b.Env...)
+	cmd.Stderr = b.Stderr
+	if cmd.Stderr == nil {
+		cmd.Stderr = os.Stderr
+	}
+	lib := &ProcessLibrary{Path: path, cmd: cmd,
+		symbols: make(map[string]plugin.Symbol), done: make(chan struct{})}
+	var (
+		conn io.ReadWriteCloser
+		err  error
+	)
+	if b.UseSocket {
+		conn, err = b.startSocket(lib)
+	} else {
+		conn, err = b.startStdio(lib)
+	}
+	if err != nil {
+		return nil, err
+	}
+	lib.client = rpc.NewClient(conn)
+	var proto int
+	call := lib.client.Go(RPC_SERVICE+".Hello", 0, &proto, nil)
+	select {
+	case <-call.Done:
+		err = call.Error
+	case <-lib.done:
+		err = fmt.Errorf("plugin %s exited: %v", path, lib.exitErr)
+	case <-time.After(b.StartTimeout):
+		err = fmt.Errorf("plugin %s handshake timeout", path)
+	}
+	if err == nil && proto != RPC_PROTOCOL {
+		err = fmt.Errorf("plugin %s protocol %d, want %d", path, proto, RPC_PROTOCOL)
+	}
+	if err != nil {
+		lib.Close()
+		return nil, err
+	}
+	return lib, nil
+}
+
+func (b *ProcessBackend) startStdio(lib *ProcessLibrary) (io.ReadWriteCloser, error) {
+	stdin, err := lib.cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := lib.cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err = lib.start(); err != nil {
+		return nil, err
+	}
+	return pipeConn{ReadCloser: stdout, w: stdin}, nil
+}
+
+func (b *ProcessBackend) startSocket(lib *ProcessLibrary) (io.ReadWriteCloser, error) {
+	dir, err := ioutil.TempDir("", "dynlib")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+	sock := filepath.Join(dir, "plugin.sock")
+	ln, err := net.Listen("unix", sock)
+	if err != nil {
+		return nil, err
+	}
+	defer ln.Close()
+	lib.cmd.Env = append(lib.cmd.Env, ENV_PLUGIN_SOCKET+"="+sock)
+	lib.cmd.Stdout = lib.cmd.Stderr
+	if err = lib.start(); err != nil {
+		return nil, err
+	}
+	accepted := make(chan net.Conn, 1)
+	go func() {
+		if conn, err := ln.Accept(); err == nil {
+			accepted <- conn
+		}
+	}()
+	select {
+	case conn := <-accepted:
+		return conn, nil
+	case <-lib.done:
+		err = fmt.Errorf("plugin %s exited: %v", lib.Path, lib.exitErr)
+	case <-time.After(b.StartTimeout):
+		err = fmt.Errorf("plugin %s connect timeout", lib.Path)
+	}
+	lib.cmd.Process.Kill()
+	<-lib.done
+	return nil, err
+}
+
+// 子进程插件
+type ProcessLibrary struct {
+	Path    string
+	cmd     *exec.Cmd
+	client  *rpc.Client
+	symbols map[string]plugin.Symbol
+	mutex   sync.Mutex
+	done    chan struct{}
+	exitErr error
+}
+
+// 启动子进程，并在退出时关闭done
+func (l *ProcessLibrary) start() error {
+	if err := l.cmd.Start(); err != nil {
+		return err
+	}
+	go func() {
+		l.exitErr = l.cmd.Wait()
+		close(l.done)
+	}()
+	return nil
+}
+
+func (l *ProcessLibrary) Pid() int {
+	return l.cmd.Process.Pid
+}
+
+// 子进程是否已退出
+func (l *ProcessLibrary) Exited() bool {
+	select {
+	case <-l.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// 查找对象或方法，方法包装为同样签名的函数，变量返回加载时的值的指针
+func (l *ProcessLibrary) Lookup(name string) (plugin.Symbol, error) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	if symb, ok := l.symbols[name]; ok {
+		return symb, nil
+	}
+	var info SymbolInfo
+	if err := l.client.Call(RPC_SERVICE+".Lookup", name, &info); err != nil {
+		return nil, err
+	}
+	symb, err := l.makeSymbol(info)
+	if err != nil {
+		return nil, fmt.Errorf("plugin %s: symbol %s: %s", l.Path, name, err)
+	}
+	l.symbols[name] = symb
+	return symb, nil
+}
+
+func (l *ProcessLibrary) makeSymbol(info SymbolInfo) (plugin.Symbol, error) {
+	out, err := lookupTypes(info.Out)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsFunc {
+		var data []byte
+		if err = l.client.Call(RPC_SERVICE+".Get", info.Name, &data); err != nil {
+			return nil, err
+		}
+		v, err := decodeValue(data, out[0])
+		if err != nil {
+			return nil, err
+		}
+		ptr := reflect.New(out[0])
+		ptr.Elem().Set(v)
+		return ptr.Interface(), nil
+	}
+	in, err := lookupTypes(info.In)
+	if err != nil {
+		return nil, err
+	}
+	ft := reflect.FuncOf(in, out, info.Variadic)
+	fn := reflect.MakeFunc(ft, func(args []reflect.Value) []reflect.Value {
+		results, err := l.call(info.Name, args, out)
+		if err == nil {
+			return results
+		}
+		// 调用失败时，最后一个返回值为error则通过它返回，否则panic
+		n := len(out)
+		if n == 0 || out[n-1] != errorType {
+			panic(err)
+		}
+		results = make([]reflect.Value, n)
+		for i, t := range out {
+			results[i] = reflect.Zero(t)
+		}
+		results[n-1] = reflect.ValueOf(&err).Elem()
+		return results
+	})
+	return fn.Interface(), nil
+}
+
+func (l *ProcessLibrary) call(name string, args []reflect.Value, out []reflect.Type) ([]reflect.Value, error) {
+	req := CallArgs{Name: name, Args: make([][]byte, len(args))}
+	var err error
+	for i, arg := range args {
+		if req.Args[i], err = encodeValue(arg); err != nil {
+			return nil, err
+		}
+	}
+	var reply CallReply
+	if err = l.client.Call(RPC_SERVICE+".Call", req, &reply); err != nil {
+		return nil, err
+	}
+	if len(reply.Results) != len(out) {
+		return nil, fmt.Errorf("symbol %s returns %d values, want %d", name, len(reply.Results), len(out))
+	}
+	results := make([]reflect.Value, len(out))
+	for i, data := range reply.Results {
+		if results[i], err = decodeValue(data, out[i]); err != nil {
+			return nil, err
+		}
+	}
+	return results, nil
+}
+
+// 断开连接，子进程应当随之退出，超时则强制结束
+func (l *ProcessLibrary) Close() error {
+	err := l.client.Close()
+	select {
+	case <-l.done:
+	case <-time.After(3 * time.Second):
+		l.cmd.Process.Kill()
+		<-l.done
+	}
+	// 子进程退出时管道已被关闭
+	if err == rpc.ErrShutdown || errors.Is(err, os.ErrClosed) {
+		err = nil
+	}
+	return err
+}
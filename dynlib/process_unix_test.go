@@ -0,0 +1,114 @@
+//go:build darwin || linux
+// +build darwin linux
+
+package dynlib_test
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/azhai/gozzo-utils/dynlib"
+	"github.com/stretchr/testify/assert"
+)
+
+// 编译testdata下的子进程插件
+func buildProgram(t *testing.T, dir, name string) string {
+	out := filepath.Join(dir, name)
+	cmd := exec.Command("go", "build", "-o", out, "./testdata/"+name)
+	if msg, err := cmd.CombinedOutput(); err != nil {
+		t.Skipf("can not build program %s: %s %s", name, err, msg)
+	}
+	return out
+}
+
+func testProcessLibrary(t *testing.T, lib *dynlib.ProcessLibrary) {
+	symb, err := lib.Lookup("Version")
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, "proc", symb.(func() string)())
+	symb, err = lib.Lookup("Manifest")
+	if assert.NoError(t, err) {
+		assert.Equal(t, "2.0.0", symb.(*dynlib.Manifest).Version)
+	}
+	symb, _ = lib.Lookup("Join")
+	assert.Equal(t, "a-b-c", symb.(func(string, ...string) string)("-", "a", "b", "c"))
+	symb, _ = lib.Lookup("Divide")
+	divide := symb.(func(float64, float64) (float64, error))
+	q, err := divide(3, 2)
+	assert.NoError(t, err)
+	assert.Equal(t, 1.5, q)
+	_, err = divide(1, 0)
+	assert.EqualError(t, err, "divide by zero")
+
+	symb, _ = lib.Lookup("Crash")
+	assert.Panics(t, func() { symb.(func())() })
+	_, err = lib.Lookup("Nothing")
+	assert.Error(t, err)
+
+	// 子进程退出后通过error返回
+	symb, _ = lib.Lookup("Exit")
+	assert.Error(t, symb.(func() error)())
+	assert.NoError(t, lib.Close())
+	assert.True(t, lib.Exited())
+}
+
+func TestProcessBackend(t *testing.T) {
+	tmp, _ := ioutil.TempDir("", "dynlib")
+	defer os.RemoveAll(tmp)
+	path := buildProgram(t, tmp, "procgeo")
+
+	var stderr bytes.Buffer
+	b := dynlib.NewProcessBackend()
+	b.Stderr = &stderr
+	lib, err := b.Start(path)
+	if assert.NoError(t, err) {
+		testProcessLibrary(t, lib)
+	}
+	assert.Contains(t, stderr.String(), "printed to stderr")
+
+	b.UseSocket = true
+	lib, err = b.Start(path)
+	if assert.NoError(t, err) {
+		testProcessLibrary(t, lib)
+	}
+
+	_, err = b.Start("/bin/true")
+	assert.Error(t, err)
+}
+
+func TestSetBackend(t *testing.T) {
+	tmp, _ := ioutil.TempDir("", "dynlib")
+	defer os.RemoveAll(tmp)
+	path := buildProgram(t, tmp, "procgeo")
+
+	b := dynlib.NewProcessBackend()
+	b.Stderr = ioutil.Discard
+	old := dynlib.SetBackend(b)
+	defer dynlib.SetBackend(old)
+	// 调用方式与Go插件相同
+	symb := dynlib.LoadSymbol(path, "Version")
+	if assert.NotNil(t, symb) {
+		assert.Equal(t, "proc", symb.(func() string)())
+	}
+	pid, err := dynlib.Lookup[func() int](path, "Pid")
+	if !assert.NoError(t, err) {
+		return
+	}
+	first := pid()
+	mf, err := dynlib.Lookup[dynlib.Manifest](path, "Manifest")
+	assert.NoError(t, err)
+	assert.Equal(t, "geo", mf.Name)
+
+	// 卸载后重新加载是新的进程
+	assert.NoError(t, dynlib.Unload(path))
+	pid, err = dynlib.Lookup[func() int](path, "Pid")
+	if assert.NoError(t, err) {
+		assert.NotEqual(t, first, pid())
+	}
+	assert.NoError(t, dynlib.Unload(path))
+}
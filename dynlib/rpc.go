@@ -0,0 +1,235 @@
+package dynlib
+
+import (
+	"bytes"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/rpc"
+	"os"
+	"reflect"
+)
+
+const (
+	ENV_PLUGIN_SOCKET = "GOZZO_PLUGIN_SOCKET" // 子进程插件连接的unix socket，为空时使用标准输入输出
+	RPC_SERVICE       = "DynlibPlugin"
+	RPC_PROTOCOL      = 1
+)
+
+var errorType = reflect.TypeOf((*error)(nil)).Elem()
+
+// 子进程插件的对象或方法
+type SymbolInfo struct {
+	Name     string
+	IsFunc   bool
+	In       []string // 参数类型
+	Out      []string // 返回类型，变量只有一项
+	Variadic bool
+}
+
+type CallArgs struct {
+	Name string
+	Args [][]byte
+}
+
+type CallReply struct {
+	Results [][]byte
+}
+
+// error在两端不是同一个对象，只传递消息
+type errorValue struct {
+	Valid   bool
+	Message string
+}
+
+func typeString(t reflect.Type) string {
+	if t == errorType {
+		return "error"
+	}
+	return t.String()
+}
+
+func encodeValue(v reflect.Value) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := gob.NewEncoder(&buf)
+	if v.Type() == errorType {
+		ev := errorValue{}
+		if !v.IsNil() {
+			ev.Valid, ev.Message = true, v.Interface().(error).Error()
+		}
+		err := enc.Encode(ev)
+		return buf.Bytes(), err
+	}
+	err := enc.EncodeValue(v)
+	return buf.Bytes(), err
+}
+
+func decodeValue(data []byte, t reflect.Type) (reflect.Value, error) {
+	dec := gob.NewDecoder(bytes.NewReader(data))
+	if t == errorType {
+		var ev errorValue
+		v := reflect.New(errorType).Elem()
+		if err := dec.Decode(&ev); err != nil {
+			return v, err
+		}
+		if ev.Valid {
+			v.Set(reflect.ValueOf(errors.New(ev.Message)))
+		}
+		return v, nil
+	}
+	ptr := reflect.New(t)
+	err := dec.DecodeValue(ptr)
+	return ptr.Elem(), err
+}
+
+// 把管道的两端合成一个连接
+type pipeConn struct {
+	io.ReadCloser
+	w io.WriteCloser
+}
+
+func (c pipeConn) Write(p []byte) (int, error) {
+	return c.w.Write(p)
+}
+
+func (c pipeConn) Close() error {
+	err := c.w.Close()
+	if e := c.ReadCloser.Close(); err == nil {
+		err = e
+	}
+	return err
+}
+
+// 子进程中提供对象或方法
+type pluginServer struct {
+	symbols map[string]reflect.Value
+}
+
+func (s *pluginServer) symbol(name string) (reflect.Value, error) {
+	if v, ok := s.symbols[name]; ok {
+		return v, nil
+	}
+	return reflect.Value{}, fmt.Errorf("symbol %s not found", name)
+}
+
+func (s *pluginServer) Hello(_ int, reply *int) error {
+	*reply = RPC_PROTOCOL
+	return nil
+}
+
+func (s *pluginServer) Lookup(name string, reply *SymbolInfo) error {
+	v, err := s.symbol(name)
+	if err != nil {
+		return err
+	}
+	*reply = SymbolInfo{Name: name}
+	t := v.Type()
+	if t.Kind() != reflect.Func {
+		if t.Kind() == reflect.Ptr {
+			t = t.Elem()
+		}
+		reply.Out = []string{typeString(t)}
+		return nil
+	}
+	reply.IsFunc, reply.Variadic = true, t.IsVariadic()
+	for i := 0; i < t.NumIn(); i++ {
+		reply.In = append(reply.In, typeString(t.In(i)))
+	}
+	for i := 0; i < t.NumOut(); i++ {
+		reply.Out = append(reply.Out, typeString(t.Out(i)))
+	}
+	return nil
+}
+
+// 读取变量的当前值
+func (s *pluginServer) Get(name string, reply *[]byte) (err error) {
+	v, err := s.symbol(name)
+	if err != nil {
+		return err
+	}
+	if v.Kind() == reflect.Func {
+		return fmt.Errorf("symbol %s is a func", name)
+	}
+	if v.Kind() == reflect.Ptr {
+		v = v.Elem()
+	}
+	*reply, err = encodeValue(v)
+	return
+}
+
+func (s *pluginServer) Call(args CallArgs, reply *CallReply) (err error) {
+	fn, err := s.symbol(args.Name)
+	if err != nil {
+		return err
+	}
+	t := fn.Type()
+	if t.Kind() != reflect.Func {
+		return fmt.Errorf("symbol %s is not a func", args.Name)
+	}
+	if len(args.Args) != t.NumIn() {
+		return fmt.Errorf("symbol %s needs %d args, got %d", args.Name, t.NumIn(), len(args.Args))
+	}
+	in := make([]reflect.Value, len(args.Args))
+	for i, data := range args.Args {
+		if in[i], err = decodeValue(data, t.In(i)); err != nil {
+			return fmt.Errorf("arg %d: %s", i, err)
+		}
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("symbol %s panic: %v", args.Name, r)
+		}
+	}()
+	var out []reflect.Value
+	if t.IsVariadic() {
+		out = fn.CallSlice(in)
+	} else {
+		out = fn.Call(in)
+	}
+	reply.Results = make([][]byte, len(out))
+	for i, v := range out {
+		if reply.Results[i], err = encodeValue(v); err != nil {
+			return fmt.Errorf("result %d: %s", i, err)
+		}
+	}
+	return nil
+}
+
+// 在子进程插件的main中调用，提供对象或方法直到宿主断开
+// 与Go插件的约定相同，变量传指针，方法传函数本身：
+//
+//	func main() {
+//		dynlib.ServePlugin(map[string]interface{}{
+//			"Manifest": &Manifest, "Version": Version,
+//		})
+//	}
+//
+// 使用标准输入输出通信时，os.Stdout 被替换为 os.Stderr
+func ServePlugin(symbols map[string]interface{}) error {
+	srv := &pluginServer{symbols: make(map[string]reflect.Value, len(symbols))}
+	for name, symb := range symbols {
+		v := reflect.ValueOf(symb)
+		if !v.IsValid() || (v.Kind() == reflect.Ptr && v.IsNil()) {
+			return fmt.Errorf("symbol %s is nil", name)
+		}
+		srv.symbols[name] = v
+	}
+	server := rpc.NewServer()
+	if err := server.RegisterName(RPC_SERVICE, srv); err != nil {
+		return err
+	}
+	if sock := os.Getenv(ENV_PLUGIN_SOCKET); sock != "" {
+		conn, err := net.Dial("unix", sock)
+		if err != nil {
+			return err
+		}
+		server.ServeConn(conn)
+		return nil
+	}
+	conn := pipeConn{ReadCloser: os.Stdin, w: os.Stdout}
+	os.Stdout = os.Stderr
+	server.ServeConn(conn)
+	return nil
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/azhai/gozzo-utils/dynlib"
+)
+
+var Manifest = dynlib.Manifest{Name: "contract", Version: "1.0.0", APIVersion: 1}
+
+var DefaultUnit = "km"
+
+var Precision int = 2
+
+func Version() string {
+	return "contract"
+}
+
+func Distance(a, b float64) float64 {
+	if a > b {
+		return a - b
+	}
+	return b - a
+}
+
+func Register(host dynlib.Host) error {
+	prefix, err := dynlib.GetService[string](host, "prefix")
+	if err != nil {
+		return err
+	}
+	host.Provide("greet", func(name string) string {
+		return fmt.Sprintf("%s, %s", prefix, name)
+	})
+	return nil
+}
@@ -0,0 +1,9 @@
+package main
+
+import "github.com/azhai/gozzo-utils/dynlib"
+
+var Manifest = dynlib.Manifest{Name: "geo", Version: "2.0.0", APIVersion: 2}
+
+func Version() string {
+	return "api2"
+}
@@ -0,0 +1,9 @@
+package main
+
+import "github.com/azhai/gozzo-utils/dynlib"
+
+var Manifest = dynlib.Manifest{Name: "geo", Version: "1.1.0", APIVersion: 1}
+
+func Version() string {
+	return "v1"
+}
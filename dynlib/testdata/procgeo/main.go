@@ -0,0 +1,52 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/azhai/gozzo-utils/dynlib"
+)
+
+var Manifest = dynlib.Manifest{Name: "geo", Version: "2.0.0", APIVersion: 1}
+
+func Version() string {
+	fmt.Println("printed to stderr")
+	return "proc"
+}
+
+func Join(sep string, parts ...string) string {
+	return strings.Join(parts, sep)
+}
+
+func Divide(a, b float64) (float64, error) {
+	if b == 0 {
+		return 0, errors.New("divide by zero")
+	}
+	return a / b, nil
+}
+
+func Pid() int {
+	return os.Getpid()
+}
+
+func Crash() {
+	panic("boom")
+}
+
+func Exit() error {
+	os.Exit(3)
+	return nil
+}
+
+func main() {
+	err := dynlib.ServePlugin(map[string]interface{}{
+		"Manifest": &Manifest, "Version": Version, "Join": Join,
+		"Divide": Divide, "Pid": Pid, "Crash": Crash, "Exit": Exit,
+	})
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
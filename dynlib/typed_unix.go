@@ -0,0 +1,198 @@
+//go:build darwin || linux
+// +build darwin linux
+
+package dynlib
+
+import (
+	"fmt"
+	"plugin"
+	"reflect"
+	"strings"
+)
+
+// 插件导出的注册函数名，签名为 func(dynlib.Host) error
+const REGISTER_SYMBOL = "Register"
+
+// 插件不符合约定，列出全部缺失和类型不符的对象或方法
+type ContractError struct {
+	Path       string
+	Missing    []string
+	Mismatched []string
+}
+
+func (e *ContractError) Error() string {
+	var parts []string
+	if len(e.Missing) > 0 {
+		parts = append(parts, "missing "+strings.Join(e.Missing, ", "))
+	}
+	if len(e.Mismatched) > 0 {
+		parts = append(parts, "mismatched "+strings.Join(e.Mismatched, ", "))
+	}
+	return fmt.Sprintf("plugin %s: %s", e.Path, strings.Join(parts, "; "))
+}
+
+// 转为指定类型，变量导出时是指针，T为值类型时自动取值
+func SymbolAs[T any](symb plugin.Symbol, name string) (T, error) {
+	var zero T
+	if v, ok := symb.(T); ok {
+		return v, nil
+	}
+	if p, ok := symb.(*T); ok && p != nil {
+		return *p, nil
+	}
+	return zero, fmt.Errorf("symbol %s is %T, not %s", name, symb, typeName[T]())
+}
+
+// 加载指定类型的对象或方法，如 Lookup[func() string](path, "Version")
+func Lookup[T any](path, name string) (T, error) {
+	symb, err := LookupSymbol(path, name)
+	if err != nil {
+		var zero T
+		return zero, err
+	}
+	return SymbolAs[T](symb, name)
+}
+
+// 在插件的当前版本中查找指定类型的对象或方法
+func ManagerLookup[T any](m *Manager, name, symbol string) (T, error) {
+	symb, err := m.Lookup(name, symbol)
+	if err != nil {
+		var zero T
+		return zero, err
+	}
+	return SymbolAs[T](symb, symbol)
+}
+
+func typeName[T any]() string {
+	return reflect.TypeOf((*T)(nil)).Elem().String()
+}
+
+// 按结构体字段绑定插件的对象或方法，字段名即符号名，可用标签 plugin:"Name" 改名
+// 标签为 plugin:"-" 的字段跳过，标签带 ,optional 的字段允许缺失
+// 一次检查全部字段，不符合时返回 *ContractError
+//
+//	var api struct {
+//		Version  func() string
+//		Distance func(a, b float64) float64
+//		Unit     *string `plugin:"DefaultUnit"`
+//	}
+//	err := dynlib.Bind(plug, &api)
+func Bind(plug *plugin.Plugin, target interface{}) error {
+	err := bindSymbols(plug.Lookup, target)
+	if ce, ok := err.(*ContractError); ok {
+		ce.Path = pluginPath(plug)
+	}
+	return err
+}
+
+// 加载插件并绑定到结构体
+func BindPath(path string, target interface{}) error {
+	plug, err := OpenPlugin(path)
+	if err != nil {
+		return err
+	}
+	err = bindSymbols(plug.Lookup, target)
+	if ce, ok := err.(*ContractError); ok {
+		ce.Path = path
+	}
+	return err
+}
+
+// 只检查插件是否符合约定，contract 为结构体或其指针
+func CheckContract(plug *plugin.Plugin, contract interface{}) error {
+	rt := reflect.TypeOf(contract)
+	if rt != nil && rt.Kind() == reflect.Ptr {
+		rt = rt.Elem()
+	}
+	if rt == nil || rt.Kind() != reflect.Struct {
+		return fmt.Errorf("contract must be a struct, got %T", contract)
+	}
+	return Bind(plug, reflect.New(rt).Interface())
+}
+
+func pluginPath(plug *plugin.Plugin) string {
+	var path string
+	registry.Range(func(key, value interface{}) bool {
+		if value.(*plugin.Plugin) == plug {
+			path = key.(string)
+			return false
+		}
+		return true
+	})
+	return path
+}
+
+func bindSymbols(lookup func(string) (plugin.Symbol, error), target interface{}) error {
+	rv := reflect.ValueOf(target)
+	if rv.Kind() != reflect.Ptr || rv.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("target must be a pointer to struct, got %T", target)
+	}
+	rv = rv.Elem()
+	rt := rv.Type()
+	ce := &ContractError{}
+	for i := 0; i < rt.NumField(); i++ {
+		field := rt.Field(i)
+		if field.PkgPath != "" { // 未导出
+			continue
+		}
+		name, optional := field.Name, false
+		if tag, ok := field.Tag.Lookup("plugin"); ok {
+			if tag == "-" {
+				continue
+			}
+			pieces := strings.Split(tag, ",")
+			if pieces[0] != "" {
+				name = pieces[0]
+			}
+			for _, opt := range pieces[1:] {
+				optional = optional || opt == "optional"
+			}
+		}
+		symb, err := lookup(name)
+		if err != nil || symb == nil {
+			if !optional {
+				ce.Missing = append(ce.Missing, name)
+			}
+			continue
+		}
+		if !assignSymbol(rv.Field(i), symb) {
+			ce.Mismatched = append(ce.Mismatched,
+				fmt.Sprintf("%s(%T, want %s)", name, symb, field.Type))
+		}
+	}
+	if len(ce.Missing) > 0 || len(ce.Mismatched) > 0 {
+		return ce
+	}
+	return nil
+}
+
+func assignSymbol(dst reflect.Value, symb plugin.Symbol) bool {
+	sv := reflect.ValueOf(symb)
+	if sv.Type().AssignableTo(dst.Type()) {
+		dst.Set(sv)
+		return true
+	}
+	// 变量导出时是指针，字段为值类型时取值
+	if sv.Kind() == reflect.Ptr && !sv.IsNil() && sv.Elem().Type().AssignableTo(dst.Type()) {
+		dst.Set(sv.Elem())
+		return true
+	}
+	return false
+}
+
+// 调用插件的注册函数，向插件提供宿主的服务
+// 插件中这样导出：func Register(host dynlib.Host) error
+func RegisterPlugin(plug *plugin.Plugin, host Host) error {
+	symb, err := plug.Lookup(REGISTER_SYMBOL)
+	if err != nil {
+		return err
+	}
+	switch fn := symb.(type) {
+	case func(Host) error:
+		return fn(host)
+	case func(Host):
+		fn(host)
+		return nil
+	}
+	return fmt.Errorf("symbol %s is %T, not func(dynlib.Host) error", REGISTER_SYMBOL, symb)
+}
@@ -0,0 +1,109 @@
+//go:build darwin || linux
+// +build darwin linux
+
+package dynlib_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/azhai/gozzo-utils/dynlib"
+	"github.com/stretchr/testify/assert"
+)
+
+// 同一插件只能加载一次，编译一次后分别测试
+func TestContract(t *testing.T) {
+	tmp, _ := ioutil.TempDir("", "dynlib")
+	defer os.RemoveAll(tmp)
+	dir := filepath.Join(tmp, "plugins")
+	os.Mkdir(dir, 0755)
+	path := buildPlugin(t, dir, "contract")
+	t.Run("TypedLookup", func(t *testing.T) { testTypedLookup(t, path) })
+	t.Run("RegisterHost", func(t *testing.T) { testRegisterHost(t, path) })
+}
+
+func testTypedLookup(t *testing.T, path string) {
+	version, err := dynlib.Lookup[func() string](path, "Version")
+	if assert.NoError(t, err) {
+		assert.Equal(t, "contract", version())
+	}
+	unit, err := dynlib.Lookup[string](path, "DefaultUnit")
+	assert.NoError(t, err)
+	assert.Equal(t, "km", unit)
+	prec, err := dynlib.Lookup[*int](path, "Precision")
+	if assert.NoError(t, err) {
+		assert.Equal(t, 2, *prec)
+	}
+	_, err = dynlib.Lookup[func() int](path, "Version")
+	assert.EqualError(t, err, "symbol Version is func() string, not func() int")
+	_, err = dynlib.Lookup[string](path, "Nothing")
+	assert.Error(t, err)
+
+	var api struct {
+		Version  func() string
+		Distance func(a, b float64) float64
+		Unit     string         `plugin:"DefaultUnit"`
+		Scale    func() float64 `plugin:",optional"`
+		Skipped  int            `plugin:"-"`
+		internal int
+	}
+	if assert.NoError(t, dynlib.BindPath(path, &api)) {
+		assert.Equal(t, 1.5, api.Distance(1, 2.5))
+		assert.Equal(t, "km", api.Unit)
+		assert.Nil(t, api.Scale)
+	}
+
+	var bad struct {
+		Version  func() int
+		Distance func(a, b float64) float64
+		Area     func() float64
+		Radius   *float64
+	}
+	err = dynlib.BindPath(path, &bad)
+	ce, ok := err.(*dynlib.ContractError)
+	if assert.True(t, ok) {
+		assert.Equal(t, []string{"Area", "Radius"}, ce.Missing)
+		assert.Equal(t, []string{"Version(func() string, want func() int)"}, ce.Mismatched)
+		assert.Equal(t, path, ce.Path)
+	}
+	plug, err := dynlib.OpenPlugin(path)
+	if assert.NoError(t, err) {
+		assert.Error(t, dynlib.CheckContract(plug, bad))
+		assert.NoError(t, dynlib.CheckContract(plug, &api))
+	}
+}
+
+func testRegisterHost(t *testing.T, path string) {
+	plug, err := dynlib.OpenPlugin(path)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	host := dynlib.NewServiceHost()
+	err = dynlib.RegisterPlugin(plug, host)
+	assert.EqualError(t, err, "service prefix not found")
+
+	host.Expose("prefix", "Hello")
+	assert.NoError(t, dynlib.RegisterPlugin(plug, host))
+	greet, err := dynlib.GetProvided[func(string) string](host, "greet")
+	if assert.NoError(t, err) {
+		assert.Equal(t, "Hello, world", greet("world"))
+	}
+	_, err = dynlib.GetProvided[func() string](host, "greet")
+	assert.Error(t, err)
+
+	// 管理器加载时自动注册
+	other := dynlib.NewServiceHost()
+	other.Expose("prefix", "Hi")
+	m := dynlib.NewManager(filepath.Dir(path), 1)
+	m.Host = other
+	_, errs := m.Scan()
+	assert.Empty(t, errs)
+	assert.Equal(t, []string{"greet"}, other.ProvidedNames())
+	version, err := dynlib.ManagerLookup[func() string](m, "contract", "Version")
+	if assert.NoError(t, err) {
+		assert.Equal(t, "contract", version())
+	}
+}
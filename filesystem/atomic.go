@@ -0,0 +1,69 @@
+package filesystem
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+// 原子写入文件，先写同目录下的临时文件并落盘，再改名覆盖目标并同步目录
+// 中途出错或崩溃时，目标文件保持原样
+func WriteFileAtomic(path string, data []byte, perm os.FileMode) error {
+	return WriteAtomic(path, perm, func(w io.Writer) error {
+		_, err := io.Copy(w, bytes.NewReader(data))
+		return err
+	})
+}
+
+// 原子写入文件，内容由write回调写入，适合大文件
+// 与ioutil.WriteFile一样，perm会去掉umask中的权限
+func WriteAtomic(path string, perm os.FileMode, write func(w io.Writer) error) (err error) {
+	dir, base := filepath.Split(path)
+	if dir == "" {
+		dir = "."
+	}
+	if err = os.MkdirAll(dir, DIR_MODE); err != nil {
+		return
+	}
+	fp, err := createTemp(dir, "."+base+".tmp", perm)
+	if err != nil {
+		return
+	}
+	tmp := fp.Name()
+	defer func() {
+		if err != nil {
+			fp.Close()
+			os.Remove(tmp)
+		}
+	}()
+	if err = write(fp); err != nil {
+		return
+	}
+	if err = fp.Sync(); err != nil {
+		return
+	}
+	if err = fp.Close(); err != nil {
+		return
+	}
+	if err = os.Rename(tmp, path); err != nil {
+		return
+	}
+	return syncDir(dir)
+}
+
+// 新建临时文件，与ioutil.TempFile不同，创建时就使用perm，由系统应用umask
+func createTemp(dir, prefix string, perm os.FileMode) (*os.File, error) {
+	seed := uint32(time.Now().UnixNano()) + uint32(os.Getpid())
+	for i := 0; i < 10000; i++ {
+		seed = seed*1664525 + 1013904223
+		name := filepath.Join(dir, prefix+strconv.FormatUint(uint64(seed), 10))
+		fp, err := os.OpenFile(name, os.O_RDWR|os.O_CREATE|os.O_EXCL, perm)
+		if !os.IsExist(err) {
+			return fp, err
+		}
+	}
+	return nil, &os.PathError{Op: "createtemp", Path: filepath.Join(dir, prefix+"*"), Err: os.ErrExist}
+}
@@ -0,0 +1,43 @@
+package filesystem
+
+import (
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWriteFileAtomic(t *testing.T) {
+	tmp, _ := ioutil.TempDir("", "atomic")
+	defer os.RemoveAll(tmp)
+	fname := filepath.Join(tmp, "conf", "app.json")
+	assert.NoError(t, WriteFileAtomic(fname, []byte(`{"a":1}`), 0600))
+	data, _ := ioutil.ReadFile(fname)
+	assert.Equal(t, `{"a":1}`, string(data))
+	info, _ := os.Stat(fname)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+
+	// 与ioutil.WriteFile一样应用umask
+	plain := filepath.Join(tmp, "plain.json")
+	ioutil.WriteFile(plain, nil, FILE_MODE)
+	assert.NoError(t, WriteFileAtomic(fname, []byte(`{"a":1}`), FILE_MODE))
+	expect, _ := os.Stat(plain)
+	info, _ = os.Stat(fname)
+	assert.Equal(t, expect.Mode().Perm(), info.Mode().Perm())
+	os.Remove(plain)
+
+	// 写入失败时原文件不变，也不留下临时文件
+	err := WriteAtomic(fname, 0600, func(w io.Writer) error {
+		w.Write([]byte(`{"a":`))
+		return errors.New("crash")
+	})
+	assert.EqualError(t, err, "crash")
+	data, _ = ioutil.ReadFile(fname)
+	assert.Equal(t, `{"a":1}`, string(data))
+	files, _ := ioutil.ReadDir(filepath.Dir(fname))
+	assert.Len(t, files, 1)
+}
@@ -0,0 +1,18 @@
+//go:build !windows
+// +build !windows
+
+package filesystem
+
+import (
+	"os"
+)
+
+// 同步目录，确保改名已落盘
+func syncDir(dir string) error {
+	fp, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer fp.Close()
+	return fp.Sync()
+}
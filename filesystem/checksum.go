@@ -0,0 +1,208 @@
+package filesystem
+
+import (
+	"bytes"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"io"
+	"io/fs"
+	"os"
+	"sort"
+
+	"github.com/cespare/xxhash/v2"
+)
+
+// 校验算法
+const (
+	HASH_MD5    = "md5"
+	HASH_SHA1   = "sha1"
+	HASH_SHA256 = "sha256"
+	HASH_XXHASH = "xxhash" // xxHash64，速度快，不能防篡改
+	HASH_CRC32  = "crc32"
+)
+
+// 创建校验算法
+func NewHasher(algo string) (hash.Hash, error) {
+	switch algo {
+	case HASH_MD5:
+		return md5.New(), nil
+	case HASH_SHA1:
+		return sha1.New(), nil
+	case HASH_SHA256:
+		return sha256.New(), nil
+	case HASH_XXHASH:
+		return xxhash.New(), nil
+	case HASH_CRC32:
+		return crc32.NewIEEE(), nil
+	}
+	return nil, fmt.Errorf("unknown hash algorithm %s", algo)
+}
+
+// 流式计算校验码，limit大于0时只读取开头的limit字节
+func HashReader(r io.Reader, algo string, limit int64) ([]byte, error) {
+	h, err := NewHasher(algo)
+	if err != nil {
+		return nil, err
+	}
+	if limit > 0 {
+		r = io.LimitReader(r, limit)
+	}
+	if _, err = io.Copy(h, r); err != nil {
+		return nil, err
+	}
+	return h.Sum(nil), nil
+}
+
+// 文件的校验码
+func HashFileFS(fsys fs.FS, name, algo string) ([]byte, error) {
+	fp, err := fsys.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer fp.Close()
+	return HashReader(fp, algo, 0)
+}
+
+// 文件的校验码，十六进制
+func Checksum(path, algo string) (string, error) {
+	sum, err := HashFileFS(OS, path, algo)
+	return hex.EncodeToString(sum), err
+}
+
+// Merkle树的节点，目录的校验码由下级的名称和校验码计算
+type TreeNode struct {
+	Name     string
+	Path     string
+	Mode     fs.FileMode
+	Size     int64 // 目录为全部下级文件的大小之和
+	Hash     []byte
+	Children []*TreeNode // 按名称排序
+}
+
+func (n *TreeNode) Hex() string {
+	return hex.EncodeToString(n.Hash)
+}
+
+// 整个目录树的校验码，文件和目录改名、内容改变都会改变根的校验码
+func HashTreeFS(fsys fs.FS, root, algo string) (*TreeNode, error) {
+	if _, err := NewHasher(algo); err != nil {
+		return nil, err
+	}
+	info, err := fs.Stat(fsys, root)
+	if err != nil {
+		return nil, err
+	}
+	return hashNode(fsys, root, info.Name(), info.Mode(), algo)
+}
+
+// 本机目录树的校验码
+func HashTree(root, algo string) (*TreeNode, error) {
+	return HashTreeFS(OS, root, algo)
+}
+
+// 目录树的校验码，十六进制
+func TreeChecksum(root, algo string) (string, error) {
+	node, err := HashTree(root, algo)
+	if err != nil {
+		return "", err
+	}
+	return node.Hex(), nil
+}
+
+func hashNode(fsys fs.FS, name, base string, mode fs.FileMode, algo string) (*TreeNode, error) {
+	node := &TreeNode{Name: base, Path: name, Mode: mode}
+	h, _ := NewHasher(algo)
+	switch {
+	case mode&fs.ModeSymlink != 0:
+		// 只有本机文件系统能读取链接，内容为链接目标
+		f, ok := fsys.(*OsFS)
+		if !ok {
+			return nil, nil
+		}
+		fpath, err := f.path("readlink", name)
+		if err != nil {
+			return nil, err
+		}
+		target, err := os.Readlink(fpath)
+		if err != nil {
+			return nil, err
+		}
+		h.Write([]byte("l" + target))
+	case mode.IsDir():
+		entries, err := fs.ReadDir(fsys, name)
+		if err != nil {
+			return nil, err
+		}
+		h.Write([]byte("d"))
+		for _, entry := range entries {
+			child, err := hashNode(fsys, joinPath(fsys, name, entry.Name()),
+				entry.Name(), entry.Type(), algo)
+			if err != nil {
+				return nil, err
+			} else if child == nil {
+				continue
+			}
+			node.Size += child.Size
+			node.Children = append(node.Children, child)
+			fmt.Fprintf(h, "%s\x00%x\x00", child.Name, child.Hash)
+		}
+	case mode.IsRegular():
+		fp, err := fsys.Open(name)
+		if err != nil {
+			return nil, err
+		}
+		defer fp.Close()
+		h.Write([]byte("f"))
+		if node.Size, err = io.Copy(h, fp); err != nil {
+			return nil, err
+		}
+	default: // 设备、管道等不计算
+		return nil, nil
+	}
+	node.Hash = h.Sum(nil)
+	return node, nil
+}
+
+// 比较两棵树，返回内容不同或只在一边存在的相对路径
+func DiffTrees(a, b *TreeNode) []string {
+	var result []string
+	diffNodes(a, b, "", &result)
+	return result
+}
+
+func diffNodes(a, b *TreeNode, rel string, result *[]string) {
+	if a != nil && b != nil && bytes.Equal(a.Hash, b.Hash) {
+		return
+	}
+	if a == nil || b == nil || !a.Mode.IsDir() || !b.Mode.IsDir() {
+		*result = append(*result, rel)
+		return
+	}
+	children := make(map[string][2]*TreeNode)
+	for _, c := range a.Children {
+		children[c.Name] = [2]*TreeNode{c, nil}
+	}
+	for _, c := range b.Children {
+		pair := children[c.Name]
+		pair[1] = c
+		children[c.Name] = pair
+	}
+	names := make([]string, 0, len(children))
+	for name := range children {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		sub := name
+		if rel != "" {
+			sub = rel + "/" + name
+		}
+		pair := children[name]
+		diffNodes(pair[0], pair[1], sub, result)
+	}
+}
@@ -0,0 +1,91 @@
+package filesystem
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestChecksum(t *testing.T) {
+	tmp, _ := ioutil.TempDir("", "checksum")
+	defer os.RemoveAll(tmp)
+	fname := filepath.Join(tmp, "abc.txt")
+	ioutil.WriteFile(fname, []byte("abc"), FILE_MODE)
+	sums := map[string]string{
+		HASH_MD5:    "900150983cd24fb0d6963f7d28e17f72",
+		HASH_SHA1:   "a9993e364706816aba3e25717850c26c9cd0d89d",
+		HASH_SHA256: "ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad",
+		HASH_XXHASH: "44bc2cf5ad770999",
+		HASH_CRC32:  "352441c2",
+	}
+	for algo, want := range sums {
+		sum, err := Checksum(fname, algo)
+		assert.NoError(t, err)
+		assert.Equal(t, want, sum, algo)
+	}
+	_, err := Checksum(fname, "md4")
+	assert.Error(t, err)
+}
+
+func TestHashTree(t *testing.T) {
+	tmp, _ := ioutil.TempDir("", "tree")
+	defer os.RemoveAll(tmp)
+	a, b := filepath.Join(tmp, "a"), filepath.Join(tmp, "b")
+	files := map[string]string{"x.txt": "x", "sub/y.txt": "y", "sub/deep/z.txt": "z"}
+	makeTree(t, a, files)
+	makeTree(t, b, files)
+	ta, err := HashTree(a, HASH_SHA256)
+	assert.NoError(t, err)
+	tb, _ := HashTree(b, HASH_SHA256)
+	assert.Equal(t, ta.Hex(), tb.Hex())
+	assert.Equal(t, int64(3), ta.Size)
+	assert.Empty(t, DiffTrees(ta, tb))
+
+	// 内容改变、新增和改名都能发现
+	makeTree(t, b, map[string]string{"sub/deep/z.txt": "changed", "new.txt": "n"})
+	os.Rename(filepath.Join(b, "x.txt"), filepath.Join(b, "x2.txt"))
+	tb, _ = HashTree(b, HASH_SHA256)
+	assert.NotEqual(t, ta.Hex(), tb.Hex())
+	assert.Equal(t, []string{"new.txt", "sub/deep/z.txt", "x.txt", "x2.txt"}, DiffTrees(ta, tb))
+
+	sum, err := TreeChecksum(a, HASH_XXHASH)
+	assert.NoError(t, err)
+	assert.Len(t, sum, 16)
+	m := NewMemFS()
+	for name, content := range files {
+		m.MkdirAll(filepath.Dir(name), DIR_MODE)
+		WriteFileFS(m, name, []byte(content))
+	}
+	tm, err := HashTreeFS(m, ".", HASH_SHA256)
+	assert.NoError(t, err)
+	assert.Equal(t, ta.Hex(), tm.Hex())
+}
+
+func TestFindDuplicates(t *testing.T) {
+	tmp, _ := ioutil.TempDir("", "dupes")
+	defer os.RemoveAll(tmp)
+	big := strings.Repeat("0123456789", 1000)
+	makeTree(t, tmp, map[string]string{
+		"a/1.txt": "same", "b/2.txt": "same", "b/3.txt": "diff",
+		"a/big1": big + "x", "b/big2": big + "x", "b/big3": big + "y",
+		"a/empty": "", "b/empty": "",
+	})
+	groups, err := FindDuplicates([]string{filepath.Join(tmp, "a"), filepath.Join(tmp, "b")}, nil)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Len(t, groups, 2)
+	assert.Equal(t, int64(10001), groups[0].Size)
+	assert.Equal(t, []string{filepath.Join(tmp, "a", "big1"), filepath.Join(tmp, "b", "big2")}, groups[0].Paths)
+	assert.Equal(t, []string{filepath.Join(tmp, "a", "1.txt"), filepath.Join(tmp, "b", "2.txt")}, groups[1].Paths)
+
+	// 同一目录重复出现不算重复文件
+	groups, err = FindDuplicates([]string{tmp, tmp}, &DupOptions{MinSize: 5000, Algo: HASH_MD5})
+	assert.NoError(t, err)
+	assert.Len(t, groups, 1)
+	assert.Len(t, groups[0].Paths, 2)
+}
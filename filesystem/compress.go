@@ -0,0 +1,209 @@
+package filesystem
+
+import (
+	"bufio"
+	"bytes"
+	"compress/bzip2"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// 压缩格式
+const (
+	COMPRESS_NONE  = ""
+	COMPRESS_GZIP  = "gzip"
+	COMPRESS_ZSTD  = "zstd"
+	COMPRESS_BZIP2 = "bzip2" // 只能读取
+)
+
+var compressMagics = []struct {
+	kind  string
+	magic []byte
+}{
+	{COMPRESS_GZIP, []byte{0x1f, 0x8b}},
+	{COMPRESS_ZSTD, []byte{0x28, 0xb5, 0x2f, 0xfd}},
+}
+
+// 判断压缩格式需要的开头字节数
+const COMPRESS_HEAD_SIZE = 10
+
+// bzip2的块开始和流结束标记，紧跟在 "BZh" 和块大小1~9之后
+var (
+	bzip2BlockMagic = []byte{0x31, 0x41, 0x59, 0x26, 0x53, 0x59}
+	bzip2EndMagic   = []byte{0x17, 0x72, 0x45, 0x38, 0x50, 0x90}
+)
+
+// 根据开头的字节判断压缩格式，需要COMPRESS_HEAD_SIZE个字节才能识别bzip2
+func DetectCompression(head []byte) string {
+	for _, m := range compressMagics {
+		if bytes.HasPrefix(head, m.magic) {
+			return m.kind
+		}
+	}
+	// 只有 "BZh" 三个字节时普通文本也可能匹配，还要检查块大小和之后的标记
+	if len(head) >= COMPRESS_HEAD_SIZE && bytes.HasPrefix(head, []byte("BZh")) &&
+		head[3] >= '1' && head[3] <= '9' {
+		if mark := head[4:COMPRESS_HEAD_SIZE]; bytes.Equal(mark, bzip2BlockMagic) ||
+			bytes.Equal(mark, bzip2EndMagic) {
+			return COMPRESS_BZIP2
+		}
+	}
+	return COMPRESS_NONE
+}
+
+// 根据扩展名判断压缩格式
+func CompressionByExt(path string) string {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".gz", ".gzip":
+		return COMPRESS_GZIP
+	case ".zst", ".zstd":
+		return COMPRESS_ZSTD
+	case ".bz2":
+		return COMPRESS_BZIP2
+	}
+	return COMPRESS_NONE
+}
+
+// 关闭时依次关闭解压和底层的文件
+type multiCloser struct {
+	io.Reader
+	closers []func() error
+}
+
+func (c *multiCloser) Close() (err error) {
+	for _, close := range c.closers {
+		if e := close(); err == nil {
+			err = e
+		}
+	}
+	return
+}
+
+// 按开头的字节自动解压，返回压缩格式，未压缩时原样读取
+func NewDecompressReader(r io.Reader) (io.ReadCloser, string, error) {
+	br := bufio.NewReader(r)
+	head, _ := br.Peek(COMPRESS_HEAD_SIZE)
+	kind := DetectCompression(head)
+	rc := &multiCloser{}
+	if c, ok := r.(io.Closer); ok {
+		rc.closers = append(rc.closers, c.Close)
+	}
+	switch kind {
+	case COMPRESS_GZIP:
+		zr, err := gzip.NewReader(br)
+		if err != nil {
+			return nil, kind, err
+		}
+		rc.Reader = zr
+		rc.closers = append([]func() error{zr.Close}, rc.closers...)
+	case COMPRESS_ZSTD:
+		zr, err := zstd.NewReader(br, zstd.WithDecoderConcurrency(1))
+		if err != nil {
+			return nil, kind, err
+		}
+		rc.Reader = zr
+		rc.closers = append([]func() error{func() error {
+			zr.Close()
+			return nil
+		}}, rc.closers...)
+	case COMPRESS_BZIP2:
+		rc.Reader = bzip2.NewReader(br)
+	default:
+		rc.Reader = br
+	}
+	return rc, kind, nil
+}
+
+// 打开文件，压缩的文件自动解压
+func OpenReader(path string) (io.ReadCloser, string, error) {
+	fp, err := os.Open(path)
+	if err != nil {
+		return nil, COMPRESS_NONE, err
+	}
+	rc, kind, err := NewDecompressReader(fp)
+	if err != nil {
+		fp.Close()
+	}
+	return rc, kind, err
+}
+
+func checkWritable(kind string) error {
+	switch kind {
+	case COMPRESS_NONE, COMPRESS_GZIP, COMPRESS_ZSTD:
+		return nil
+	}
+	return fmt.Errorf("can not write %s", kind)
+}
+
+// 压缩写入，kind为空时原样写入，关闭时不关闭w
+func NewCompressWriter(w io.Writer, kind string) (io.WriteCloser, error) {
+	if err := checkWritable(kind); err != nil {
+		return nil, err
+	}
+	switch kind {
+	case COMPRESS_GZIP:
+		return gzip.NewWriter(w), nil
+	case COMPRESS_ZSTD:
+		return zstd.NewWriter(w)
+	}
+	return nopWriteCloser{w}, nil
+}
+
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error {
+	return nil
+}
+
+type compressFile struct {
+	io.WriteCloser
+	fp *os.File
+}
+
+func (f *compressFile) Close() error {
+	err := f.WriteCloser.Close()
+	if e := f.fp.Close(); err == nil {
+		err = e
+	}
+	return err
+}
+
+// 创建文件并压缩写入，kind为空时按扩展名判断，关闭时同时关闭文件
+func CreateWriter(path, kind string) (io.WriteCloser, error) {
+	if kind == COMPRESS_NONE {
+		kind = CompressionByExt(path)
+	}
+	if err := checkWritable(kind); err != nil {
+		return nil, err
+	}
+	fp, err := CreateFile(path)
+	if err != nil {
+		return nil, err
+	}
+	w, err := NewCompressWriter(fp, kind)
+	if err != nil {
+		fp.Close()
+		return nil, err
+	}
+	return &compressFile{WriteCloser: w, fp: fp}, nil
+}
+
+// 文件是否压缩
+func IsCompressed(path string) bool {
+	fp, err := os.Open(path)
+	if err != nil {
+		return false
+	}
+	defer fp.Close()
+	head := make([]byte, COMPRESS_HEAD_SIZE)
+	n, _ := io.ReadFull(fp, head)
+	return DetectCompression(head[:n]) != COMPRESS_NONE
+}
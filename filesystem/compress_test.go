@@ -0,0 +1,98 @@
+package filesystem
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// bzip2压缩的 "hello\nworld\n"
+var bzip2Data = []byte{
+	0x42, 0x5a, 0x68, 0x39, 0x31, 0x41, 0x59, 0x26, 0x53, 0x59, 0x6b, 0x5f, 0xb1, 0xdd, 0x00, 0x00,
+	0x02, 0x41, 0x80, 0x00, 0x10, 0x06, 0x44, 0x90, 0x80, 0x20, 0x00, 0x31, 0x0c, 0x08, 0x21, 0xa3,
+	0x69, 0x08, 0x07, 0x23, 0xae, 0x87, 0x8b, 0xb9, 0x22, 0x9c, 0x28, 0x48, 0x35, 0xaf, 0xd8, 0xee,
+	0x80,
+}
+
+func TestCompressedFiles(t *testing.T) {
+	tmp, _ := ioutil.TempDir("", "compress")
+	defer os.RemoveAll(tmp)
+	var lines []string
+	for i := 0; i < 1000; i++ {
+		lines = append(lines, fmt.Sprintf("line %d", i))
+	}
+	content := strings.Join(lines, "\n") + "\n"
+
+	for _, name := range []string{"a.log.gz", "a.log.zst", "a.log"} {
+		fname := filepath.Join(tmp, name)
+		w, err := CreateWriter(fname, "")
+		if !assert.NoError(t, err) {
+			continue
+		}
+		io.WriteString(w, content)
+		assert.NoError(t, w.Close())
+		assert.Equal(t, name != "a.log", IsCompressed(fname), name)
+
+		r := NewLineReader(fname)
+		var got []string
+		for r.Reading() {
+			got = append(got, r.Text())
+		}
+		assert.NoError(t, r.Err())
+		assert.NoError(t, r.Close())
+		assert.Equal(t, lines, got, name)
+		got, err = ReadLines(fname)
+		assert.NoError(t, err)
+		assert.Len(t, got, 1000)
+		assert.Equal(t, 1000, LineCount(fname))
+		tail, err := ReadFileTail(fname, 9)
+		assert.NoError(t, err)
+		assert.Equal(t, "line 999\n", string(tail), name)
+		got, err = TailLines(fname, 2)
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"line 998", "line 999"}, got, name)
+	}
+
+	fname := filepath.Join(tmp, "b.txt.bz2")
+	ioutil.WriteFile(fname, bzip2Data, FILE_MODE)
+	rd, kind, err := OpenReader(fname)
+	if assert.NoError(t, err) {
+		data, _ := ioutil.ReadAll(rd)
+		assert.Equal(t, COMPRESS_BZIP2, kind)
+		assert.Equal(t, "hello\nworld\n", string(data))
+		rd.Close()
+	}
+	// 不支持的格式不会破坏已有的文件
+	_, err = CreateWriter(fname, COMPRESS_BZIP2)
+	assert.Error(t, err)
+	size, _ := FileSize(fname)
+	assert.Equal(t, int64(len(bzip2Data)), size)
+
+	// 空的bzip2文件只有流结束标记
+	empty := []byte{'B', 'Z', 'h', '9', 0x17, 0x72, 0x45, 0x38, 0x50, 0x90, 0, 0, 0, 0}
+	assert.Equal(t, COMPRESS_BZIP2, DetectCompression(empty))
+
+	// 以 "BZh" 开头的普通文本不是bzip2
+	fname = filepath.Join(tmp, "notes.txt")
+	ioutil.WriteFile(fname, []byte("BZh is a prefix\nsecond line\n"), FILE_MODE)
+	assert.False(t, IsCompressed(fname))
+	lines, err = ReadLines(fname)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"BZh is a prefix", "second line"}, lines)
+	assert.Equal(t, 2, LineCount(fname))
+	tail, err := ReadFileTail(fname, 12)
+	assert.NoError(t, err)
+	assert.Equal(t, "second line\n", string(tail))
+	assert.Equal(t, COMPRESS_NONE, DetectCompression([]byte("BZh9")))
+
+	r := NewLineReader(filepath.Join(tmp, "missing.gz"))
+	assert.False(t, r.Reading())
+	assert.Error(t, r.Err())
+	assert.Error(t, r.Close())
+}
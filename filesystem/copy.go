@@ -0,0 +1,397 @@
+package filesystem
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// 判断文件是否改变的方式
+const (
+	COMPARE_NONE      = iota // 总是复制
+	COMPARE_SIZE_TIME        // 大小和修改时间都相同时跳过
+	COMPARE_HASH             // 大小和sha256都相同时跳过
+)
+
+// 复制中的动作
+const (
+	ACTION_MKDIR  = "mkdir"
+	ACTION_COPY   = "copy"
+	ACTION_LINK   = "link"
+	ACTION_SKIP   = "skip"
+	ACTION_DELETE = "delete"
+)
+
+var (
+	errNotDir   = errors.New("not a directory")
+	errIsDir    = errors.New("is a directory")
+	errNotEmpty = errors.New("directory not empty")
+)
+
+// 复制目录的选项
+// 规则见 MatchGlob，含斜杠时匹配相对路径，否则匹配文件名
+type CopyOptions struct {
+	Include  []string // 只复制匹配的文件，为空时复制全部
+	Exclude  []string // 跳过匹配的文件和目录
+	Compare  int      // COMPARE_NONE, COMPARE_SIZE_TIME 或 COMPARE_HASH
+	Delete   bool     // 删除目标中多出来的文件和目录，被排除的不删除
+	Workers  int      // 并发复制的文件数，默认为CPU数
+	Progress func(p Progress)
+}
+
+// 进度，Files 和 Bytes 为累计值
+type Progress struct {
+	Path   string // 相对路径
+	Action string
+	Size   int64
+	Files  int
+	Bytes  int64
+}
+
+// 复制结果
+type CopyStats struct {
+	Dirs, Files, Links, Skipped, Deleted int
+	Bytes                                int64
+}
+
+// 匹配包含或排除规则
+func MatchAny(patterns []string, rel string) bool {
+	rel = filepath.ToSlash(rel)
+	base := path.Base(rel)
+	for _, pat := range patterns {
+		name := base
+		if strings.Contains(pat, "/") {
+			name = rel
+		}
+		if MatchGlob(pat, name) {
+			return true
+		}
+	}
+	return false
+}
+
+type copyJob struct {
+	rel  string
+	info os.FileInfo
+}
+
+type treeCopier struct {
+	src, dst string
+	opts     CopyOptions
+	stats    CopyStats
+	seen     map[string]bool
+	dirs     []copyJob
+	err      error
+	mutex    sync.Mutex
+}
+
+// 复制整个目录，保留权限、修改时间和符号链接
+func CopyTree(src, dst string, opts *CopyOptions) (*CopyStats, error) {
+	c := &treeCopier{src: filepath.Clean(src), dst: filepath.Clean(dst)}
+	if opts != nil {
+		c.opts = *opts
+	}
+	return c.run()
+}
+
+// 同步目录，跳过大小和修改时间未变的文件，并删除目标中多出来的
+func SyncTree(src, dst string, opts *CopyOptions) (*CopyStats, error) {
+	var o CopyOptions
+	if opts != nil {
+		o = *opts
+	}
+	if o.Compare == COMPARE_NONE {
+		o.Compare = COMPARE_SIZE_TIME
+	}
+	o.Delete = true
+	return CopyTree(src, dst, &o)
+}
+
+func (c *treeCopier) report(rel, action string, size int64) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	switch action {
+	case ACTION_MKDIR:
+		c.stats.Dirs++
+	case ACTION_COPY:
+		c.stats.Files++
+		c.stats.Bytes += size
+	case ACTION_LINK:
+		c.stats.Links++
+	case ACTION_SKIP:
+		c.stats.Skipped++
+	case ACTION_DELETE:
+		c.stats.Deleted++
+	}
+	if c.opts.Progress != nil {
+		c.opts.Progress(Progress{Path: rel, Action: action, Size: size,
+			Files: c.stats.Files, Bytes: c.stats.Bytes})
+	}
+}
+
+func (c *treeCopier) fail(err error) {
+	c.mutex.Lock()
+	if c.err == nil {
+		c.err = err
+	}
+	c.mutex.Unlock()
+}
+
+func (c *treeCopier) failed() bool {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.err != nil
+}
+
+func (c *treeCopier) run() (*CopyStats, error) {
+	info, err := os.Stat(c.src)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return nil, &os.PathError{Op: "copytree", Path: c.src, Err: errNotDir}
+	}
+	if c.opts.Delete {
+		c.seen = make(map[string]bool)
+	}
+	workers := c.opts.Workers
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+	jobs := make(chan copyJob, workers*4)
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for job := range jobs {
+				if !c.failed() {
+					if err := c.copyFile(job); err != nil {
+						c.fail(err)
+					}
+				}
+			}
+		}()
+	}
+	err = filepath.Walk(c.src, func(fpath string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if c.failed() {
+			return filepath.SkipDir
+		}
+		rel, _ := filepath.Rel(c.src, fpath)
+		if rel != "." && MatchAny(c.opts.Exclude, rel) {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if info.IsDir() {
+			c.markSeen(rel)
+			return c.makeDir(rel, info)
+		}
+		if len(c.opts.Include) > 0 && !MatchAny(c.opts.Include, rel) {
+			return nil
+		}
+		c.markSeen(rel)
+		jobs <- copyJob{rel: rel, info: info}
+		return nil
+	})
+	close(jobs)
+	wg.Wait()
+	if err != nil {
+		c.fail(err)
+	}
+	if c.err == nil && c.opts.Delete {
+		c.fail(c.deleteExtra())
+	}
+	// 目录内容复制完成后，由深到浅设置目录时间
+	for i := len(c.dirs) - 1; i >= 0 && c.err == nil; i-- {
+		d := c.dirs[i]
+		t := d.info.ModTime()
+		c.fail(os.Chtimes(filepath.Join(c.dst, d.rel), t, t))
+	}
+	return &c.stats, c.err
+}
+
+func (c *treeCopier) markSeen(rel string) {
+	if c.seen != nil {
+		c.seen[rel] = true
+	}
+}
+
+func (c *treeCopier) makeDir(rel string, info os.FileInfo) error {
+	target := filepath.Join(c.dst, rel)
+	c.dirs = append(c.dirs, copyJob{rel: rel, info: info})
+	if st, err := os.Lstat(target); err == nil {
+		if st.IsDir() {
+			return os.Chmod(target, info.Mode().Perm())
+		}
+		if err = os.Remove(target); err != nil {
+			return err
+		}
+	}
+	if err := os.MkdirAll(target, info.Mode().Perm()|0700); err != nil {
+		return err
+	}
+	c.report(rel, ACTION_MKDIR, 0)
+	return os.Chmod(target, info.Mode().Perm())
+}
+
+func (c *treeCopier) copyFile(job copyJob) error {
+	src, dst := filepath.Join(c.src, job.rel), filepath.Join(c.dst, job.rel)
+	mode := job.info.Mode()
+	if mode&os.ModeSymlink != 0 {
+		return c.copyLink(job.rel, src, dst)
+	}
+	if !mode.IsRegular() { // 设备、管道等特殊文件不复制
+		return nil
+	}
+	if st, err := os.Lstat(dst); err == nil {
+		if c.unchanged(src, dst, job.info, st) {
+			c.report(job.rel, ACTION_SKIP, job.info.Size())
+			return nil
+		}
+		if !st.Mode().IsRegular() {
+			if err = os.RemoveAll(dst); err != nil {
+				return err
+			}
+		}
+	}
+	if err := copyContents(src, dst, mode.Perm()); err != nil {
+		return err
+	}
+	t := job.info.ModTime()
+	if err := os.Chtimes(dst, t, t); err != nil {
+		return err
+	}
+	c.report(job.rel, ACTION_COPY, job.info.Size())
+	return nil
+}
+
+func (c *treeCopier) copyLink(rel, src, dst string) error {
+	link, err := os.Readlink(src)
+	if err != nil {
+		return err
+	}
+	if old, err := os.Readlink(dst); err == nil && old == link {
+		c.report(rel, ACTION_SKIP, 0)
+		return nil
+	}
+	if _, err = os.Lstat(dst); err == nil {
+		if err = os.RemoveAll(dst); err != nil {
+			return err
+		}
+	}
+	if err = os.Symlink(link, dst); err != nil {
+		return err
+	}
+	c.report(rel, ACTION_LINK, 0)
+	return nil
+}
+
+func (c *treeCopier) unchanged(src, dst string, si, di os.FileInfo) bool {
+	if !di.Mode().IsRegular() || si.Size() != di.Size() {
+		return false
+	}
+	switch c.opts.Compare {
+	case COMPARE_SIZE_TIME:
+		return si.ModTime().Equal(di.ModTime())
+	case COMPARE_HASH:
+		a, err := fileSha256(src)
+		if err != nil {
+			return false
+		}
+		b, err := fileSha256(dst)
+		return err == nil && bytes.Equal(a, b)
+	}
+	return false
+}
+
+// 删除目标中多出来的文件和目录
+func (c *treeCopier) deleteExtra() error {
+	var extra []string
+	err := filepath.Walk(c.dst, func(fpath string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, _ := filepath.Rel(c.dst, fpath)
+		if rel == "." || c.seen[rel] {
+			return nil
+		}
+		if MatchAny(c.opts.Exclude, rel) {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		include := len(c.opts.Include) > 0
+		if info.IsDir() {
+			// 有Include时目录中可能有不在同步范围内的文件，只删除其中匹配的
+			if include {
+				return nil
+			}
+			extra = append(extra, rel)
+			return filepath.SkipDir
+		}
+		// 不匹配Include的文件不归同步管理，不删除
+		if !include || MatchAny(c.opts.Include, rel) {
+			extra = append(extra, rel)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	sort.Strings(extra)
+	for _, rel := range extra {
+		if err = os.RemoveAll(filepath.Join(c.dst, rel)); err != nil {
+			return err
+		}
+		c.report(rel, ACTION_DELETE, 0)
+	}
+	return nil
+}
+
+func copyContents(src, dst string, perm os.FileMode) (err error) {
+	in, err := os.Open(src)
+	if err != nil {
+		return
+	}
+	defer in.Close()
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, perm)
+	if err != nil {
+		return
+	}
+	defer func() {
+		if cerr := out.Close(); err == nil {
+			err = cerr
+		}
+	}()
+	if _, err = io.Copy(out, in); err != nil {
+		return
+	}
+	return out.Chmod(perm) // 已存在的文件不会按perm创建
+}
+
+func fileSha256(fname string) ([]byte, error) {
+	fp, err := os.Open(fname)
+	if err != nil {
+		return nil, err
+	}
+	defer fp.Close()
+	h := sha256.New()
+	if _, err = io.Copy(h, fp); err != nil {
+		return nil, err
+	}
+	return h.Sum(nil), nil
+}
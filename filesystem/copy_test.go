@@ -0,0 +1,121 @@
+package filesystem
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func makeTree(t *testing.T, root string, files map[string]string) {
+	for name, content := range files {
+		fname := filepath.Join(root, name)
+		os.MkdirAll(filepath.Dir(fname), DIR_MODE)
+		assert.NoError(t, ioutil.WriteFile(fname, []byte(content), 0640))
+	}
+}
+
+func TestCopyTree(t *testing.T) {
+	tmp, _ := ioutil.TempDir("", "copytree")
+	defer os.RemoveAll(tmp)
+	src, dst := filepath.Join(tmp, "src"), filepath.Join(tmp, "dst")
+	makeTree(t, src, map[string]string{
+		"a.txt": "hello", "run.sh": "#!/bin/sh", "sub/b.log": "log",
+		"sub/deep/c.log": "deep", ".git/HEAD": "ref", "tmp/x.txt": "x",
+	})
+	os.Chmod(filepath.Join(src, "run.sh"), 0755)
+	os.Symlink("a.txt", filepath.Join(src, "link.txt"))
+	old := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	os.Chtimes(filepath.Join(src, "sub", "b.log"), old, old)
+
+	var events int32
+	opts := &CopyOptions{
+		Exclude:  []string{".git", "tmp/*"},
+		Workers:  2,
+		Progress: func(p Progress) { atomic.AddInt32(&events, 1) },
+	}
+	stats, err := CopyTree(src, dst, opts)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, 4, stats.Files)
+	assert.Equal(t, 1, stats.Links)
+	assert.Equal(t, int64(21), stats.Bytes)
+	assert.True(t, events > 0)
+
+	info, err := os.Stat(filepath.Join(dst, "run.sh"))
+	assert.NoError(t, err)
+	assert.Equal(t, os.FileMode(0755), info.Mode().Perm())
+	info, _ = os.Stat(filepath.Join(dst, "sub", "b.log"))
+	assert.True(t, info.ModTime().Equal(old))
+	link, err := os.Readlink(filepath.Join(dst, "link.txt"))
+	assert.NoError(t, err)
+	assert.Equal(t, "a.txt", link)
+	size, _ := FileSize(filepath.Join(dst, ".git", "HEAD"))
+	assert.Equal(t, int64(0), size)
+	size, exists := FileSize(filepath.Join(dst, "tmp"))
+	assert.True(t, exists && size < 0) // 目录保留，其中的文件被排除
+
+	// 同步时跳过未改变的文件，删除多出来的
+	makeTree(t, dst, map[string]string{"extra.txt": "extra", "old/z.txt": "z"})
+	makeTree(t, src, map[string]string{"a.txt": "changed"})
+	stats, err = SyncTree(src, dst, &CopyOptions{Exclude: []string{".git", "tmp"}})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, stats.Files)
+	assert.Equal(t, 4, stats.Skipped)
+	assert.Equal(t, 2, stats.Deleted) // extra.txt和old，tmp被排除不删除
+	size, _ = FileSize(filepath.Join(dst, "extra.txt"))
+	assert.Equal(t, int64(0), size)
+	_, exists = FileSize(filepath.Join(dst, "tmp"))
+	assert.True(t, exists)
+
+	// 按内容比较，修改时间不同也跳过
+	os.Chtimes(filepath.Join(dst, "sub", "b.log"), time.Now(), time.Now())
+	stats, err = CopyTree(src, dst, &CopyOptions{Compare: COMPARE_HASH, Include: []string{"*.log"}})
+	assert.NoError(t, err)
+	assert.Equal(t, 0, stats.Files)
+	assert.Equal(t, 2, stats.Skipped)
+
+	_, err = CopyTree(filepath.Join(src, "a.txt"), dst, nil)
+	assert.Error(t, err)
+}
+
+func TestSyncTreeInclude(t *testing.T) {
+	tmp, _ := ioutil.TempDir("", "synctree")
+	defer os.RemoveAll(tmp)
+	src, dst := filepath.Join(tmp, "src"), filepath.Join(tmp, "dst")
+	makeTree(t, src, map[string]string{"app.log": "a", "important.conf": "c"})
+	makeTree(t, dst, map[string]string{
+		"important.conf": "c", "local.conf": "l", "old.log": "o", "logs/x.log": "x", "logs/keep.txt": "k",
+	})
+	stats, err := SyncTree(src, dst, &CopyOptions{Include: []string{"*.log", "**/*.log"}, Delete: true})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, stats.Files)
+	assert.Equal(t, 2, stats.Deleted) // 只删除匹配Include的old.log和logs/x.log
+	for _, name := range []string{"important.conf", "local.conf", "logs/keep.txt", "app.log"} {
+		_, exists := FileSize(filepath.Join(dst, name))
+		assert.True(t, exists, name)
+	}
+	for _, name := range []string{"old.log", "logs/x.log"} {
+		_, exists := FileSize(filepath.Join(dst, name))
+		assert.False(t, exists, name)
+	}
+}
+
+func TestCopyDir(t *testing.T) {
+	tmp, _ := ioutil.TempDir("", "copydir")
+	defer os.RemoveAll(tmp)
+	src := filepath.Join(tmp, "src")
+	makeTree(t, src, map[string]string{"a/b.txt": "b"})
+	assert.NoError(t, CopyDir(src+"/", filepath.Join(tmp, "dst")))
+	size, _ := FileSize(filepath.Join(tmp, "dst", "a", "b.txt"))
+	assert.Equal(t, int64(1), size)
+	// 目标已存在时复制为子目录
+	assert.NoError(t, CopyDir(src, filepath.Join(tmp, "dst")))
+	size, _ = FileSize(filepath.Join(tmp, "dst", "src", "a", "b.txt"))
+	assert.Equal(t, int64(1), size)
+}
@@ -0,0 +1,593 @@
+package filesystem
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 落盘策略
+const (
+	SYNC_ALWAYS = iota // 每条消息都落盘，最安全也最慢
+	SYNC_BATCH         // 累积SyncEvery条或每隔SyncInterval落盘一次
+	SYNC_NONE          // 交给操作系统，进程崩溃不丢，断电可能丢
+)
+
+const (
+	SEGMENT_EXT        = ".seg"
+	OFFSET_EXT         = ".offset"
+	RECORD_HEADER_SIZE = 8 // 4字节长度 + 4字节CRC32
+)
+
+var (
+	ErrQueueClosed   = errors.New("disk queue is closed")
+	ErrQueueEmpty    = errors.New("disk queue is empty")
+	ErrQueueCorrupt  = errors.New("disk queue record is corrupt")
+	ErrMessageTooBig = errors.New("message is too big")
+)
+
+// 磁盘队列的选项
+type QueueOptions struct {
+	SegmentSize    int64 // 单个分段文件的大小，超过后轮转，默认64M
+	MaxMessageSize int   // 单条消息的最大长度，默认16M
+	SyncPolicy     int
+	SyncEvery      int           // SYNC_BATCH时累积多少条落盘
+	SyncInterval   time.Duration // SYNC_BATCH时定时落盘的间隔
+}
+
+// 一个分段文件，文件名是第一条消息的序号
+type segment struct {
+	base  int64
+	count int64
+	size  int64
+	path  string
+}
+
+func (s *segment) end() int64 {
+	return s.base + s.count
+}
+
+// 持久化的先进先出队列，由目录下只追加的分段文件组成
+// 每个消费者各自记录读到的位置，所有消费者都确认过的分段可以用Compact删除
+//
+//	q, _ := OpenDiskQueue("spool", nil)
+//	defer q.Close()
+//	q.Push([]byte("hello"))
+//	c, _ := q.Consumer("sender")
+//	if _, data, err := c.Next(); err == nil {
+//		send(data)
+//		c.Commit()
+//	}
+type DiskQueue struct {
+	Dir       string
+	opts      QueueOptions
+	segs      []*segment
+	active    *os.File
+	next      int64 // 下一条消息的序号
+	unsynced  int
+	consumers map[string]*QueueConsumer
+	notify    chan struct{}
+	quit      chan struct{}
+	closed    bool
+	mutex     sync.Mutex
+}
+
+// 打开或新建队列目录，最后一个分段末尾不完整的记录会被截掉
+func OpenDiskQueue(dir string, opts *QueueOptions) (*DiskQueue, error) {
+	q := &DiskQueue{
+		Dir: dir, consumers: make(map[string]*QueueConsumer),
+		notify: make(chan struct{}), quit: make(chan struct{}),
+	}
+	if opts != nil {
+		q.opts = *opts
+	}
+	if q.opts.SegmentSize <= 0 {
+		q.opts.SegmentSize = 64 * 1024 * 1024
+	}
+	if q.opts.MaxMessageSize <= 0 {
+		q.opts.MaxMessageSize = 16 * 1024 * 1024
+	}
+	if err := os.MkdirAll(dir, DIR_MODE); err != nil {
+		return nil, err
+	}
+	if err := q.load(); err != nil {
+		q.closeFiles()
+		return nil, err
+	}
+	if q.opts.SyncPolicy == SYNC_BATCH && q.opts.SyncInterval > 0 {
+		go q.syncLoop(q.opts.SyncInterval)
+	}
+	return q, nil
+}
+
+func (q *DiskQueue) segmentPath(base int64) string {
+	return filepath.Join(q.Dir, fmt.Sprintf("%020d%s", base, SEGMENT_EXT))
+}
+
+func (q *DiskQueue) load() error {
+	names, err := ioutil.ReadDir(q.Dir)
+	if err != nil {
+		return err
+	}
+	var offsets []string
+	for _, info := range names {
+		name := info.Name()
+		if strings.HasSuffix(name, OFFSET_EXT) {
+			offsets = append(offsets, strings.TrimSuffix(name, OFFSET_EXT))
+			continue
+		}
+		if !strings.HasSuffix(name, SEGMENT_EXT) {
+			continue
+		}
+		base, err := strconv.ParseInt(strings.TrimSuffix(name, SEGMENT_EXT), 10, 64)
+		if err != nil {
+			continue
+		}
+		seg := &segment{base: base, size: info.Size(), path: filepath.Join(q.Dir, name)}
+		q.segs = append(q.segs, seg)
+	}
+	sort.Slice(q.segs, func(i, j int) bool {
+		return q.segs[i].base < q.segs[j].base
+	})
+	for i := 0; i+1 < len(q.segs); i++ {
+		q.segs[i].count = q.segs[i+1].base - q.segs[i].base
+	}
+	if len(q.segs) == 0 {
+		err = q.rotate(0)
+	} else {
+		err = q.recover(q.segs[len(q.segs)-1])
+	}
+	if err != nil {
+		return err
+	}
+	for _, name := range offsets {
+		if _, err = q.consumer(name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// 扫描最后一个分段，统计消息数，截掉崩溃时没写完整的记录
+// 中间的记录损坏或超过MaxMessageSize时出错，不会删除后面的消息
+func (q *DiskQueue) recover(seg *segment) error {
+	fp, size, err := OpenFile(seg.path, false, true)
+	if err != nil {
+		return err
+	}
+	var pos int64
+	for pos < size {
+		n, err := q.readRecord(fp, pos, nil)
+		if err != nil {
+			if isTornRecord(fp, pos, size, err) {
+				break
+			}
+			fp.Close()
+			return fmt.Errorf("%s at %d: %w", seg.path, pos, err)
+		}
+		pos += RECORD_HEADER_SIZE + int64(n)
+		seg.count++
+	}
+	if pos < size {
+		if err = fp.Truncate(pos); err == nil {
+			err = fp.Sync()
+		}
+		if err != nil {
+			fp.Close()
+			return err
+		}
+	}
+	seg.size, q.active, q.next = pos, fp, seg.end()
+	return nil
+}
+
+// 是否为文件末尾没写完整的记录：超出了文件末尾，或者是最后一条且校验失败
+func isTornRecord(fp *os.File, pos, size int64, err error) bool {
+	if pos+RECORD_HEADER_SIZE > size {
+		return true
+	}
+	var head [4]byte
+	if _, err := fp.ReadAt(head[:], pos); err != nil {
+		return false
+	}
+	end := pos + RECORD_HEADER_SIZE + int64(binary.BigEndian.Uint32(head[:]))
+	return end > size || (end == size && err == ErrQueueCorrupt)
+}
+
+// 读取pos处的一条记录，buf不为nil时读出内容，返回内容长度
+func (q *DiskQueue) readRecord(fp *os.File, pos int64, buf *[]byte) (int, error) {
+	var head [RECORD_HEADER_SIZE]byte
+	if _, err := fp.ReadAt(head[:], pos); err != nil {
+		return 0, err
+	}
+	n := int(binary.BigEndian.Uint32(head[:4]))
+	if n > q.opts.MaxMessageSize {
+		return 0, ErrMessageTooBig
+	}
+	data := make([]byte, n)
+	if _, err := fp.ReadAt(data, pos+RECORD_HEADER_SIZE); err != nil {
+		if err == io.EOF {
+			err = ErrQueueCorrupt
+		}
+		return 0, err
+	}
+	if crc32.ChecksumIEEE(data) != binary.BigEndian.Uint32(head[4:]) {
+		return 0, ErrQueueCorrupt
+	}
+	if buf != nil {
+		*buf = data
+	}
+	return n, nil
+}
+
+// 新建分段文件作为当前写入的分段
+func (q *DiskQueue) rotate(base int64) error {
+	if q.active != nil {
+		if err := q.active.Sync(); err != nil {
+			return err
+		}
+		q.active.Close()
+		q.active, q.unsynced = nil, 0
+	}
+	seg := &segment{base: base, path: q.segmentPath(base)}
+	fp, _, err := OpenFile(seg.path, false, true)
+	if err != nil {
+		return err
+	}
+	q.segs = append(q.segs, seg)
+	q.active, q.next = fp, base
+	return syncDir(q.Dir)
+}
+
+// 追加一条消息，返回它的序号
+func (q *DiskQueue) Push(data []byte) (int64, error) {
+	if len(data) > q.opts.MaxMessageSize {
+		return -1, ErrMessageTooBig
+	}
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	if q.closed {
+		return -1, ErrQueueClosed
+	}
+	size := RECORD_HEADER_SIZE + int64(len(data))
+	seg := q.segs[len(q.segs)-1]
+	if seg.size > 0 && seg.size+size > q.opts.SegmentSize {
+		if err := q.rotate(q.next); err != nil {
+			return -1, err
+		}
+		seg = q.segs[len(q.segs)-1]
+	}
+	buf := make([]byte, size)
+	binary.BigEndian.PutUint32(buf[:4], uint32(len(data)))
+	binary.BigEndian.PutUint32(buf[4:8], crc32.ChecksumIEEE(data))
+	copy(buf[RECORD_HEADER_SIZE:], data)
+	if _, err := q.active.Write(buf); err != nil {
+		// 写了一半的记录要去掉，否则后面的消息都读不到
+		q.active.Truncate(seg.size)
+		return -1, err
+	}
+	seq := q.next
+	seg.size += size
+	seg.count++
+	q.next++
+	q.unsynced++
+	if q.opts.SyncPolicy == SYNC_ALWAYS ||
+		(q.opts.SyncPolicy == SYNC_BATCH && q.opts.SyncEvery > 0 && q.unsynced >= q.opts.SyncEvery) {
+		if err := q.sync(); err != nil {
+			return seq, err
+		}
+	}
+	// 唤醒等待新消息的消费者
+	close(q.notify)
+	q.notify = make(chan struct{})
+	return seq, nil
+}
+
+func (q *DiskQueue) sync() error {
+	if q.unsynced == 0 {
+		return nil
+	}
+	q.unsynced = 0
+	return q.active.Sync()
+}
+
+// 立即落盘
+func (q *DiskQueue) Sync() error {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	if q.closed {
+		return ErrQueueClosed
+	}
+	return q.sync()
+}
+
+func (q *DiskQueue) syncLoop(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-q.quit:
+			return
+		case <-ticker.C:
+			q.Sync()
+		}
+	}
+}
+
+// 有新消息时关闭的通道，先取通道再调用Next，才不会漏掉通知
+func (q *DiskQueue) Wait() <-chan struct{} {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	return q.notify
+}
+
+// 第一条和下一条消息的序号
+func (q *DiskQueue) Range() (first, next int64) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	return q.segs[0].base, q.next
+}
+
+// 还有多少消息没有被所有消费者确认
+func (q *DiskQueue) Len() int64 {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	return q.next - q.minOffset()
+}
+
+// 所有消费者都已确认的位置，没有消费者时不删除任何消息
+func (q *DiskQueue) minOffset() int64 {
+	if len(q.consumers) == 0 {
+		return q.segs[0].base
+	}
+	min := q.next
+	for _, c := range q.consumers {
+		if c.offset < min {
+			min = c.offset
+		}
+	}
+	return min
+}
+
+// 删除所有消费者都已确认的分段，正在写入的分段保留
+func (q *DiskQueue) Compact() (removed int, err error) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	if q.closed {
+		return 0, ErrQueueClosed
+	}
+	min := q.minOffset()
+	for len(q.segs) > 1 && q.segs[0].end() <= min {
+		seg := q.segs[0]
+		for _, c := range q.consumers {
+			if c.fp != nil && c.seg == seg {
+				c.closeSegment()
+			}
+		}
+		if err = os.Remove(seg.path); err != nil && !os.IsNotExist(err) {
+			return
+		}
+		q.segs = q.segs[1:]
+		removed++
+	}
+	if removed > 0 {
+		err = syncDir(q.Dir)
+	}
+	return
+}
+
+// 找到包含序号seq的分段
+func (q *DiskQueue) findSegment(seq int64) *segment {
+	i := sort.Search(len(q.segs), func(i int) bool {
+		return q.segs[i].end() > seq
+	})
+	if i < len(q.segs) && q.segs[i].base <= seq {
+		return q.segs[i]
+	}
+	return nil
+}
+
+// 获取或新建消费者，已确认的位置从同名的offset文件中恢复
+func (q *DiskQueue) Consumer(name string) (*QueueConsumer, error) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	if q.closed {
+		return nil, ErrQueueClosed
+	}
+	return q.consumer(name)
+}
+
+func (q *DiskQueue) consumer(name string) (*QueueConsumer, error) {
+	if c, ok := q.consumers[name]; ok {
+		return c, nil
+	}
+	if name == "" || strings.ContainsAny(name, `/\`) {
+		return nil, fmt.Errorf("invalid consumer name %q", name)
+	}
+	c := &QueueConsumer{Name: name, q: q, offset: q.segs[0].base}
+	c.path = filepath.Join(q.Dir, name+OFFSET_EXT)
+	if data, err := ioutil.ReadFile(c.path); err == nil {
+		offset, err := strconv.ParseInt(strings.TrimSpace(string(data)), 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("bad offset file %s: %w", c.path, err)
+		}
+		c.offset = offset
+	} else if !os.IsNotExist(err) {
+		return nil, err
+	}
+	if c.offset > q.next {
+		c.offset = q.next
+	}
+	c.pos = c.offset
+	q.consumers[name] = c
+	return c, nil
+}
+
+// 删除消费者和它的offset文件，它不再阻止Compact删除分段
+func (q *DiskQueue) RemoveConsumer(name string) error {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	c, ok := q.consumers[name]
+	if !ok {
+		return nil
+	}
+	c.closeSegment()
+	delete(q.consumers, name)
+	if err := os.Remove(c.path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+func (q *DiskQueue) closeFiles() {
+	for _, c := range q.consumers {
+		c.closeSegment()
+	}
+	if q.active != nil {
+		q.active.Close()
+		q.active = nil
+	}
+}
+
+// 落盘并关闭，消费者读到但未确认的消息下次会重新读到
+func (q *DiskQueue) Close() error {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	if q.closed {
+		return nil
+	}
+	q.closed = true
+	close(q.quit)
+	close(q.notify) // 让等待的消费者退出
+	err := q.sync()
+	q.closeFiles()
+	return err
+}
+
+// 队列的一个消费者，读取位置在内存中，Commit后才持久化
+type QueueConsumer struct {
+	Name   string
+	q      *DiskQueue
+	path   string
+	offset int64 // 已确认的位置
+	pos    int64 // 下一条要读的序号
+	seg    *segment
+	fp     *os.File
+	fpos   int64 // pos对应的记录在分段文件中的位置
+}
+
+func (c *QueueConsumer) closeSegment() {
+	if c.fp != nil {
+		c.fp.Close()
+		c.fp, c.seg = nil, nil
+	}
+}
+
+// 读取下一条消息，没有新消息时返回ErrQueueEmpty
+func (c *QueueConsumer) Next() (seq int64, data []byte, err error) {
+	q := c.q
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	if q.closed {
+		return -1, nil, ErrQueueClosed
+	}
+	if first := q.segs[0].base; c.pos < first {
+		c.pos = first // 已被删除的消息跳过
+	}
+	if c.pos >= q.next {
+		return -1, nil, ErrQueueEmpty
+	}
+	if err = c.seek(q.findSegment(c.pos)); err != nil {
+		return -1, nil, err
+	}
+	n, err := q.readRecord(c.fp, c.fpos, &data)
+	if err != nil {
+		if err == io.EOF {
+			err = ErrQueueCorrupt
+		}
+		return -1, nil, err
+	}
+	seq = c.pos
+	c.pos++
+	c.fpos += RECORD_HEADER_SIZE + int64(n)
+	return seq, data, nil
+}
+
+// 打开分段文件并定位到c.pos对应的记录
+func (c *QueueConsumer) seek(seg *segment) error {
+	if seg == nil {
+		return ErrQueueCorrupt
+	}
+	if c.seg == seg {
+		return nil
+	}
+	c.closeSegment()
+	fp, _, err := OpenFile(seg.path, true, false)
+	if err != nil {
+		return err
+	}
+	var pos int64
+	for i := seg.base; i < c.pos; i++ {
+		n, err := c.q.readRecord(fp, pos, nil)
+		if err != nil {
+			fp.Close()
+			return err
+		}
+		pos += RECORD_HEADER_SIZE + int64(n)
+	}
+	c.seg, c.fp, c.fpos = seg, fp, pos
+	return nil
+}
+
+// 确认已读到的所有消息，并保存位置
+func (c *QueueConsumer) Commit() error {
+	c.q.mutex.Lock()
+	defer c.q.mutex.Unlock()
+	if c.pos == c.offset {
+		return nil
+	}
+	data := []byte(strconv.FormatInt(c.pos, 10) + "\n")
+	if err := WriteFileAtomic(c.path, data, FILE_MODE); err != nil {
+		return err
+	}
+	c.offset = c.pos
+	return nil
+}
+
+// 回到上次确认的位置，未确认的消息会重新读到
+func (c *QueueConsumer) Rewind() {
+	c.q.mutex.Lock()
+	defer c.q.mutex.Unlock()
+	if c.pos != c.offset {
+		c.pos = c.offset
+		c.closeSegment()
+	}
+}
+
+// 已确认的位置和下一条要读的序号
+func (c *QueueConsumer) Offset() (committed, next int64) {
+	c.q.mutex.Lock()
+	defer c.q.mutex.Unlock()
+	return c.offset, c.pos
+}
+
+// 还有多少消息没有读
+func (c *QueueConsumer) Len() int64 {
+	c.q.mutex.Lock()
+	defer c.q.mutex.Unlock()
+	pos := c.pos
+	if first := c.q.segs[0].base; pos < first {
+		pos = first
+	}
+	return c.q.next - pos
+}
@@ -0,0 +1,166 @@
+package filesystem
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDiskQueue(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "dqueue")
+	defer os.RemoveAll(dir)
+	opts := &QueueOptions{SegmentSize: 100, SyncPolicy: SYNC_BATCH, SyncEvery: 3}
+	q, err := OpenDiskQueue(dir, opts)
+	if !assert.NoError(t, err) {
+		return
+	}
+	for i := 0; i < 20; i++ {
+		seq, err := q.Push([]byte(fmt.Sprintf("message-%02d", i)))
+		assert.NoError(t, err)
+		assert.Equal(t, int64(i), seq)
+	}
+	segs, _ := filepath.Glob(filepath.Join(dir, "*"+SEGMENT_EXT))
+	assert.True(t, len(segs) > 1, "segments should rotate")
+
+	c, err := q.Consumer("sender")
+	assert.NoError(t, err)
+	for i := 0; i < 5; i++ {
+		seq, data, err := c.Next()
+		assert.NoError(t, err)
+		assert.Equal(t, int64(i), seq)
+		assert.Equal(t, fmt.Sprintf("message-%02d", i), string(data))
+	}
+	assert.NoError(t, c.Commit())
+	c.Next()
+	c.Rewind() // 未确认的第6条会重新读到
+	assert.Equal(t, int64(15), c.Len())
+	assert.NoError(t, q.Close())
+	_, err = q.Push([]byte("x"))
+	assert.Equal(t, ErrQueueClosed, err)
+
+	// 模拟崩溃时写了一半的记录
+	last := segs[len(segs)-1]
+	fp, _ := os.OpenFile(last, os.O_WRONLY|os.O_APPEND, FILE_MODE)
+	fp.Write([]byte{0, 0, 0, 9, 1, 2})
+	fp.Close()
+
+	q, err = OpenDiskQueue(dir, opts)
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer q.Close()
+	first, next := q.Range()
+	assert.Equal(t, int64(0), first)
+	assert.Equal(t, int64(20), next)
+	seq, err := q.Push([]byte("message-20"))
+	assert.NoError(t, err)
+	assert.Equal(t, int64(20), seq)
+
+	c, _ = q.Consumer("sender")
+	committed, pos := c.Offset()
+	assert.Equal(t, int64(5), committed)
+	assert.Equal(t, int64(5), pos)
+	for i := 5; i <= 20; i++ {
+		_, data, err := c.Next()
+		assert.NoError(t, err)
+		assert.Equal(t, fmt.Sprintf("message-%02d", i), string(data))
+	}
+	_, _, err = c.Next()
+	assert.Equal(t, ErrQueueEmpty, err)
+
+	// 其他消费者还没确认时不能删除
+	other, _ := q.Consumer("backup")
+	c.Commit()
+	removed, err := q.Compact()
+	assert.NoError(t, err)
+	assert.Equal(t, 0, removed)
+	assert.NoError(t, q.RemoveConsumer(other.Name))
+	removed, err = q.Compact()
+	assert.NoError(t, err)
+	assert.True(t, removed > 0)
+	first, _ = q.Range()
+	assert.True(t, first > 0)
+	assert.Equal(t, int64(0), q.Len())
+
+	// 新消费者从剩下的第一条开始
+	other, _ = q.Consumer("backup")
+	seq, _, err = other.Next()
+	assert.NoError(t, err)
+	assert.Equal(t, first, seq)
+}
+
+func TestDiskQueueWait(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "dqueue")
+	defer os.RemoveAll(dir)
+	q, err := OpenDiskQueue(dir, &QueueOptions{SyncPolicy: SYNC_NONE})
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer q.Close()
+	c, _ := q.Consumer("reader")
+	wait := q.Wait()
+	_, _, err = c.Next()
+	assert.Equal(t, ErrQueueEmpty, err)
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		q.Push([]byte("wake"))
+	}()
+	select {
+	case <-wait:
+	case <-time.After(time.Second):
+		t.Fatal("not notified")
+	}
+	_, data, err := c.Next()
+	assert.NoError(t, err)
+	assert.Equal(t, "wake", string(data))
+	_, err = q.Push(make([]byte, 17*1024*1024))
+	assert.Equal(t, ErrMessageTooBig, err)
+}
+
+func TestDiskQueueRecover(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "dqueue")
+	defer os.RemoveAll(dir)
+	q, err := OpenDiskQueue(dir, nil)
+	if !assert.NoError(t, err) {
+		return
+	}
+	for i := 0; i < 3; i++ {
+		q.Push([]byte(fmt.Sprintf("%064d", i)))
+	}
+	q.Close()
+	segs, _ := filepath.Glob(filepath.Join(dir, "*"+SEGMENT_EXT))
+	size, _ := FileSize(segs[0])
+
+	// 调小MaxMessageSize不会删除已有的消息
+	_, err = OpenDiskQueue(dir, &QueueOptions{MaxMessageSize: 32})
+	assert.True(t, errors.Is(err, ErrMessageTooBig))
+	newSize, _ := FileSize(segs[0])
+	assert.Equal(t, size, newSize)
+
+	// 中间的记录损坏时出错
+	fp, _ := os.OpenFile(segs[0], os.O_RDWR, FILE_MODE)
+	fp.WriteAt([]byte("x"), RECORD_HEADER_SIZE)
+	_, err = OpenDiskQueue(dir, nil)
+	assert.True(t, errors.Is(err, ErrQueueCorrupt))
+	newSize, _ = FileSize(segs[0])
+	assert.Equal(t, size, newSize)
+
+	// 最后一条记录校验失败视为没写完整，截掉
+	fp.WriteAt([]byte("0"), RECORD_HEADER_SIZE)
+	fp.WriteAt([]byte("x"), size-1)
+	fp.Close()
+	q, err = OpenDiskQueue(dir, nil)
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer q.Close()
+	first, next := q.Range()
+	assert.Equal(t, int64(0), first)
+	assert.Equal(t, int64(2), next)
+}
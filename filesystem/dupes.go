@@ -0,0 +1,128 @@
+package filesystem
+
+import (
+	"encoding/hex"
+	"os"
+	"sort"
+)
+
+// 查找重复文件的选项
+type DupOptions struct {
+	MinSize     int64  // 小于它的文件不比较，默认为1，即跳过空文件
+	PartialSize int64  // 先比较开头的字节数，默认4096
+	Algo        string // 完整比较的校验算法，默认为sha256
+	Walk        *WalkOptions
+}
+
+// 内容相同的一组文件
+type DupGroup struct {
+	Size  int64
+	Hash  string
+	Paths []string
+}
+
+// 在多个目录中查找内容相同的文件
+// 先按大小分组，再比较开头部分的校验码，最后比较完整的校验码
+func FindDuplicates(roots []string, opts *DupOptions) ([]DupGroup, error) {
+	var o DupOptions
+	if opts != nil {
+		o = *opts
+	}
+	if o.MinSize <= 0 {
+		o.MinSize = 1
+	}
+	if o.PartialSize <= 0 {
+		o.PartialSize = 4096
+	}
+	if o.Algo == "" {
+		o.Algo = HASH_SHA256
+	}
+	if _, err := NewHasher(o.Algo); err != nil {
+		return nil, err
+	}
+	var wo WalkOptions
+	if o.Walk != nil {
+		wo = *o.Walk
+	}
+	wo.Types = WALK_FILE
+
+	bySize := make(map[int64][]string)
+	seen := make(map[string]bool)
+	for _, root := range roots {
+		err := Walk(root, &wo, func(e *WalkEntry) error {
+			info, err := e.Info()
+			if err != nil || info.Size() < o.MinSize || seen[e.Path] {
+				return nil
+			}
+			seen[e.Path] = true
+			bySize[info.Size()] = append(bySize[info.Size()], e.Path)
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var result []DupGroup
+	for size, paths := range bySize {
+		if len(paths) < 2 {
+			continue
+		}
+		candidates := [][]string{paths}
+		// 文件比开头部分大时才需要先比较开头
+		if size > o.PartialSize {
+			groups, err := groupByHash(paths, HASH_XXHASH, o.PartialSize)
+			if err != nil {
+				return nil, err
+			}
+			candidates = candidates[:0]
+			for _, group := range groups {
+				candidates = append(candidates, group)
+			}
+		}
+		for _, group := range candidates {
+			if len(group) < 2 {
+				continue
+			}
+			groups, err := groupByHash(group, o.Algo, 0)
+			if err != nil {
+				return nil, err
+			}
+			for sum, same := range groups {
+				if len(same) > 1 {
+					sort.Strings(same)
+					result = append(result, DupGroup{Size: size, Hash: sum, Paths: same})
+				}
+			}
+		}
+	}
+	// 大文件在前，浪费的空间多
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Size != result[j].Size {
+			return result[i].Size > result[j].Size
+		}
+		return result[i].Paths[0] < result[j].Paths[0]
+	})
+	return result, nil
+}
+
+func groupByHash(paths []string, algo string, limit int64) (map[string][]string, error) {
+	groups := make(map[string][]string)
+	for _, fpath := range paths {
+		fp, err := os.Open(fpath)
+		if err != nil {
+			if os.IsNotExist(err) { // 遍历之后被删除
+				continue
+			}
+			return nil, err
+		}
+		sum, err := HashReader(fp, algo, limit)
+		fp.Close()
+		if err != nil {
+			return nil, err
+		}
+		key := hex.EncodeToString(sum)
+		groups[key] = append(groups[key], fpath)
+	}
+	return groups, nil
+}
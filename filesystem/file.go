@@ -1,12 +1,11 @@
 package filesystem
 
 import (
+	"bytes"
 	"fmt"
+	"io"
 	"os"
-	"os/exec"
 	"path/filepath"
-	"strconv"
-	"strings"
 )
 
 const (
@@ -20,18 +19,7 @@ const (
 // -1, true 存在文件夹
 // >=0, true 文件并存在
 func FileSize(path string) (int64, bool) {
-	if path == "" {
-		return -1, false
-	}
-	info, err := os.Stat(path)
-	if err != nil && os.IsNotExist(err) {
-		return 0, false
-	}
-	var size = int64(-1)
-	if info.IsDir() == false {
-		size = info.Size()
-	}
-	return size, true
+	return FileSizeFS(OS, path)
 }
 
 func CreateFile(path string) (fp *os.File, err error) {
@@ -67,20 +55,26 @@ func OpenFile(path string, readonly, append bool) (fp *os.File, size int64, err
 	return
 }
 
-// 使用 wc -l 计算有多少行
+// 计算有多少行，与 wc -l 相同只统计换行符，出错时返回-1
+// 压缩的文件自动解压，需要反复读取大文件的行数或某一行时，请使用LineIndex
 func LineCount(fname string) int {
-	var num int
-	fname, err := filepath.Abs(fname)
+	fp, _, err := OpenReader(fname)
 	if err != nil {
 		return -1
 	}
-	out, err := exec.Command("wc", "-l", fname).Output()
-	if err != nil {
-		return -1
-	}
-	col := strings.SplitN(string(out), " ", 2)[0]
-	if num, err = strconv.Atoi(col); err != nil {
-		return -1
+	defer fp.Close()
+	var num int
+	buf := make([]byte, 1024*1024)
+	for {
+		n, err := fp.Read(buf)
+		if n > 0 {
+			num += bytes.Count(buf[:n], []byte{'\n'})
+		}
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return -1
+		}
 	}
 	return num
 }
@@ -0,0 +1,283 @@
+package filesystem
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"sync"
+	"time"
+)
+
+// 用于识别文件的开头字节数
+const FINGERPRINT_SIZE = 1024
+
+// 持续读取增长中的文件，类似 tail -f
+// 能处理文件被截断，以及改名后新建同名文件的日志轮转
+// 设置OffsetFile后，读到的位置会保存下来，重启后从这里继续
+//
+//	f := NewFollower("app.log", "app.log.offset")
+//	defer f.Close()
+//	for f.Reading() {
+//		fmt.Println(f.Text())
+//	}
+type Follower struct {
+	Path       string
+	OffsetFile string
+	Interval   time.Duration // 没有新数据时的检查间隔
+	FromEnd    bool          // 没有保存的位置时从文件末尾开始
+	fp         *os.File
+	rd         *bufio.Reader
+	info       os.FileInfo
+	offset     int64 // 已读完整行的结束位置
+	saved      int64
+	partial    []byte // 末尾还没有换行符的数据
+	line       []byte
+	err        error
+	started    bool
+	quit       chan struct{}
+	once       sync.Once
+	mutex      sync.Mutex
+}
+
+func NewFollower(path, offsetFile string) *Follower {
+	return &Follower{
+		Path: path, OffsetFile: offsetFile, Interval: 200 * time.Millisecond,
+		saved: -1, quit: make(chan struct{}),
+	}
+}
+
+func (f *Follower) Err() error {
+	return f.err
+}
+
+func (f *Follower) Line() []byte {
+	return f.line
+}
+
+func (f *Follower) Text() string {
+	return string(f.line)
+}
+
+// 当前行之后的位置
+func (f *Follower) Offset() int64 {
+	return f.offset
+}
+
+// 等待并读取下一行，Stop或Close之后返回false
+func (f *Follower) Reading() bool {
+	for {
+		f.mutex.Lock()
+		ok, done := f.next()
+		f.mutex.Unlock()
+		if ok {
+			return true
+		} else if done {
+			return false
+		}
+		select {
+		case <-f.quit:
+			return false
+		case <-time.After(f.Interval):
+		}
+	}
+}
+
+// 读取下一行，没有新数据时ok和done都为false
+func (f *Follower) next() (ok, done bool) {
+	select {
+	case <-f.quit:
+		return false, true
+	default:
+	}
+	if f.fp == nil {
+		if err := f.open(!f.started); err != nil {
+			if os.IsNotExist(err) {
+				return false, false
+			}
+			f.err = err
+			return false, true
+		}
+	}
+	for {
+		if f.readLine() {
+			return true, false
+		}
+		if f.err != nil {
+			return false, true
+		}
+		if !f.checkFile() {
+			break
+		}
+		if len(f.line) > 0 {
+			return true, false
+		} else if f.fp == nil {
+			return false, false
+		}
+	}
+	if f.err = f.saveOffset(); f.err != nil {
+		return false, true
+	}
+	return false, false
+}
+
+// 在通道中返回每一行，Stop或Close之后通道关闭
+func (f *Follower) Lines() <-chan string {
+	ch := make(chan string)
+	go func() {
+		defer close(ch)
+		for f.Reading() {
+			select {
+			case ch <- f.Text():
+			case <-f.quit:
+				return
+			}
+		}
+	}()
+	return ch
+}
+
+// 停止等待，Reading随后返回false
+func (f *Follower) Stop() {
+	f.once.Do(func() { close(f.quit) })
+}
+
+// 停止并保存位置
+func (f *Follower) Close() error {
+	f.Stop()
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	err := f.saveOffset()
+	if f.fp != nil {
+		if e := f.fp.Close(); err == nil {
+			err = e
+		}
+		f.fp = nil
+	}
+	return err
+}
+
+func (f *Follower) readLine() bool {
+	for {
+		data, err := f.rd.ReadSlice('\n')
+		f.partial = append(f.partial, data...)
+		if err == nil {
+			f.offset += int64(len(f.partial))
+			f.line = append(f.line[:0], bytes.TrimRight(f.partial, "\r\n")...)
+			f.partial = f.partial[:0]
+			return true
+		}
+		if err == io.EOF {
+			return false
+		}
+		if err != bufio.ErrBufferFull {
+			f.err = err
+			return false
+		}
+	}
+}
+
+// 到达末尾后检查截断和轮转，需要重新读取时返回true
+func (f *Follower) checkFile() bool {
+	f.line = f.line[:0]
+	info, err := f.fp.Stat()
+	if err == nil && info.Size() < f.offset+int64(len(f.partial)) {
+		// 文件被截断，从头开始
+		f.reset(0)
+		return true
+	}
+	curr, err := os.Stat(f.Path)
+	if err != nil || os.SameFile(curr, f.info) {
+		return false
+	}
+	// 旧文件已读完，剩余的不完整行也作为一行返回
+	if len(f.partial) > 0 {
+		f.line = append(f.line, f.partial...)
+	}
+	f.fp.Close()
+	f.fp, f.partial, f.offset, f.saved = nil, f.partial[:0], 0, -1
+	if err = f.open(false); err != nil && !os.IsNotExist(err) {
+		f.err = err
+	}
+	return f.fp != nil || len(f.line) > 0
+}
+
+func (f *Follower) reset(offset int64) {
+	f.fp.Seek(offset, io.SeekStart)
+	f.rd.Reset(f.fp)
+	f.offset, f.partial = offset, f.partial[:0]
+}
+
+// 打开文件，resume为true时从保存的位置继续，否则从头开始
+func (f *Follower) open(resume bool) (err error) {
+	if f.fp, err = os.Open(f.Path); err != nil {
+		f.fp = nil
+		return
+	}
+	if f.info, err = f.fp.Stat(); err != nil {
+		f.fp.Close()
+		f.fp = nil
+		return
+	}
+	f.rd = bufio.NewReader(f.fp)
+	f.started = true
+	var offset int64
+	if resume {
+		if offset = f.loadOffset(); offset < 0 {
+			offset = 0
+			if f.FromEnd {
+				offset = f.info.Size()
+			}
+		}
+	}
+	f.reset(offset)
+	return nil
+}
+
+// 读取保存的位置，文件已不是同一个或被截断时返回-1
+func (f *Follower) loadOffset() int64 {
+	if f.OffsetFile == "" {
+		return -1
+	}
+	data, err := ioutil.ReadFile(f.OffsetFile)
+	if err != nil {
+		return -1
+	}
+	var (
+		offset, size int64
+		sum          uint32
+	)
+	if _, err = fmt.Sscan(string(data), &offset, &size, &sum); err != nil {
+		return -1
+	}
+	if offset > f.info.Size() {
+		return -1
+	}
+	if n, crc := fileFingerprint(f.fp, size); n != size || crc != sum {
+		return -1
+	}
+	f.saved = offset
+	return offset
+}
+
+// 保存当前位置，位置未变时不写文件
+func (f *Follower) SaveOffset() error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	return f.saveOffset()
+}
+
+func (f *Follower) saveOffset() error {
+	if f.OffsetFile == "" || f.fp == nil || f.offset == f.saved {
+		return nil
+	}
+	size, sum := fileFingerprint(f.fp, f.offset)
+	data := fmt.Sprintf("%d %d %d\n", f.offset, size, sum)
+	if err := WriteFileAtomic(f.OffsetFile, []byte(data), FILE_MODE); err != nil {
+		return err
+	}
+	f.saved = f.offset
+	return nil
+}
@@ -0,0 +1,88 @@
+package filesystem
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func appendFile(t *testing.T, fname, data string) {
+	fp, err := os.OpenFile(fname, os.O_WRONLY|os.O_CREATE|os.O_APPEND, FILE_MODE)
+	if assert.NoError(t, err) {
+		fp.WriteString(data)
+		fp.Close()
+	}
+}
+
+func nextLine(t *testing.T, ch <-chan string) string {
+	select {
+	case line := <-ch:
+		return line
+	case <-time.After(3 * time.Second):
+		t.Fatal("timeout waiting for line")
+	}
+	return ""
+}
+
+func TestFollower(t *testing.T) {
+	tmp, _ := ioutil.TempDir("", "follow")
+	defer os.RemoveAll(tmp)
+	fname := filepath.Join(tmp, "app.log")
+	offname := filepath.Join(tmp, "app.offset")
+
+	f := NewFollower(fname, offname)
+	f.Interval = 10 * time.Millisecond
+	ch := f.Lines()
+	appendFile(t, fname, "first\nsecond\r\nthi")
+	assert.Equal(t, "first", nextLine(t, ch))
+	assert.Equal(t, "second", nextLine(t, ch))
+	appendFile(t, fname, "rd\n")
+	assert.Equal(t, "third", nextLine(t, ch))
+
+	// 截断后从头开始
+	assert.NoError(t, os.Truncate(fname, 0))
+	time.Sleep(50 * time.Millisecond)
+	appendFile(t, fname, "after truncate\n")
+	assert.Equal(t, "after truncate", nextLine(t, ch))
+
+	// 改名后新建同名文件
+	appendFile(t, fname, "last of old\ntail")
+	assert.Equal(t, "last of old", nextLine(t, ch))
+	assert.NoError(t, os.Rename(fname, fname+".1"))
+	appendFile(t, fname, "new file\n")
+	assert.Equal(t, "tail", nextLine(t, ch))
+	assert.Equal(t, "new file", nextLine(t, ch))
+	assert.NoError(t, f.Close())
+	_, ok := <-ch
+	assert.False(t, ok)
+
+	// 重启后从保存的位置继续
+	appendFile(t, fname, "while stopped\n")
+	f = NewFollower(fname, offname)
+	assert.True(t, f.Reading())
+	assert.Equal(t, "while stopped", f.Text())
+	assert.Equal(t, int64(23), f.Offset())
+	assert.NoError(t, f.Close())
+	assert.False(t, f.Reading())
+
+	// 文件已被替换时，保存的位置作废
+	os.Remove(fname)
+	appendFile(t, fname, "replaced content is longer\n")
+	f = NewFollower(fname, offname)
+	assert.True(t, f.Reading())
+	assert.Equal(t, "replaced content is longer", f.Text())
+	f.Close()
+
+	// 没有保存的位置时从末尾开始
+	f = NewFollower(fname, "")
+	f.FromEnd, f.Interval = true, 10*time.Millisecond
+	ch = f.Lines()
+	time.Sleep(50 * time.Millisecond)
+	appendFile(t, fname, "from end\n")
+	assert.Equal(t, "from end", nextLine(t, ch))
+	f.Close()
+}
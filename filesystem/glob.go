@@ -0,0 +1,124 @@
+package filesystem
+
+import (
+	"bufio"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// 匹配带 ** 的路径，** 匹配零或多层目录，其余与 path.Match 相同
+// 如 **/*.log 匹配 a.log 和 x/y/a.log
+func MatchGlob(pattern, name string) bool {
+	return matchSegments(strings.Split(pattern, "/"), strings.Split(filepath.ToSlash(name), "/"))
+}
+
+func matchSegments(pats, names []string) bool {
+	for len(pats) > 0 {
+		if pats[0] == "**" {
+			// 合并连续的 **
+			for len(pats) > 1 && pats[1] == "**" {
+				pats = pats[1:]
+			}
+			if len(pats) == 1 {
+				return true
+			}
+			for i := 0; i <= len(names); i++ {
+				if matchSegments(pats[1:], names[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(names) == 0 {
+			return false
+		}
+		if ok, _ := path.Match(pats[0], names[0]); !ok {
+			return false
+		}
+		pats, names = pats[1:], names[1:]
+	}
+	return len(names) == 0
+}
+
+// .gitignore 的一条规则
+type ignoreRule struct {
+	pattern string
+	negate  bool
+	dirOnly bool
+}
+
+// .gitignore 格式的忽略规则，路径相对于规则文件所在目录
+type IgnoreRules struct {
+	rules []ignoreRule
+}
+
+// 解析规则，每行一条，支持 # 注释、! 取反、/ 开头锚定和 / 结尾只匹配目录
+func ParseIgnore(lines []string) *IgnoreRules {
+	r := &IgnoreRules{}
+	for _, line := range lines {
+		line = strings.TrimRight(line, " \t\r")
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		var rule ignoreRule
+		if strings.HasPrefix(line, "!") {
+			rule.negate, line = true, line[1:]
+		} else if strings.HasPrefix(line, `\`) {
+			line = line[1:] // 转义开头的 # 或 !
+		}
+		if strings.HasSuffix(line, "/") {
+			rule.dirOnly, line = true, strings.TrimRight(line, "/")
+		}
+		// 不含斜杠的规则匹配任意层，否则相对于规则文件所在目录
+		if strings.HasPrefix(line, "/") {
+			line = line[1:]
+		} else if !strings.Contains(line, "/") {
+			line = "**/" + line
+		}
+		if line == "" {
+			continue
+		}
+		rule.pattern = line
+		r.rules = append(r.rules, rule)
+	}
+	return r
+}
+
+// 读取规则文件，文件不存在时返回nil
+func ReadIgnoreFile(fname string) (*IgnoreRules, error) {
+	fp, err := os.Open(fname)
+	if err != nil {
+		if os.IsNotExist(err) {
+			err = nil
+		}
+		return nil, err
+	}
+	defer fp.Close()
+	var lines []string
+	scanner := bufio.NewScanner(fp)
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+	}
+	return ParseIgnore(lines), scanner.Err()
+}
+
+// 判断相对路径是否被忽略，后面的规则优先
+// 返回值 matched 表示有规则匹配，此时 ignored 才有意义
+func (r *IgnoreRules) Match(rel string, isDir bool) (ignored, matched bool) {
+	if r == nil {
+		return false, false
+	}
+	rel = filepath.ToSlash(rel)
+	for i := len(r.rules) - 1; i >= 0; i-- {
+		rule := r.rules[i]
+		if rule.dirOnly && !isDir {
+			continue
+		}
+		if MatchGlob(rule.pattern, rel) {
+			return !rule.negate, true
+		}
+	}
+	return false, false
+}
@@ -0,0 +1,235 @@
+package filesystem
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+)
+
+const LINE_INDEX_MAGIC = "GZLIDX1\n"
+
+var ErrLineOutOfRange = errors.New("line number out of range")
+
+// 文件的行首位置索引，按行号读取为常数时间，按位置查行号为对数时间
+// 文件只追加时可以增量更新，截断或替换后重建
+type LineIndex struct {
+	Path   string
+	starts []int64 // 每行的开始位置，最后一项可能等于size，表示之后还没有数据
+	size   int64   // 已索引的字节数
+	fpSize int64   // 指纹覆盖的字节数
+	fpSum  uint32
+}
+
+// 扫描整个文件建立索引
+func BuildLineIndex(path string) (*LineIndex, error) {
+	ix := &LineIndex{Path: path}
+	return ix, ix.Update()
+}
+
+// 读取保存的索引，失效时重建，文件增长时增量更新，有变化时保存
+func LoadLineIndex(path, indexFile string) (*LineIndex, error) {
+	ix, err := readLineIndex(path, indexFile)
+	if err != nil {
+		ix = &LineIndex{Path: path}
+	}
+	size := ix.size
+	if err = ix.Update(); err != nil {
+		return nil, err
+	}
+	if ix.size != size || len(ix.starts) == 0 {
+		err = ix.Save(indexFile)
+	}
+	return ix, err
+}
+
+// 行数，最后一行没有换行符也计算在内
+func (ix *LineIndex) Count() int {
+	n := len(ix.starts)
+	if n > 0 && ix.starts[n-1] == ix.size {
+		n--
+	}
+	return n
+}
+
+// 第n行（从0开始）的开始和结束位置，结束位置包括换行符
+func (ix *LineIndex) Range(n int) (start, end int64, err error) {
+	if n < 0 || n >= ix.Count() {
+		return 0, 0, ErrLineOutOfRange
+	}
+	start, end = ix.starts[n], ix.size
+	if n+1 < len(ix.starts) {
+		end = ix.starts[n+1]
+	}
+	return
+}
+
+// 读取第n行（从0开始），不含换行符
+func (ix *LineIndex) ReadLine(n int) (string, error) {
+	start, end, err := ix.Range(n)
+	if err != nil {
+		return "", err
+	}
+	fp, err := os.Open(ix.Path)
+	if err != nil {
+		return "", err
+	}
+	defer fp.Close()
+	buf := make([]byte, end-start)
+	if _, err = fp.ReadAt(buf, start); err != nil {
+		return "", err
+	}
+	return string(bytes.TrimRight(buf, "\r\n")), nil
+}
+
+// 位置所在的行号，超出范围时返回-1
+func (ix *LineIndex) LineAt(offset int64) int {
+	if offset < 0 || offset >= ix.size {
+		return -1
+	}
+	lo, hi := 0, len(ix.starts)-1
+	for lo < hi {
+		mid := (lo + hi + 1) / 2
+		if ix.starts[mid] <= offset {
+			lo = mid
+		} else {
+			hi = mid - 1
+		}
+	}
+	return lo
+}
+
+// 索引文件新增的部分，文件被截断或替换时重建
+func (ix *LineIndex) Update() error {
+	fp, err := os.Open(ix.Path)
+	if err != nil {
+		return err
+	}
+	defer fp.Close()
+	info, err := fp.Stat()
+	if err != nil {
+		return err
+	}
+	if info.Size() < ix.size || !ix.sameHead(fp) {
+		ix.starts, ix.size, ix.fpSize = nil, 0, 0
+	}
+	if info.Size() == ix.size {
+		return nil
+	}
+	if len(ix.starts) == 0 {
+		ix.starts = append(ix.starts, 0)
+	}
+	if _, err = fp.Seek(ix.size, io.SeekStart); err != nil {
+		return err
+	}
+	buf := make([]byte, 1024*1024)
+	for {
+		n, err := fp.Read(buf)
+		for i := 0; i < n; i++ {
+			if buf[i] == '\n' {
+				ix.starts = append(ix.starts, ix.size+int64(i)+1)
+			}
+		}
+		ix.size += int64(n)
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return err
+		}
+	}
+	if ix.fpSize < FINGERPRINT_SIZE {
+		ix.fpSize, ix.fpSum = fileFingerprint(fp, ix.size)
+	}
+	return nil
+}
+
+func (ix *LineIndex) sameHead(fp *os.File) bool {
+	if ix.fpSize == 0 {
+		return true
+	}
+	size, sum := fileFingerprint(fp, ix.fpSize)
+	return size == ix.fpSize && sum == ix.fpSum
+}
+
+// 保存索引，行首位置以差值变长编码
+func (ix *LineIndex) Save(indexFile string) error {
+	return WriteAtomic(indexFile, FILE_MODE, func(w io.Writer) error {
+		bw := bufio.NewWriter(w)
+		bw.WriteString(LINE_INDEX_MAGIC)
+		buf := make([]byte, binary.MaxVarintLen64)
+		put := func(x uint64) {
+			bw.Write(buf[:binary.PutUvarint(buf, x)])
+		}
+		put(uint64(ix.size))
+		put(uint64(ix.fpSize))
+		put(uint64(ix.fpSum))
+		put(uint64(len(ix.starts)))
+		var last int64
+		for _, start := range ix.starts {
+			put(uint64(start - last))
+			last = start
+		}
+		return bw.Flush()
+	})
+}
+
+func readLineIndex(path, indexFile string) (*LineIndex, error) {
+	fp, err := os.Open(indexFile)
+	if err != nil {
+		return nil, err
+	}
+	defer fp.Close()
+	br := bufio.NewReader(fp)
+	magic := make([]byte, len(LINE_INDEX_MAGIC))
+	if _, err = io.ReadFull(br, magic); err != nil {
+		return nil, err
+	}
+	if string(magic) != LINE_INDEX_MAGIC {
+		return nil, fmt.Errorf("%s is not a line index", indexFile)
+	}
+	var head [4]uint64
+	for i := range head {
+		if head[i], err = binary.ReadUvarint(br); err != nil {
+			return nil, err
+		}
+	}
+	ix := &LineIndex{Path: path, size: int64(head[0]),
+		fpSize: int64(head[1]), fpSum: uint32(head[2])}
+	// 行数最多为size+1，每个位置至少占一个字节，超出说明索引文件已损坏
+	info, err := fp.Stat()
+	if err != nil {
+		return nil, err
+	}
+	count := head[3]
+	if count > head[0]+1 || count > uint64(info.Size()) {
+		return nil, fmt.Errorf("%s: corrupt line index", indexFile)
+	}
+	ix.starts = make([]int64, count)
+	var last uint64
+	for i := range ix.starts {
+		delta, err := binary.ReadUvarint(br)
+		if err != nil {
+			return nil, err
+		}
+		if delta > head[0]-last {
+			return nil, fmt.Errorf("%s: corrupt line index", indexFile)
+		}
+		last += delta
+		ix.starts[i] = int64(last)
+	}
+	return ix, nil
+}
+
+// 文件开头部分的大小和校验码，用于识别是否还是同一个文件
+func fileFingerprint(fp *os.File, size int64) (int64, uint32) {
+	if size > FINGERPRINT_SIZE {
+		size = FINGERPRINT_SIZE
+	}
+	buf := make([]byte, size)
+	n, _ := fp.ReadAt(buf, 0)
+	return int64(n), crc32.ChecksumIEEE(buf[:n])
+}
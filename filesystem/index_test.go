@@ -0,0 +1,97 @@
+package filesystem
+
+import (
+	"encoding/binary"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLineIndex(t *testing.T) {
+	tmp, _ := ioutil.TempDir("", "index")
+	defer os.RemoveAll(tmp)
+	fname := filepath.Join(tmp, "a.log")
+	idxname := filepath.Join(tmp, "a.log.idx")
+	ioutil.WriteFile(fname, []byte("zero\none\r\n\nthree"), FILE_MODE)
+
+	ix, err := LoadLineIndex(fname, idxname)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, 4, ix.Count())
+	for n, want := range []string{"zero", "one", "", "three"} {
+		line, err := ix.ReadLine(n)
+		assert.NoError(t, err)
+		assert.Equal(t, want, line)
+	}
+	_, err = ix.ReadLine(4)
+	assert.Equal(t, ErrLineOutOfRange, err)
+	assert.Equal(t, 0, ix.LineAt(0))
+	assert.Equal(t, 1, ix.LineAt(7))
+	assert.Equal(t, 3, ix.LineAt(11))
+	assert.Equal(t, -1, ix.LineAt(100))
+
+	// 追加后增量更新，保存的索引可以继续使用
+	appendFile(t, fname, " continued\nfour\n")
+	ix, err = LoadLineIndex(fname, idxname)
+	assert.NoError(t, err)
+	assert.Equal(t, 5, ix.Count())
+	line, _ := ix.ReadLine(3)
+	assert.Equal(t, "three continued", line)
+	line, _ = ix.ReadLine(4)
+	assert.Equal(t, "four", line)
+	saved, err := readLineIndex(fname, idxname)
+	assert.NoError(t, err)
+	assert.Equal(t, ix.starts, saved.starts)
+
+	// 文件被替换后重建
+	ioutil.WriteFile(fname, []byte("other content\nhere\nand more lines\n"), FILE_MODE)
+	ix, err = LoadLineIndex(fname, idxname)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, ix.Count())
+	line, _ = ix.ReadLine(0)
+	assert.Equal(t, "other content", line)
+	assert.Equal(t, LineCount(fname), ix.Count())
+
+	// 最后一行没有换行符时，LineIndex计算在内，LineCount与 wc -l 一样不计算
+	ioutil.WriteFile(fname, []byte("first\nlast"), FILE_MODE)
+	ix, err = LoadLineIndex(fname, idxname)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, ix.Count())
+	assert.Equal(t, 1, LineCount(fname))
+}
+
+func TestCorruptLineIndex(t *testing.T) {
+	tmp, _ := ioutil.TempDir("", "index")
+	defer os.RemoveAll(tmp)
+	fname := filepath.Join(tmp, "a.log")
+	idxname := filepath.Join(tmp, "a.log.idx")
+	ioutil.WriteFile(fname, []byte("one\ntwo\n"), FILE_MODE)
+
+	corrupt := func(values ...uint64) []byte {
+		data := []byte(LINE_INDEX_MAGIC)
+		buf := make([]byte, binary.MaxVarintLen64)
+		for _, v := range values {
+			n := binary.PutUvarint(buf, v)
+			data = append(data, buf[:n]...)
+		}
+		return data
+	}
+	cases := [][]byte{
+		corrupt(8, 0, 0, 1<<62),           // 行数过大
+		corrupt(8, 0, 0, 3, 0, 4, 1<<63),  // 位置溢出
+		corrupt(1<<40, 0, 0, 1<<20, 0, 1), // 行数超过索引文件大小
+	}
+	for _, data := range cases {
+		ioutil.WriteFile(idxname, data, FILE_MODE)
+		_, err := readLineIndex(fname, idxname)
+		assert.Error(t, err)
+		// 损坏的索引被重建
+		ix, err := LoadLineIndex(fname, idxname)
+		assert.NoError(t, err)
+		assert.Equal(t, 2, ix.Count())
+	}
+}
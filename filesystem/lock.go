@@ -0,0 +1,117 @@
+package filesystem
+
+import (
+	"errors"
+	"os"
+	"time"
+)
+
+var (
+	ErrLocked      = errors.New("file is locked by another process")
+	ErrLockTimeout = errors.New("timeout waiting for file lock")
+)
+
+// 基于flock的建议锁，只对同样加锁的进程有效
+// 锁随文件描述符释放，进程退出时自动解锁
+// solaris和aix上使用fcntl记录锁，同一进程内不会互斥
+type FileLock struct {
+	Path string
+	fp   *os.File
+}
+
+// 加排他锁，timeout小于0时一直等待，等于0时与TryLockFile相同
+func LockFile(path string, timeout time.Duration) (*FileLock, error) {
+	return lockFile(path, true, timeout)
+}
+
+// 加共享锁，可以与其他共享锁同时持有
+func RLockFile(path string, timeout time.Duration) (*FileLock, error) {
+	return lockFile(path, false, timeout)
+}
+
+// 尝试加排他锁，已被占用时返回ErrLocked
+func TryLockFile(path string) (*FileLock, error) {
+	return lockFile(path, true, 0)
+}
+
+func lockFile(path string, exclusive bool, timeout time.Duration) (*FileLock, error) {
+	if size := MkdirForFile(path); size < 0 {
+		return nil, &os.PathError{Op: "lock", Path: path, Err: errors.New("is a directory")}
+	}
+	fp, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, FILE_MODE)
+	if err != nil {
+		return nil, err
+	}
+	if timeout < 0 {
+		err = flock(fp, exclusive, true)
+	} else {
+		err = pollLock(fp, exclusive, timeout)
+	}
+	if err != nil {
+		fp.Close()
+		return nil, err
+	}
+	return &FileLock{Path: path, fp: fp}, nil
+}
+
+// 文件是否被其他进程锁住，只读打开，文件不存在时返回false
+// fcntl记录锁在关闭文件时会释放本进程的锁，不要用来检查自己持有的锁
+func IsFileLocked(path string) (bool, error) {
+	fp, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+	defer fp.Close()
+	if err = flock(fp, false, false); err == nil {
+		err = funlock(fp)
+	} else if err == ErrLocked {
+		return true, nil
+	}
+	return false, err
+}
+
+// 不断尝试直到超时，间隔逐渐加长
+func pollLock(fp *os.File, exclusive bool, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	wait := time.Millisecond
+	for {
+		err := flock(fp, exclusive, false)
+		if err != ErrLocked {
+			return err
+		}
+		remain := time.Until(deadline)
+		if remain <= 0 {
+			if timeout == 0 {
+				return ErrLocked
+			}
+			return ErrLockTimeout
+		}
+		if wait > remain {
+			wait = remain
+		}
+		time.Sleep(wait)
+		if wait < 100*time.Millisecond {
+			wait *= 2
+		}
+	}
+}
+
+// 加锁的文件，可以用来读写内容
+func (l *FileLock) File() *os.File {
+	return l.fp
+}
+
+// 解锁并关闭文件，锁文件保留
+func (l *FileLock) Unlock() error {
+	if l.fp == nil {
+		return nil
+	}
+	err := funlock(l.fp)
+	if cerr := l.fp.Close(); err == nil {
+		err = cerr
+	}
+	l.fp = nil
+	return err
+}
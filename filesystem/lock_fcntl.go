@@ -0,0 +1,37 @@
+//go:build aix || solaris
+// +build aix solaris
+
+package filesystem
+
+import (
+	"io"
+	"os"
+	"syscall"
+)
+
+// 没有flock的系统使用fcntl记录锁，锁属于进程而不是文件描述符
+// 同一进程内不会互斥，关闭该文件的任何描述符都会释放锁
+func flock(fp *os.File, exclusive, block bool) error {
+	lk := syscall.Flock_t{Type: syscall.F_RDLCK, Whence: io.SeekStart}
+	if exclusive {
+		lk.Type = syscall.F_WRLCK
+	}
+	cmd := syscall.F_SETLK
+	if block {
+		cmd = syscall.F_SETLKW
+	}
+	for {
+		err := syscall.FcntlFlock(fp.Fd(), cmd, &lk)
+		if err == syscall.EINTR {
+			continue
+		} else if err == syscall.EAGAIN || err == syscall.EACCES {
+			return ErrLocked
+		}
+		return err
+	}
+}
+
+func funlock(fp *os.File) error {
+	lk := syscall.Flock_t{Type: syscall.F_UNLCK, Whence: io.SeekStart}
+	return syscall.FcntlFlock(fp.Fd(), syscall.F_SETLK, &lk)
+}
@@ -0,0 +1,19 @@
+//go:build !aix && !darwin && !dragonfly && !freebsd && !linux && !netbsd && !openbsd && !solaris && !windows
+// +build !aix,!darwin,!dragonfly,!freebsd,!linux,!netbsd,!openbsd,!solaris,!windows
+
+package filesystem
+
+import (
+	"errors"
+	"os"
+)
+
+var errLockUnsupported = errors.New("file lock is not supported on this platform")
+
+func flock(fp *os.File, exclusive, block bool) error {
+	return errLockUnsupported
+}
+
+func funlock(fp *os.File) error {
+	return errLockUnsupported
+}
@@ -0,0 +1,60 @@
+package filesystem
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLockFile(t *testing.T) {
+	tmp, _ := ioutil.TempDir("", "lock")
+	defer os.RemoveAll(tmp)
+	fname := filepath.Join(tmp, "cache", "app.lock")
+
+	lock, err := TryLockFile(fname)
+	if !assert.NoError(t, err) {
+		return
+	}
+	_, err = TryLockFile(fname)
+	assert.Equal(t, ErrLocked, err)
+	_, err = LockFile(fname, 50*time.Millisecond)
+	assert.Equal(t, ErrLockTimeout, err)
+
+	// 等待到解锁
+	go func(l *FileLock) {
+		time.Sleep(30 * time.Millisecond)
+		l.Unlock()
+	}(lock)
+	lock, err = LockFile(fname, time.Second)
+	assert.NoError(t, err)
+	assert.NoError(t, lock.Unlock())
+	assert.NoError(t, lock.Unlock())
+
+	// 共享锁可以同时持有，但排斥排他锁
+	r1, err := RLockFile(fname, 0)
+	assert.NoError(t, err)
+	r2, err := RLockFile(fname, 0)
+	assert.NoError(t, err)
+	_, err = TryLockFile(fname)
+	assert.Equal(t, ErrLocked, err)
+	r1.Unlock()
+	r2.Unlock()
+	lock, err = LockFile(fname, -1)
+	assert.NoError(t, err)
+	locked, err := IsFileLocked(fname)
+	assert.NoError(t, err)
+	assert.True(t, locked)
+	lock.Unlock()
+	locked, _ = IsFileLocked(fname)
+	assert.False(t, locked)
+	locked, err = IsFileLocked(filepath.Join(tmp, "missing.lock"))
+	assert.NoError(t, err)
+	assert.False(t, locked)
+
+	_, err = TryLockFile(tmp)
+	assert.Error(t, err)
+}
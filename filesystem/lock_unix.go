@@ -0,0 +1,32 @@
+//go:build darwin || dragonfly || freebsd || linux || netbsd || openbsd
+// +build darwin dragonfly freebsd linux netbsd openbsd
+
+package filesystem
+
+import (
+	"os"
+	"syscall"
+)
+
+func flock(fp *os.File, exclusive, block bool) error {
+	how := syscall.LOCK_SH
+	if exclusive {
+		how = syscall.LOCK_EX
+	}
+	if !block {
+		how |= syscall.LOCK_NB
+	}
+	for {
+		err := syscall.Flock(int(fp.Fd()), how)
+		if err == syscall.EINTR {
+			continue
+		} else if err == syscall.EWOULDBLOCK {
+			return ErrLocked
+		}
+		return err
+	}
+}
+
+func funlock(fp *os.File) error {
+	return syscall.Flock(int(fp.Fd()), syscall.LOCK_UN)
+}
@@ -0,0 +1,36 @@
+//go:build windows
+// +build windows
+
+package filesystem
+
+import (
+	"os"
+
+	"golang.org/x/sys/windows"
+)
+
+func flock(fp *os.File, exclusive, block bool) error {
+	var flags uint32
+	if exclusive {
+		flags |= windows.LOCKFILE_EXCLUSIVE_LOCK
+	}
+	if !block {
+		flags |= windows.LOCKFILE_FAIL_IMMEDIATELY
+	}
+	ol := new(windows.Overlapped)
+	err := windows.LockFileEx(windows.Handle(fp.Fd()), flags, 0, 1, 0, ol)
+	if err == windows.ERROR_LOCK_VIOLATION {
+		return ErrLocked
+	}
+	return err
+}
+
+func funlock(fp *os.File) error {
+	ol := new(windows.Overlapped)
+	return windows.UnlockFileEx(windows.Handle(fp.Fd()), 0, 1, 0, ol)
+}
+
+// Windows无法同步目录，改名由文件系统保证
+func syncDir(dir string) error {
+	return nil
+}
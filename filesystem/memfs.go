@@ -0,0 +1,321 @@
+package filesystem
+
+import (
+	"bytes"
+	"io"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+type memNode struct {
+	data    []byte
+	mode    fs.FileMode
+	modTime time.Time
+}
+
+// 文件信息，在持有锁时复制节点的属性，之后的写入不会影响它
+type memInfo struct {
+	name    string
+	size    int64
+	mode    fs.FileMode
+	modTime time.Time
+}
+
+func newMemInfo(name string, node *memNode) memInfo {
+	return memInfo{name: name, size: int64(len(node.data)), mode: node.mode, modTime: node.modTime}
+}
+
+func (i memInfo) Name() string {
+	return i.name
+}
+
+func (i memInfo) Size() int64 {
+	return i.size
+}
+
+func (i memInfo) Mode() fs.FileMode {
+	return i.mode
+}
+
+func (i memInfo) ModTime() time.Time {
+	return i.modTime
+}
+
+func (i memInfo) IsDir() bool {
+	return i.mode.IsDir()
+}
+
+func (i memInfo) Sys() interface{} {
+	return nil
+}
+
+// 内存中的文件系统，用于测试
+type MemFS struct {
+	nodes map[string]*memNode
+	mutex sync.RWMutex
+}
+
+func NewMemFS() *MemFS {
+	root := &memNode{mode: fs.ModeDir | DIR_MODE, modTime: time.Now()}
+	return &MemFS{nodes: map[string]*memNode{".": root}}
+}
+
+func (m *MemFS) lookup(op, name string) (*memNode, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: op, Path: name, Err: fs.ErrInvalid}
+	}
+	node, ok := m.nodes[name]
+	if !ok {
+		return nil, &fs.PathError{Op: op, Path: name, Err: fs.ErrNotExist}
+	}
+	return node, nil
+}
+
+func (m *MemFS) Open(name string) (fs.File, error) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+	node, err := m.lookup("open", name)
+	if err != nil {
+		return nil, err
+	}
+	info := newMemInfo(path.Base(name), node)
+	if !node.mode.IsDir() {
+		return &memFile{Reader: bytes.NewReader(node.data), info: info}, nil
+	}
+	return &dirFile{info: info, entries: m.children(name)}, nil
+}
+
+// 直接下级，按名称排序
+func (m *MemFS) children(dir string) []fs.DirEntry {
+	prefix := dir + "/"
+	if dir == "." {
+		prefix = ""
+	}
+	var entries []fs.DirEntry
+	for name, node := range m.nodes {
+		if name == "." || !strings.HasPrefix(name, prefix) {
+			continue
+		}
+		if rest := name[len(prefix):]; !strings.Contains(rest, "/") {
+			entries = append(entries, fs.FileInfoToDirEntry(newMemInfo(rest, node)))
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Name() < entries[j].Name()
+	})
+	return entries
+}
+
+func (m *MemFS) Stat(name string) (fs.FileInfo, error) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+	node, err := m.lookup("stat", name)
+	if err != nil {
+		return nil, err
+	}
+	return newMemInfo(path.Base(name), node), nil
+}
+
+func (m *MemFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+	node, err := m.lookup("readdir", name)
+	if err != nil {
+		return nil, err
+	}
+	if !node.mode.IsDir() {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: errNotDir}
+	}
+	return m.children(name), nil
+}
+
+func (m *MemFS) ReadFile(name string) ([]byte, error) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+	node, err := m.lookup("readfile", name)
+	if err != nil {
+		return nil, err
+	}
+	if node.mode.IsDir() {
+		return nil, &fs.PathError{Op: "readfile", Path: name, Err: errIsDir}
+	}
+	return append([]byte(nil), node.data...), nil
+}
+
+// 检查上级目录存在
+func (m *MemFS) checkParent(op, name string) error {
+	parent, err := m.lookup(op, path.Dir(name))
+	if err != nil {
+		return &fs.PathError{Op: op, Path: name, Err: fs.ErrNotExist}
+	}
+	if !parent.mode.IsDir() {
+		return &fs.PathError{Op: op, Path: name, Err: errNotDir}
+	}
+	return nil
+}
+
+func (m *MemFS) Create(name string) (io.WriteCloser, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if !fs.ValidPath(name) || name == "." {
+		return nil, &fs.PathError{Op: "create", Path: name, Err: fs.ErrInvalid}
+	}
+	if err := m.checkParent("create", name); err != nil {
+		return nil, err
+	}
+	node, ok := m.nodes[name]
+	if ok && node.mode.IsDir() {
+		return nil, &fs.PathError{Op: "create", Path: name, Err: errIsDir}
+	}
+	if !ok {
+		node = &memNode{mode: FILE_MODE}
+		m.nodes[name] = node
+	}
+	node.data, node.modTime = nil, time.Now()
+	return &memWriter{fs: m, node: node}, nil
+}
+
+func (m *MemFS) MkdirAll(name string, perm fs.FileMode) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if !fs.ValidPath(name) {
+		return &fs.PathError{Op: "mkdir", Path: name, Err: fs.ErrInvalid}
+	}
+	for dir := name; dir != "."; dir = path.Dir(dir) {
+		if node, ok := m.nodes[dir]; ok {
+			if !node.mode.IsDir() {
+				return &fs.PathError{Op: "mkdir", Path: dir, Err: errNotDir}
+			}
+			continue
+		}
+		m.nodes[dir] = &memNode{mode: fs.ModeDir | perm.Perm(), modTime: time.Now()}
+	}
+	return nil
+}
+
+func (m *MemFS) Remove(name string) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	node, err := m.lookup("remove", name)
+	if err != nil {
+		return err
+	}
+	if name == "." || (node.mode.IsDir() && len(m.children(name)) > 0) {
+		return &fs.PathError{Op: "remove", Path: name, Err: errNotEmpty}
+	}
+	delete(m.nodes, name)
+	return nil
+}
+
+func (m *MemFS) RemoveAll(name string) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if !fs.ValidPath(name) || name == "." {
+		return &fs.PathError{Op: "remove", Path: name, Err: fs.ErrInvalid}
+	}
+	for p := range m.nodes {
+		if p == name || strings.HasPrefix(p, name+"/") {
+			delete(m.nodes, p)
+		}
+	}
+	return nil
+}
+
+// 改名，目录连同下级一起移动，目标已存在的文件被覆盖
+func (m *MemFS) Rename(oldname, newname string) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	node, err := m.lookup("rename", oldname)
+	if err != nil {
+		return err
+	}
+	if !fs.ValidPath(newname) || oldname == "." || newname == "." ||
+		strings.HasPrefix(newname, oldname+"/") {
+		return &fs.PathError{Op: "rename", Path: newname, Err: fs.ErrInvalid}
+	}
+	if err = m.checkParent("rename", newname); err != nil {
+		return err
+	}
+	if old, ok := m.nodes[newname]; ok && (old.mode.IsDir() || node.mode.IsDir()) {
+		return &fs.PathError{Op: "rename", Path: newname, Err: fs.ErrExist}
+	}
+	for p, n := range m.nodes {
+		if strings.HasPrefix(p, oldname+"/") {
+			delete(m.nodes, p)
+			m.nodes[newname+p[len(oldname):]] = n
+		}
+	}
+	delete(m.nodes, oldname)
+	m.nodes[newname] = node
+	return nil
+}
+
+// 内存中打开的文件
+type memFile struct {
+	*bytes.Reader
+	info memInfo
+}
+
+func (f *memFile) Stat() (fs.FileInfo, error) {
+	return f.info, nil
+}
+
+func (f *memFile) Close() error {
+	return nil
+}
+
+type memWriter struct {
+	fs   *MemFS
+	node *memNode
+}
+
+func (w *memWriter) Write(p []byte) (int, error) {
+	w.fs.mutex.Lock()
+	defer w.fs.mutex.Unlock()
+	w.node.data = append(w.node.data, p...)
+	w.node.modTime = time.Now()
+	return len(p), nil
+}
+
+func (w *memWriter) Close() error {
+	return nil
+}
+
+// 打开的目录，读取已排序的下级
+type dirFile struct {
+	info    fs.FileInfo
+	entries []fs.DirEntry
+	offset  int
+}
+
+func (d *dirFile) Stat() (fs.FileInfo, error) {
+	return d.info, nil
+}
+
+func (d *dirFile) Read([]byte) (int, error) {
+	return 0, &fs.PathError{Op: "read", Path: d.info.Name(), Err: errIsDir}
+}
+
+func (d *dirFile) Close() error {
+	return nil
+}
+
+func (d *dirFile) ReadDir(n int) ([]fs.DirEntry, error) {
+	rest := d.entries[d.offset:]
+	if n <= 0 {
+		d.offset = len(d.entries)
+		return rest, nil
+	}
+	if len(rest) == 0 {
+		return nil, io.EOF
+	}
+	if n > len(rest) {
+		n = len(rest)
+	}
+	d.offset += n
+	return rest[:n], nil
+}
@@ -0,0 +1,171 @@
+package filesystem
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"path"
+	"sort"
+)
+
+// 叠加的文件系统，按顺序查找，前面的优先，目录内容合并
+// Upper为nil时只读，可以用来包装 embed.FS；否则写入都在Upper中
+//
+//	//go:embed static
+//	var static embed.FS
+//	assets := NewOverlayFS(NewOsFS("./custom"), static)
+type OverlayFS struct {
+	Upper  WritableFS
+	Lowers []fs.FS
+}
+
+func NewOverlayFS(upper WritableFS, lowers ...fs.FS) *OverlayFS {
+	return &OverlayFS{Upper: upper, Lowers: lowers}
+}
+
+// 只读的文件系统
+func ReadOnlyFS(layers ...fs.FS) *OverlayFS {
+	return &OverlayFS{Lowers: layers}
+}
+
+func (o *OverlayFS) layers() []fs.FS {
+	if o.Upper == nil {
+		return o.Lowers
+	}
+	return append([]fs.FS{o.Upper}, o.Lowers...)
+}
+
+func (o *OverlayFS) Open(name string) (fs.File, error) {
+	info, err := o.Stat(name)
+	if err != nil {
+		return nil, err
+	}
+	if info.IsDir() {
+		entries, err := o.ReadDir(name)
+		if err != nil {
+			return nil, err
+		}
+		return &dirFile{info: info, entries: entries}, nil
+	}
+	for _, layer := range o.layers() {
+		fp, err := layer.Open(name)
+		if err == nil || !errors.Is(err, fs.ErrNotExist) {
+			return fp, err
+		}
+	}
+	return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+}
+
+func (o *OverlayFS) Stat(name string) (fs.FileInfo, error) {
+	for _, layer := range o.layers() {
+		info, err := fs.Stat(layer, name)
+		if err == nil || !errors.Is(err, fs.ErrNotExist) {
+			return info, err
+		}
+	}
+	return nil, &fs.PathError{Op: "stat", Path: name, Err: fs.ErrNotExist}
+}
+
+// 合并各层的目录内容，同名的以前面的为准
+func (o *OverlayFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	var (
+		result []fs.DirEntry
+		found  bool
+		seen   = make(map[string]bool)
+	)
+	for _, layer := range o.layers() {
+		entries, err := fs.ReadDir(layer, name)
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				continue
+			}
+			return nil, err
+		}
+		found = true
+		for _, entry := range entries {
+			if !seen[entry.Name()] {
+				seen[entry.Name()] = true
+				result = append(result, entry)
+			}
+		}
+	}
+	if !found {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: fs.ErrNotExist}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Name() < result[j].Name()
+	})
+	return result, nil
+}
+
+func (o *OverlayFS) upper(op, name string) (WritableFS, error) {
+	if o.Upper == nil {
+		return nil, &fs.PathError{Op: op, Path: name, Err: ErrReadOnly}
+	}
+	return o.Upper, nil
+}
+
+// 只在下层存在的文件不能修改
+func (o *OverlayFS) upperOnly(op, name string) (WritableFS, error) {
+	up, err := o.upper(op, name)
+	if err != nil {
+		return nil, err
+	}
+	if _, err = up.Stat(name); err != nil {
+		if _, e := o.Stat(name); e == nil {
+			return nil, &fs.PathError{Op: op, Path: name, Err: ErrReadOnly}
+		}
+		return nil, err
+	}
+	return up, nil
+}
+
+// 在上层创建文件，上级目录只在下层存在时先在上层创建
+func (o *OverlayFS) Create(name string) (io.WriteCloser, error) {
+	up, err := o.upper("create", name)
+	if err != nil {
+		return nil, err
+	}
+	if dir := path.Dir(name); dir != "." {
+		if info, err := o.Stat(dir); err != nil {
+			return nil, err
+		} else if info.IsDir() {
+			if err = up.MkdirAll(dir, info.Mode().Perm()); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return up.Create(name)
+}
+
+func (o *OverlayFS) MkdirAll(name string, perm fs.FileMode) error {
+	up, err := o.upper("mkdir", name)
+	if err != nil {
+		return err
+	}
+	return up.MkdirAll(name, perm)
+}
+
+func (o *OverlayFS) Remove(name string) error {
+	up, err := o.upperOnly("remove", name)
+	if err != nil {
+		return err
+	}
+	return up.Remove(name)
+}
+
+func (o *OverlayFS) RemoveAll(name string) error {
+	up, err := o.upper("remove", name)
+	if err != nil {
+		return err
+	}
+	return up.RemoveAll(name)
+}
+
+func (o *OverlayFS) Rename(oldname, newname string) error {
+	up, err := o.upperOnly("rename", oldname)
+	if err != nil {
+		return err
+	}
+	return up.Rename(oldname, newname)
+}
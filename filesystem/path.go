@@ -1,10 +1,7 @@
 package filesystem
 
 import (
-	"io"
-	"io/ioutil"
 	"os"
-	"os/exec"
 	"path/filepath"
 	"strings"
 )
@@ -41,8 +38,7 @@ func MkdirForFile(path string) int64 {
 	return size
 }
 
-// 通过Bash命令复制整个目录，只能运行于Linux或MacOS
-// 当dst结尾带斜杠时，复制为dst下的子目录
+// 复制整个目录，与 cp -rf 相同，当dst结尾带斜杠或dst是已存在的目录时，复制为dst下的子目录
 func CopyDir(src, dst string) (err error) {
 	if length := len(src); src[length-1] == '/' {
 		src = src[:length-1] //去掉结尾的斜杠
@@ -51,7 +47,12 @@ func CopyDir(src, dst string) (err error) {
 	if err != nil || !info.IsDir() {
 		return
 	}
-	err = exec.Command("cp", "-rf", src, dst).Run()
+	if strings.HasSuffix(dst, "/") {
+		dst = filepath.Join(dst, filepath.Base(src))
+	} else if st, e := os.Stat(dst); e == nil && st.IsDir() {
+		dst = filepath.Join(dst, filepath.Base(src))
+	}
+	_, err = CopyTree(src, dst, nil)
 	return
 }
 
@@ -59,43 +60,11 @@ func CopyDir(src, dst string) (err error) {
 // by dst. The file will be created if it does not already exist. If the
 // destination file exists, all it's contents will be replaced by the contents
 // of the source file.
-func CopyFile(src, dst string) (err error) {
-	in, err := os.Open(src)
-	if err != nil {
-		return
-	}
-	defer in.Close()
-	out, err := os.Create(dst)
-	if err != nil {
-		return
-	}
-	defer func() {
-		cerr := out.Close()
-		if err == nil {
-			err = cerr
-		}
-	}()
-	if _, err = io.Copy(out, in); err != nil {
-		return
-	}
-	err = out.Sync()
-	return
+func CopyFile(src, dst string) error {
+	return CopyFileFS(OS, src, OS, dst)
 }
 
-// 遍历目录下的文件
+// 遍历目录下的文件，不进入子目录，需要递归或按规则查找请使用Walk
 func FindFiles(dir, ext string) (map[string]os.FileInfo, error) {
-	var result = make(map[string]os.FileInfo)
-	files, err := ioutil.ReadDir(dir)
-	if err != nil {
-		return result, err
-	}
-	for _, file := range files {
-		fname := file.Name()
-		if ext != "" && !strings.HasSuffix(fname, ext) {
-			continue
-		}
-		fname = filepath.Join(dir, fname)
-		result[fname] = file
-	}
-	return result, nil
+	return FindFilesFS(OS, dir, ext)
 }
@@ -3,6 +3,7 @@ package filesystem
 import (
 	"bufio"
 	"io"
+	"strings"
 )
 
 // 每次只保留当前行数据
@@ -13,12 +14,19 @@ type LineReader struct {
 	*bufio.Reader
 }
 
+// 压缩的文件自动解压
 func NewLineReader(fname string) *LineReader {
-	fp, _, err := OpenFile(fname, true, false)
-	return &LineReader{err: err, rd: fp, Reader: bufio.NewReader(fp)}
+	rd, _, err := OpenReader(fname)
+	if err != nil {
+		return &LineReader{err: err, Reader: bufio.NewReader(strings.NewReader(""))}
+	}
+	return &LineReader{rd: rd, Reader: bufio.NewReader(rd)}
 }
 
 func (r *LineReader) Close() error {
+	if r.rd == nil {
+		return r.err
+	}
 	r.err = r.rd.Close()
 	return r.err
 }
@@ -50,24 +58,16 @@ func (r *LineReader) Reading() bool {
 	return true
 }
 
-// 读取全部数据，按行组成列表
+// 读取全部数据，按行组成列表，压缩的文件自动解压
 func ReadLines(path string) ([]string, error) {
-	fp, _, err := OpenFile(path, true, false)
-	defer fp.Close()
-	if err != nil {
-		return nil, err
-	}
-	scanner := bufio.NewScanner(fp)
-	scanner.Split(bufio.ScanLines)
-	var result []string
-	for scanner.Scan() {
-		result = append(result, scanner.Text())
-	}
-	return result, scanner.Err()
+	return ReadLinesFS(OS, path)
 }
 
-// 读取文件末尾若干字节
+// 读取文件末尾若干字节，压缩的文件读取解压后的末尾
 func ReadFileTail(path string, size int) ([]byte, error) {
+	if IsCompressed(path) {
+		return readCompressedTail(path, size)
+	}
 	fp, _, err := OpenFile(path, true, false)
 	if err != nil {
 		return nil, err
@@ -88,3 +88,30 @@ func ReadFileTail(path string, size int) ([]byte, error) {
 	}
 	return result, err
 }
+
+// 压缩的文件无法定位，从头解压并保留最后的数据
+func readCompressedTail(path string, size int) ([]byte, error) {
+	rd, _, err := OpenReader(path)
+	if err != nil {
+		return nil, err
+	}
+	defer rd.Close()
+	buf := make([]byte, 0, size*2)
+	chunk := make([]byte, 32*1024)
+	for {
+		n, err := rd.Read(chunk)
+		buf = append(buf, chunk[:n]...)
+		if len(buf) > size*2 {
+			buf = append(buf[:0], buf[len(buf)-size:]...)
+		}
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+	}
+	if len(buf) > size {
+		buf = buf[len(buf)-size:]
+	}
+	return buf, nil
+}
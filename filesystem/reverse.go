@@ -0,0 +1,130 @@
+package filesystem
+
+import (
+	"bytes"
+	"os"
+)
+
+// 每次从文件读取的字节数
+const REVERSE_CHUNK_SIZE = 64 * 1024
+
+// 从文件末尾开始逐行向前读取，用法与LineReader相同
+type ReverseLineReader struct {
+	err     error
+	line    []byte
+	fp      *os.File
+	pos     int64  // 之前的数据还没有读取
+	buf     []byte // 已读取但未返回的数据
+	pending bool   // buf中还有一行，可能为空行
+	tail    bool   // 还没有返回过任何行
+}
+
+func NewReverseLineReader(fname string) *ReverseLineReader {
+	r := &ReverseLineReader{tail: true}
+	fp, size, err := OpenFile(fname, true, false)
+	if err == nil && fp == nil {
+		err = &os.PathError{Op: "open", Path: fname, Err: os.ErrNotExist}
+	}
+	r.err, r.fp, r.pos, r.pending = err, fp, size, size > 0
+	return r
+}
+
+func (r *ReverseLineReader) Close() error {
+	if r.fp == nil {
+		return r.err
+	}
+	r.err = r.fp.Close()
+	return r.err
+}
+
+func (r *ReverseLineReader) Err() error {
+	return r.err
+}
+
+func (r *ReverseLineReader) Line() []byte {
+	return r.line
+}
+
+func (r *ReverseLineReader) Text() string {
+	return string(r.line)
+}
+
+// 读取前一行，到达文件开头或出错时返回false
+func (r *ReverseLineReader) Reading() bool {
+	if r.fp == nil || r.err != nil {
+		return false
+	}
+	for {
+		if idx := bytes.LastIndexByte(r.buf, '\n'); idx >= 0 {
+			line := r.buf[idx+1:]
+			r.buf = r.buf[:idx]
+			if r.tail && len(line) == 0 {
+				r.tail = false // 文件以换行符结尾，不算一行
+				continue
+			}
+			r.setLine(line)
+			return true
+		}
+		if r.pos == 0 {
+			if !r.pending {
+				return false
+			}
+			r.pending = false
+			r.setLine(r.buf)
+			r.buf = r.buf[:0]
+			return true
+		}
+		n := int64(REVERSE_CHUNK_SIZE)
+		if n > r.pos {
+			n = r.pos
+		}
+		r.pos -= n
+		chunk := make([]byte, n, n+int64(len(r.buf)))
+		if _, err := r.fp.ReadAt(chunk, r.pos); err != nil {
+			r.err = err
+			return false
+		}
+		r.buf = append(chunk, r.buf...)
+	}
+}
+
+func (r *ReverseLineReader) setLine(line []byte) {
+	r.tail = false
+	r.line = append(r.line[:0], bytes.TrimRight(line, "\r")...)
+}
+
+// 读取文件最后n行，按原来的顺序返回，压缩的文件从头解压
+func TailLines(path string, n int) ([]string, error) {
+	if IsCompressed(path) {
+		return tailCompressedLines(path, n)
+	}
+	r := NewReverseLineReader(path)
+	defer r.Close()
+	var result []string
+	for len(result) < n && r.Reading() {
+		result = append(result, r.Text())
+	}
+	for i, j := 0, len(result)-1; i < j; i, j = i+1, j-1 {
+		result[i], result[j] = result[j], result[i]
+	}
+	return result, r.Err()
+}
+
+func tailCompressedLines(path string, n int) ([]string, error) {
+	if n <= 0 {
+		return nil, nil
+	}
+	r := NewLineReader(path)
+	defer r.Close()
+	var result []string
+	for r.Reading() {
+		if len(result) >= n*2 {
+			result = append(result[:0], result[len(result)-n+1:]...)
+		}
+		result = append(result, r.Text())
+	}
+	if len(result) > n {
+		result = result[len(result)-n:]
+	}
+	return result, r.Err()
+}
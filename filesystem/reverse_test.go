@@ -0,0 +1,66 @@
+package filesystem
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func reverseLines(fname string) ([]string, error) {
+	var lines []string
+	r := NewReverseLineReader(fname)
+	defer r.Close()
+	for r.Reading() {
+		lines = append(lines, r.Text())
+	}
+	return lines, r.Err()
+}
+
+func TestReverseLineReader(t *testing.T) {
+	tmp, _ := ioutil.TempDir("", "reverse")
+	defer os.RemoveAll(tmp)
+	fname := filepath.Join(tmp, "a.log")
+	cases := map[string][]string{
+		"":             nil,
+		"\n":           {""},
+		"a":            {"a"},
+		"a\nb\n":       {"b", "a"},
+		"a\r\n\nb":     {"b", "", "a"},
+		"\n\nlast\n":   {"last", "", ""},
+		"one\ntwo\n\n": {"", "two", "one"},
+	}
+	for content, want := range cases {
+		ioutil.WriteFile(fname, []byte(content), FILE_MODE)
+		lines, err := reverseLines(fname)
+		assert.NoError(t, err)
+		assert.Equal(t, want, lines, "%q", content)
+		assert.Equal(t, strings.Count(content, "\n"), LineCount(fname), "%q", content)
+	}
+
+	// 跨越多个块的长行
+	var sb strings.Builder
+	for i := 0; i < 5000; i++ {
+		fmt.Fprintf(&sb, "%d %s\n", i, strings.Repeat("x", i%50))
+	}
+	long := strings.Repeat("y", REVERSE_CHUNK_SIZE*2+7)
+	sb.WriteString(long + "\n")
+	ioutil.WriteFile(fname, []byte(sb.String()), FILE_MODE)
+	lines, err := TailLines(fname, 3)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"4998 " + strings.Repeat("x", 48),
+		"4999 " + strings.Repeat("x", 49), long}, lines)
+	lines, err = reverseLines(fname)
+	assert.NoError(t, err)
+	assert.Len(t, lines, 5001)
+	assert.Equal(t, "0 ", lines[5000])
+	assert.Equal(t, 5001, LineCount(fname))
+
+	_, err = TailLines(filepath.Join(tmp, "missing"), 3)
+	assert.Error(t, err)
+	assert.Equal(t, -1, LineCount(filepath.Join(tmp, "missing")))
+}
@@ -0,0 +1,237 @@
+package filesystem
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// 只读的文件系统不能写入
+var ErrReadOnly = errors.New("read-only file system")
+
+// 可写的文件系统，读取部分与 fs.FS 兼容
+type WritableFS interface {
+	fs.StatFS
+	Create(name string) (io.WriteCloser, error) // 创建或清空文件，上级目录必须存在
+	MkdirAll(name string, perm fs.FileMode) error
+	Remove(name string) error
+	RemoveAll(name string) error
+	Rename(oldname, newname string) error
+}
+
+// 本地文件系统，Root为空时直接使用本机路径，此时路径不必符合 fs.ValidPath
+type OsFS struct {
+	Root string
+}
+
+// 本机路径的文件系统，包内不带FS后缀的函数都使用它
+var OS = &OsFS{}
+
+// 以root为根目录的本地文件系统
+func NewOsFS(root string) *OsFS {
+	return &OsFS{Root: root}
+}
+
+func (f *OsFS) path(op, name string) (string, error) {
+	if f.Root == "" {
+		return name, nil
+	}
+	if !fs.ValidPath(name) {
+		return "", &fs.PathError{Op: op, Path: name, Err: fs.ErrInvalid}
+	}
+	return filepath.Join(f.Root, filepath.FromSlash(name)), nil
+}
+
+func (f *OsFS) Open(name string) (fs.File, error) {
+	fpath, err := f.path("open", name)
+	if err != nil {
+		return nil, err
+	}
+	fp, err := os.Open(fpath)
+	if err != nil {
+		return nil, err // 返回nil接口，而不是nil的*os.File
+	}
+	return fp, nil
+}
+
+func (f *OsFS) Stat(name string) (fs.FileInfo, error) {
+	fpath, err := f.path("stat", name)
+	if err != nil {
+		return nil, err
+	}
+	return os.Stat(fpath)
+}
+
+func (f *OsFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	fpath, err := f.path("readdir", name)
+	if err != nil {
+		return nil, err
+	}
+	return os.ReadDir(fpath)
+}
+
+func (f *OsFS) ReadFile(name string) ([]byte, error) {
+	fpath, err := f.path("readfile", name)
+	if err != nil {
+		return nil, err
+	}
+	return os.ReadFile(fpath)
+}
+
+func (f *OsFS) Create(name string) (io.WriteCloser, error) {
+	fpath, err := f.path("create", name)
+	if err != nil {
+		return nil, err
+	}
+	fp, err := os.OpenFile(fpath, os.O_RDWR|os.O_CREATE|os.O_TRUNC, FILE_MODE)
+	if err != nil {
+		return nil, err
+	}
+	return fp, nil
+}
+
+func (f *OsFS) MkdirAll(name string, perm fs.FileMode) error {
+	fpath, err := f.path("mkdir", name)
+	if err != nil {
+		return err
+	}
+	return os.MkdirAll(fpath, perm)
+}
+
+func (f *OsFS) Remove(name string) error {
+	fpath, err := f.path("remove", name)
+	if err != nil {
+		return err
+	}
+	return os.Remove(fpath)
+}
+
+func (f *OsFS) RemoveAll(name string) error {
+	fpath, err := f.path("remove", name)
+	if err != nil {
+		return err
+	}
+	return os.RemoveAll(fpath)
+}
+
+func (f *OsFS) Rename(oldname, newname string) error {
+	oldpath, err := f.path("rename", oldname)
+	if err != nil {
+		return err
+	}
+	newpath, err := f.path("rename", newname)
+	if err != nil {
+		return err
+	}
+	return os.Rename(oldpath, newpath)
+}
+
+func joinPath(fsys fs.FS, dir, name string) string {
+	if f, ok := fsys.(*OsFS); ok && f.Root == "" {
+		return filepath.Join(dir, name)
+	}
+	return path.Join(dir, name)
+}
+
+// 与FileSize相同，-1, false 不合法的路径；0, false 不存在；-1, true 目录；>=0, true 文件
+func FileSizeFS(fsys fs.FS, name string) (int64, bool) {
+	if name == "" {
+		return -1, false
+	}
+	info, err := fs.Stat(fsys, name)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return 0, false
+		}
+		return -1, false
+	}
+	if info.IsDir() {
+		return -1, true
+	}
+	return info.Size(), true
+}
+
+// 读取全部数据，按行组成列表，压缩的文件自动解压
+func ReadLinesFS(fsys fs.FS, name string) ([]string, error) {
+	fp, err := fsys.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	rd, _, err := NewDecompressReader(fp)
+	if err != nil {
+		fp.Close()
+		return nil, err
+	}
+	defer rd.Close()
+	scanner := bufio.NewScanner(rd)
+	scanner.Split(bufio.ScanLines)
+	var result []string
+	for scanner.Scan() {
+		result = append(result, scanner.Text())
+	}
+	return result, scanner.Err()
+}
+
+// 目录下以ext结尾的文件和子目录，不进入子目录
+func FindFilesFS(fsys fs.FS, dir, ext string) (map[string]fs.FileInfo, error) {
+	var result = make(map[string]fs.FileInfo)
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return result, err
+	}
+	for _, entry := range entries {
+		fname := entry.Name()
+		if ext != "" && !strings.HasSuffix(fname, ext) {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return result, err
+		}
+		result[joinPath(fsys, dir, fname)] = info
+	}
+	return result, nil
+}
+
+// 在文件系统之间复制文件，与CopyFile一样，目标的上级目录不存在时出错
+func CopyFileFS(srcFS fs.FS, src string, dstFS WritableFS, dst string) (err error) {
+	in, err := srcFS.Open(src)
+	if err != nil {
+		return
+	}
+	defer in.Close()
+	out, err := dstFS.Create(dst)
+	if err != nil {
+		return
+	}
+	defer func() {
+		if cerr := out.Close(); err == nil {
+			err = cerr
+		}
+	}()
+	if _, err = io.Copy(out, in); err != nil {
+		return
+	}
+	if s, ok := out.(interface{ Sync() error }); ok {
+		err = s.Sync()
+	}
+	return
+}
+
+// 写入整个文件
+func WriteFileFS(fsys WritableFS, name string, data []byte) error {
+	w, err := fsys.Create(name)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(data)
+	if cerr := w.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
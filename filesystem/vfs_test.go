@@ -0,0 +1,127 @@
+package filesystem
+
+import (
+	"embed"
+	"errors"
+	"io/fs"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+)
+
+//go:embed read.go file.go
+var embedded embed.FS
+
+func TestMemFS(t *testing.T) {
+	m := NewMemFS()
+	assert.NoError(t, m.MkdirAll("conf/app", DIR_MODE))
+	assert.NoError(t, WriteFileFS(m, "conf/app/a.ini", []byte("a=1\nb=2\n")))
+	assert.NoError(t, WriteFileFS(m, "conf/b.ini", []byte("b")))
+	assert.Error(t, WriteFileFS(m, "missing/c.ini", nil))
+	assert.NoError(t, fstest.TestFS(m, "conf/app/a.ini", "conf/b.ini"))
+
+	size, ok := FileSizeFS(m, "conf/app/a.ini")
+	assert.True(t, ok)
+	assert.Equal(t, int64(8), size)
+	size, ok = FileSizeFS(m, "conf")
+	assert.True(t, ok && size < 0)
+	_, ok = FileSizeFS(m, "none")
+	assert.False(t, ok)
+	lines, err := ReadLinesFS(m, "conf/app/a.ini")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"a=1", "b=2"}, lines)
+	files, err := FindFilesFS(m, "conf", ".ini")
+	assert.NoError(t, err)
+	assert.Len(t, files, 1)
+	assert.Contains(t, files, "conf/b.ini")
+
+	assert.Error(t, CopyFileFS(m, "conf/b.ini", m, "backup/b.ini")) // 不会创建目录
+	m.MkdirAll("backup", DIR_MODE)
+	assert.NoError(t, CopyFileFS(m, "conf/b.ini", m, "backup/b.ini"))
+	data, _ := m.ReadFile("backup/b.ini")
+	assert.Equal(t, "b", string(data))
+	assert.Error(t, m.Remove("conf"))
+	assert.NoError(t, m.Rename("conf", "etc"))
+	_, err = m.Stat("etc/app/a.ini")
+	assert.NoError(t, err)
+	assert.NoError(t, m.RemoveAll("etc"))
+	entries, _ := m.ReadDir(".")
+	assert.Len(t, entries, 1)
+	_, err = m.Open("../x")
+	assert.Error(t, err)
+
+	// 写入的同时可以Stat，已取得的信息不会变化
+	w, _ := m.Create("log.txt")
+	info, _ := m.Stat("log.txt")
+	done := make(chan struct{})
+	go func() {
+		for i := 0; i < 100; i++ {
+			w.Write([]byte("x"))
+		}
+		close(done)
+	}()
+	for i := 0; i < 100; i++ {
+		if fi, err := m.Stat("log.txt"); assert.NoError(t, err) {
+			fi.Size()
+			fi.ModTime()
+		}
+	}
+	<-done
+	w.Close()
+	assert.Equal(t, int64(0), info.Size())
+	info, _ = m.Stat("log.txt")
+	assert.Equal(t, int64(100), info.Size())
+}
+
+func TestOverlayFS(t *testing.T) {
+	ro := ReadOnlyFS(embedded)
+	assert.NoError(t, fstest.TestFS(ro, "read.go", "file.go"))
+	lines, err := ReadLinesFS(ro, "read.go")
+	assert.NoError(t, err)
+	assert.Equal(t, "package filesystem", lines[0])
+	err = WriteFileFS(ro, "new.go", nil)
+	assert.True(t, errors.Is(err, ErrReadOnly))
+
+	// 写入在上层，读取时上层优先
+	upper := NewMemFS()
+	o := NewOverlayFS(upper, embedded)
+	assert.NoError(t, WriteFileFS(o, "read.go", []byte("package custom\n")))
+	assert.NoError(t, WriteFileFS(o, "extra.go", []byte("package extra\n")))
+	lines, _ = ReadLinesFS(o, "read.go")
+	assert.Equal(t, []string{"package custom"}, lines)
+	files, err := FindFilesFS(o, ".", ".go")
+	assert.NoError(t, err)
+	assert.Len(t, files, 3)
+	assert.Equal(t, int64(15), files["read.go"].Size())
+	assert.NoError(t, fstest.TestFS(o, "read.go", "file.go", "extra.go"))
+	assert.True(t, errors.Is(o.Remove("file.go"), ErrReadOnly))
+	assert.NoError(t, o.Remove("read.go"))
+	lines, _ = ReadLinesFS(o, "read.go")
+	assert.Equal(t, "package filesystem", lines[0])
+}
+
+func TestOsFS(t *testing.T) {
+	tmp, _ := ioutil.TempDir("", "vfs")
+	defer os.RemoveAll(tmp)
+	f := NewOsFS(tmp)
+	f.MkdirAll("src", DIR_MODE)
+	assert.NoError(t, CopyFileFS(embedded, "read.go", f, "src/read.go"))
+	assert.NoError(t, fstest.TestFS(f, "src/read.go"))
+	assert.Equal(t, LineCount("read.go"), LineCount(filepath.Join(tmp, "src", "read.go")))
+	_, err := f.Open("/etc/passwd")
+	assert.True(t, errors.Is(err, fs.ErrInvalid))
+
+	// 从内存复制到本机路径
+	m := NewMemFS()
+	WriteFileFS(m, "a.txt", []byte("memory"))
+	fname := filepath.Join(tmp, "out", "a.txt")
+	assert.Error(t, CopyFile(filepath.Join(tmp, "src", "read.go"), fname))
+	os.Mkdir(filepath.Dir(fname), DIR_MODE)
+	assert.NoError(t, CopyFileFS(m, "a.txt", OS, fname))
+	size, _ := FileSize(fname)
+	assert.Equal(t, int64(6), size)
+}
@@ -0,0 +1,263 @@
+package filesystem
+
+import (
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// 遍历返回的类型
+const (
+	WALK_FILE = 1 << iota
+	WALK_DIR
+	WALK_LINK
+	WALK_OTHER // 设备、管道、socket等
+	WALK_ALL   = WALK_FILE | WALK_DIR | WALK_LINK | WALK_OTHER
+)
+
+// 遍历目录的选项，路径规则见 MatchGlob，匹配斜杠分隔的相对路径
+type WalkOptions struct {
+	MaxDepth   int      // 最大层数，1为只有直接下级，0为不限
+	Include    []string // 只返回匹配的项，目录仍然会进入
+	Exclude    []string // 跳过匹配的项，目录不再进入
+	Types      int      // 返回的类型，0为全部
+	Hidden     bool     // 包括以点开头的隐藏文件和目录
+	MinSize    int64    // 文件大小下限，只对普通文件有效
+	MaxSize    int64    // 文件大小上限，0为不限
+	ModAfter   time.Time
+	ModBefore  time.Time
+	IgnoreFile string // 每层目录中的忽略规则文件，如 .gitignore
+	Filter     func(e *WalkEntry) bool
+	OnError    func(path string, err error) error // 读目录出错时调用，返回nil则跳过该目录
+}
+
+// 遍历到的文件或目录
+type WalkEntry struct {
+	Path  string // 包括根目录的路径
+	Rel   string // 相对于根目录，斜杠分隔
+	Depth int
+	fs.DirEntry
+}
+
+type scopedRules struct {
+	base  string // 规则文件所在目录的相对路径
+	rules *IgnoreRules
+}
+
+type walkFrame struct {
+	rel     string
+	depth   int
+	entries []fs.DirEntry
+	index   int
+	ignores []scopedRules
+}
+
+// 按名称顺序深度优先遍历目录，每层只读取一个目录，适合海量文件
+//
+//	w := NewWalker("/var/log", &WalkOptions{Include: []string{"**/*.log"}, Types: WALK_FILE})
+//	for w.Next() {
+//		fmt.Println(w.Entry().Path)
+//	}
+//	err := w.Err()
+type Walker struct {
+	Root    string
+	opts    WalkOptions
+	stack   []*walkFrame
+	entry   *WalkEntry
+	pending *walkFrame // 上一个返回的目录，下次进入
+	started bool
+	err     error
+}
+
+func NewWalker(root string, opts *WalkOptions) *Walker {
+	w := &Walker{Root: root}
+	if opts != nil {
+		w.opts = *opts
+	}
+	if w.opts.Types == 0 {
+		w.opts.Types = WALK_ALL
+	}
+	return w
+}
+
+func (w *Walker) Entry() *WalkEntry {
+	return w.entry
+}
+
+func (w *Walker) Err() error {
+	return w.err
+}
+
+// 不进入刚返回的目录
+func (w *Walker) SkipDir() {
+	w.pending = nil
+}
+
+// 遍历下一项，结束或出错时返回false
+func (w *Walker) Next() bool {
+	if w.err != nil {
+		return false
+	}
+	if !w.started {
+		w.started = true
+		if w.err = w.push(&walkFrame{}, nil); w.err != nil {
+			return false
+		}
+	}
+	if w.pending != nil {
+		frame := w.pending
+		w.pending = nil
+		if w.err = w.push(frame, w.stack[len(w.stack)-1].ignores); w.err != nil {
+			return false
+		}
+	}
+	for len(w.stack) > 0 {
+		top := w.stack[len(w.stack)-1]
+		if top.index >= len(top.entries) {
+			w.stack = w.stack[:len(w.stack)-1]
+			continue
+		}
+		de := top.entries[top.index]
+		top.index++
+		name := de.Name()
+		if !w.opts.Hidden && strings.HasPrefix(name, ".") {
+			continue
+		}
+		rel := path.Join(top.rel, name)
+		isDir := de.IsDir()
+		if ignoredBy(top.ignores, rel, isDir) || matchGlobs(w.opts.Exclude, rel) {
+			continue
+		}
+		e := &WalkEntry{Path: filepath.Join(w.Root, filepath.FromSlash(rel)),
+			Rel: rel, Depth: top.depth + 1, DirEntry: de}
+		var sub *walkFrame
+		if isDir && (w.opts.MaxDepth <= 0 || e.Depth < w.opts.MaxDepth) {
+			sub = &walkFrame{rel: rel, depth: e.Depth}
+		}
+		if w.accept(e) {
+			w.entry, w.pending = e, sub
+			return true
+		}
+		if sub != nil {
+			if w.err = w.push(sub, top.ignores); w.err != nil {
+				return false
+			}
+		}
+	}
+	w.entry = nil
+	return false
+}
+
+// 读取目录并压栈，出错时交给OnError处理
+func (w *Walker) push(frame *walkFrame, ignores []scopedRules) error {
+	dir := filepath.Join(w.Root, filepath.FromSlash(frame.rel))
+	entries, err := os.ReadDir(dir)
+	if err == nil && w.opts.IgnoreFile != "" {
+		var rules *IgnoreRules
+		rules, err = ReadIgnoreFile(filepath.Join(dir, w.opts.IgnoreFile))
+		if rules != nil {
+			ignores = append(ignores[:len(ignores):len(ignores)],
+				scopedRules{base: frame.rel, rules: rules})
+		}
+	}
+	if err != nil {
+		if w.opts.OnError != nil && frame.rel != "" {
+			return w.opts.OnError(dir, err)
+		}
+		return err
+	}
+	frame.entries, frame.ignores = entries, ignores
+	w.stack = append(w.stack, frame)
+	return nil
+}
+
+func (w *Walker) accept(e *WalkEntry) bool {
+	mode := e.Type()
+	var kind int
+	switch {
+	case mode.IsDir():
+		kind = WALK_DIR
+	case mode&fs.ModeSymlink != 0:
+		kind = WALK_LINK
+	case mode.IsRegular():
+		kind = WALK_FILE
+	default:
+		kind = WALK_OTHER
+	}
+	if w.opts.Types&kind == 0 {
+		return false
+	}
+	if len(w.opts.Include) > 0 && !matchGlobs(w.opts.Include, e.Rel) {
+		return false
+	}
+	o := w.opts
+	if o.MinSize > 0 || o.MaxSize > 0 || !o.ModAfter.IsZero() || !o.ModBefore.IsZero() {
+		info, err := e.Info()
+		if err != nil {
+			return false
+		}
+		if kind == WALK_FILE {
+			if info.Size() < o.MinSize || (o.MaxSize > 0 && info.Size() > o.MaxSize) {
+				return false
+			}
+		}
+		if !o.ModAfter.IsZero() && !info.ModTime().After(o.ModAfter) {
+			return false
+		}
+		if !o.ModBefore.IsZero() && !info.ModTime().Before(o.ModBefore) {
+			return false
+		}
+	}
+	return o.Filter == nil || o.Filter(e)
+}
+
+// 由深到浅检查各层的忽略规则，最近的规则优先
+func ignoredBy(ignores []scopedRules, rel string, isDir bool) bool {
+	for i := len(ignores) - 1; i >= 0; i-- {
+		scope := ignores[i]
+		sub := rel
+		if scope.base != "" {
+			sub = strings.TrimPrefix(rel, scope.base+"/")
+		}
+		if ignored, matched := scope.rules.Match(sub, isDir); matched {
+			return ignored
+		}
+	}
+	return false
+}
+
+func matchGlobs(patterns []string, rel string) bool {
+	for _, pat := range patterns {
+		if MatchGlob(pat, rel) {
+			return true
+		}
+	}
+	return false
+}
+
+// 遍历目录，fn返回filepath.SkipDir时不进入该目录，返回其他错误时停止
+func Walk(root string, opts *WalkOptions, fn func(e *WalkEntry) error) error {
+	w := NewWalker(root, opts)
+	for w.Next() {
+		if err := fn(w.Entry()); err == filepath.SkipDir {
+			w.SkipDir()
+		} else if err != nil {
+			return err
+		}
+	}
+	return w.Err()
+}
+
+// 查找匹配的文件，返回排序的路径
+func Glob(root, pattern string) ([]string, error) {
+	var result []string
+	opts := &WalkOptions{Include: []string{pattern}, Types: WALK_FILE}
+	err := Walk(root, opts, func(e *WalkEntry) error {
+		result = append(result, e.Path)
+		return nil
+	})
+	return result, err
+}
@@ -0,0 +1,104 @@
+package filesystem
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMatchGlob(t *testing.T) {
+	assert.True(t, MatchGlob("**/*.log", "a.log"))
+	assert.True(t, MatchGlob("**/*.log", "x/y/a.log"))
+	assert.False(t, MatchGlob("**/*.log", "x/y/a.txt"))
+	assert.True(t, MatchGlob("src/**/test/*.go", "src/test/a.go"))
+	assert.True(t, MatchGlob("src/**/test/*.go", "src/a/b/test/a.go"))
+	assert.False(t, MatchGlob("src/*/test/*.go", "src/a/b/test/a.go"))
+	assert.True(t, MatchGlob("logs/**", "logs/a/b"))
+	assert.False(t, MatchGlob("*.log", "x/a.log"))
+}
+
+func TestIgnoreRules(t *testing.T) {
+	r := ParseIgnore([]string{"# comment", "*.tmp", "!keep.tmp", "/build", "docs/*.pdf", "cache/"})
+	cases := []struct {
+		rel     string
+		isDir   bool
+		ignored bool
+	}{
+		{"a.tmp", false, true}, {"x/y/b.tmp", false, true}, {"x/keep.tmp", false, false},
+		{"build", true, true}, {"src/build", true, false}, {"docs/a.pdf", false, true},
+		{"x/docs/a.pdf", false, false}, {"cache", true, true}, {"cache", false, false},
+	}
+	for _, c := range cases {
+		ignored, _ := r.Match(c.rel, c.isDir)
+		assert.Equal(t, c.ignored, ignored, c.rel)
+	}
+}
+
+func walkRels(t *testing.T, root string, opts *WalkOptions) []string {
+	var rels []string
+	err := Walk(root, opts, func(e *WalkEntry) error {
+		rels = append(rels, e.Rel)
+		return nil
+	})
+	assert.NoError(t, err)
+	return rels
+}
+
+func TestWalk(t *testing.T) {
+	tmp, _ := ioutil.TempDir("", "walk")
+	defer os.RemoveAll(tmp)
+	makeTree(t, tmp, map[string]string{
+		"b.log": "bb", "a.txt": "a", ".hidden/x.log": "x", "sub/c.log": "cccc",
+		"sub/deep/d.log": "d", "sub/deep/e.tmp": "e", "vendor/v.log": "v",
+		".gitignore": "*.tmp\nvendor/\n", "sub/.gitignore": "!e.tmp\nc.log\n",
+	})
+	os.Symlink("a.txt", filepath.Join(tmp, "link"))
+
+	assert.Equal(t, []string{"a.txt", "b.log", "link", "sub", "sub/c.log", "sub/deep",
+		"sub/deep/d.log", "sub/deep/e.tmp", "vendor", "vendor/v.log"}, walkRels(t, tmp, nil))
+	assert.Equal(t, []string{"b.log", "sub/deep/d.log"}, walkRels(t, tmp, &WalkOptions{
+		Include: []string{"**/*.log"}, IgnoreFile: ".gitignore"}))
+	assert.Equal(t, []string{"sub/deep/e.tmp"}, walkRels(t, tmp, &WalkOptions{
+		Include: []string{"**/*.tmp"}, IgnoreFile: ".gitignore"}))
+	assert.Equal(t, []string{"a.txt", "b.log", "link", "sub", "vendor"},
+		walkRels(t, tmp, &WalkOptions{MaxDepth: 1}))
+	assert.Equal(t, []string{".gitignore", ".hidden", "a.txt", "b.log", "link", "sub", "vendor"},
+		walkRels(t, tmp, &WalkOptions{MaxDepth: 1, Hidden: true}))
+	assert.Equal(t, []string{"sub", "sub/deep"}, walkRels(t, tmp, &WalkOptions{Types: WALK_DIR,
+		Exclude: []string{"vendor"}}))
+	assert.Equal(t, []string{"link"}, walkRels(t, tmp, &WalkOptions{Types: WALK_LINK}))
+	assert.Equal(t, []string{"b.log", "sub/c.log"}, walkRels(t, tmp, &WalkOptions{
+		Types: WALK_FILE, MinSize: 2, MaxSize: 4}))
+
+	old := time.Now().Add(-time.Hour)
+	os.Chtimes(filepath.Join(tmp, "a.txt"), old, old)
+	assert.Equal(t, []string{"a.txt"}, walkRels(t, tmp, &WalkOptions{
+		Types: WALK_FILE, ModBefore: time.Now().Add(-time.Minute)}))
+
+	// 跳过目录
+	var rels []string
+	err := Walk(tmp, nil, func(e *WalkEntry) error {
+		rels = append(rels, e.Rel)
+		if e.Rel == "sub" {
+			return filepath.SkipDir
+		}
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"a.txt", "b.log", "link", "sub", "vendor", "vendor/v.log"}, rels)
+
+	paths, err := Glob(tmp, "sub/**/*.log")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{filepath.Join(tmp, "sub", "c.log"),
+		filepath.Join(tmp, "sub", "deep", "d.log")}, paths)
+
+	files, err := FindFiles(tmp, ".log")
+	assert.NoError(t, err)
+	assert.Len(t, files, 1)
+	_, err = FindFiles(filepath.Join(tmp, "missing"), "")
+	assert.Error(t, err)
+}
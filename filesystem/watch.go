@@ -0,0 +1,340 @@
+package filesystem
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 文件变化的类型，合并后可能同时有多种
+type EventOp int
+
+const (
+	EVENT_CREATE EventOp = 1 << iota
+	EVENT_WRITE
+	EVENT_REMOVE
+	EVENT_RENAME // 改名前的路径，改名后的路径为EVENT_CREATE
+)
+
+func (op EventOp) String() string {
+	var names []string
+	for i, name := range []string{"create", "write", "remove", "rename"} {
+		if op&(1<<i) != 0 {
+			names = append(names, name)
+		}
+	}
+	return strings.Join(names, "|")
+}
+
+type Event struct {
+	Path string
+	Op   EventOp
+}
+
+func (e Event) String() string {
+	return e.Op.String() + " " + e.Path
+}
+
+// 监视的选项
+type WatchOptions struct {
+	Recursive bool          // 监视子目录，包括之后新建的
+	Debounce  time.Duration // 同一路径从第一次变化起这段时间内的变化合并为一个事件，0为不合并
+	Poll      bool          // 强制使用轮询，用于网络文件系统等
+	Interval  time.Duration // 轮询间隔
+}
+
+// 监视文件和目录的变化，Linux下使用inotify，其他系统或inotify不可用时轮询
+//
+//	w, _ := NewWatcher(&WatchOptions{Recursive: true, Debounce: 100 * time.Millisecond})
+//	w.Add("./conf")
+//	for ev := range w.Events {
+//		fmt.Println(ev)
+//	}
+type Watcher struct {
+	Events  chan Event
+	Errors  chan error
+	opts    WatchOptions
+	backend watchBackend
+	polling bool
+	raw     chan Event
+	done    chan struct{}
+	stopped chan struct{}
+	once    sync.Once
+}
+
+type watchBackend interface {
+	add(path string) error
+	remove(path string) error
+	close() error // 返回前确保不再发出事件
+}
+
+func NewWatcher(opts *WatchOptions) (*Watcher, error) {
+	w := &Watcher{
+		Events: make(chan Event), Errors: make(chan error, 16),
+		raw: make(chan Event, 64), done: make(chan struct{}), stopped: make(chan struct{}),
+	}
+	if opts != nil {
+		w.opts = *opts
+	}
+	if w.opts.Interval <= 0 {
+		w.opts.Interval = time.Second
+	}
+	var err error
+	if !w.opts.Poll {
+		w.backend, err = newNativeBackend(w)
+	}
+	if w.opts.Poll || err != nil {
+		w.backend, w.polling = newPollBackend(w), true
+	}
+	go w.debounce()
+	return w, nil
+}
+
+// 是否在使用轮询
+func (w *Watcher) Polling() bool {
+	return w.polling
+}
+
+// 添加监视的文件或目录
+func (w *Watcher) Add(path string) error {
+	path = filepath.Clean(path)
+	if _, err := os.Lstat(path); err != nil {
+		return err
+	}
+	return w.backend.add(path)
+}
+
+// 停止监视，递归监视时包括其子目录
+func (w *Watcher) Remove(path string) error {
+	return w.backend.remove(filepath.Clean(path))
+}
+
+// 停止监视，Events通道随后关闭
+func (w *Watcher) Close() (err error) {
+	w.once.Do(func() {
+		close(w.done)
+		err = w.backend.close()
+		close(w.raw)
+		<-w.stopped
+	})
+	return
+}
+
+func (w *Watcher) emit(ev Event) {
+	select {
+	case w.raw <- ev:
+	case <-w.done:
+	}
+}
+
+func (w *Watcher) fail(err error) {
+	select {
+	case w.Errors <- err:
+	default: // 没有人读取时丢弃
+	}
+}
+
+// 等待中的事件，到期时间为第一次变化的时间加上Debounce
+type pendingEvent struct {
+	op       EventOp
+	deadline time.Time
+}
+
+// 合并同一路径的事件，每个路径在第一次变化后Debounce时间内发出
+// 按到期时间而不是最后一次变化计时，其他文件持续变化时也不会一直等待
+func (w *Watcher) debounce() {
+	defer close(w.stopped)
+	defer close(w.Events)
+	pending := make(map[string]*pendingEvent)
+	timer := time.NewTimer(time.Hour)
+	stopTimer(timer)
+	armed := false
+	flush := func(now time.Time) {
+		var paths []string
+		next := time.Time{}
+		for path, pe := range pending {
+			if !pe.deadline.After(now) {
+				paths = append(paths, path)
+			} else if next.IsZero() || pe.deadline.Before(next) {
+				next = pe.deadline
+			}
+		}
+		sort.Strings(paths)
+		for _, path := range paths {
+			if !w.send(Event{Path: path, Op: pending[path].op}) {
+				return
+			}
+			delete(pending, path)
+		}
+		if !next.IsZero() {
+			timer.Reset(time.Until(next))
+			armed = true
+		}
+	}
+	for {
+		select {
+		case ev, ok := <-w.raw:
+			if !ok {
+				return
+			}
+			if w.opts.Debounce <= 0 {
+				w.send(ev)
+				continue
+			}
+			if pe, ok := pending[ev.Path]; ok {
+				pe.op |= ev.Op
+				continue
+			}
+			pending[ev.Path] = &pendingEvent{op: ev.Op, deadline: time.Now().Add(w.opts.Debounce)}
+			// 后加入的路径到期时间更晚，计时器已启动时不用调整
+			if !armed {
+				stopTimer(timer)
+				timer.Reset(w.opts.Debounce)
+				armed = true
+			}
+		case now := <-timer.C:
+			armed = false
+			flush(now)
+		}
+	}
+}
+
+// 停止计时器并清空通道，之后可以安全地Reset
+func stopTimer(t *time.Timer) {
+	if !t.Stop() {
+		select {
+		case <-t.C:
+		default:
+		}
+	}
+}
+
+func (w *Watcher) send(ev Event) bool {
+	select {
+	case w.Events <- ev:
+		return true
+	case <-w.done:
+		return false
+	}
+}
+
+type pollState struct {
+	size    int64
+	modTime time.Time
+	isDir   bool
+}
+
+// 轮询，定时比较文件的大小和修改时间，改名表现为删除和新建
+type pollBackend struct {
+	w     *Watcher
+	roots map[string]bool
+	state map[string]pollState
+	mutex sync.Mutex
+	quit  chan struct{}
+	wg    sync.WaitGroup
+}
+
+func newPollBackend(w *Watcher) *pollBackend {
+	b := &pollBackend{w: w, roots: make(map[string]bool),
+		state: make(map[string]pollState), quit: make(chan struct{})}
+	b.wg.Add(1)
+	go b.loop()
+	return b
+}
+
+func (b *pollBackend) add(path string) error {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.roots[path] = true
+	return b.scan(path, b.state)
+}
+
+func (b *pollBackend) remove(path string) error {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	delete(b.roots, path)
+	prefix := path + string(filepath.Separator)
+	for p := range b.state {
+		if p == path || strings.HasPrefix(p, prefix) {
+			delete(b.state, p)
+		}
+	}
+	return nil
+}
+
+func (b *pollBackend) close() error {
+	close(b.quit)
+	b.wg.Wait()
+	return nil
+}
+
+func (b *pollBackend) scan(root string, state map[string]pollState) error {
+	info, err := os.Lstat(root)
+	if err != nil {
+		return err
+	}
+	state[root] = pollState{size: info.Size(), modTime: info.ModTime(), isDir: info.IsDir()}
+	if !info.IsDir() {
+		return nil
+	}
+	opts := &WalkOptions{Hidden: true}
+	if !b.w.opts.Recursive {
+		opts.MaxDepth = 1
+	}
+	return Walk(root, opts, func(e *WalkEntry) error {
+		if info, err := e.Info(); err == nil {
+			state[e.Path] = pollState{size: info.Size(), modTime: info.ModTime(), isDir: info.IsDir()}
+		}
+		return nil
+	})
+}
+
+func (b *pollBackend) loop() {
+	defer b.wg.Done()
+	ticker := time.NewTicker(b.w.opts.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-b.quit:
+			return
+		case <-ticker.C:
+		}
+		b.mutex.Lock()
+		state := make(map[string]pollState, len(b.state))
+		for root := range b.roots {
+			if err := b.scan(root, state); err != nil && !os.IsNotExist(err) {
+				b.w.fail(err)
+			}
+		}
+		events := diffPollState(b.state, state)
+		b.state = state
+		b.mutex.Unlock()
+		for _, ev := range events {
+			b.w.emit(ev)
+		}
+	}
+}
+
+func diffPollState(old, curr map[string]pollState) []Event {
+	var events []Event
+	for path, st := range curr {
+		prev, ok := old[path]
+		if !ok {
+			events = append(events, Event{Path: path, Op: EVENT_CREATE})
+		} else if !st.isDir && (st.size != prev.size || !st.modTime.Equal(prev.modTime)) {
+			events = append(events, Event{Path: path, Op: EVENT_WRITE})
+		}
+	}
+	for path := range old {
+		if _, ok := curr[path]; !ok {
+			events = append(events, Event{Path: path, Op: EVENT_REMOVE})
+		}
+	}
+	sort.Slice(events, func(i, j int) bool {
+		return events[i].Path < events[j].Path
+	})
+	return events
+}
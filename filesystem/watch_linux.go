@@ -0,0 +1,255 @@
+//go:build linux
+// +build linux
+
+package filesystem
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"unsafe"
+)
+
+const inotifyMask = syscall.IN_CREATE | syscall.IN_MODIFY | syscall.IN_CLOSE_WRITE |
+	syscall.IN_DELETE | syscall.IN_DELETE_SELF | syscall.IN_MOVED_FROM |
+	syscall.IN_MOVED_TO | syscall.IN_MOVE_SELF
+
+var errInotifyOverflow = errors.New("inotify event queue overflow")
+
+type inotifyBackend struct {
+	w     *Watcher
+	fp    *os.File
+	fd    int
+	paths map[int]string // wd -> 路径
+	wds   map[string]int
+	roots map[string]bool
+	lost  map[string]bool // 被删除或移走、等待重新出现的根路径
+	dirs  map[int]string  // 只为等待根路径而监视的上级目录
+	mutex sync.Mutex
+	wg    sync.WaitGroup
+}
+
+func newNativeBackend(w *Watcher) (watchBackend, error) {
+	fd, err := syscall.InotifyInit1(syscall.IN_CLOEXEC | syscall.IN_NONBLOCK)
+	if err != nil {
+		return nil, err
+	}
+	// 非阻塞的描述符由运行时轮询，Close可以打断Read
+	b := &inotifyBackend{w: w, fp: os.NewFile(uintptr(fd), "inotify"), fd: fd,
+		paths: make(map[int]string), wds: make(map[string]int), roots: make(map[string]bool),
+		lost: make(map[string]bool), dirs: make(map[int]string)}
+	b.wg.Add(1)
+	go b.loop()
+	return b, nil
+}
+
+func (b *inotifyBackend) add(path string) error {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.roots[path] = true
+	return b.addTree(path, nil)
+}
+
+// 添加监视，递归时包括子目录，events不为nil时收集已有内容的新建事件
+// 持有锁时不能发出事件，否则读取事件的一方调用Add或Remove会死锁
+func (b *inotifyBackend) addTree(path string, events *[]Event) error {
+	if err := b.addWatch(path); err != nil {
+		return err
+	}
+	info, err := os.Lstat(path)
+	if err != nil || !info.IsDir() || !b.w.opts.Recursive {
+		return err
+	}
+	return Walk(path, &WalkOptions{Hidden: true}, func(e *WalkEntry) error {
+		if events != nil && e.Path != path {
+			*events = append(*events, Event{Path: e.Path, Op: EVENT_CREATE})
+		}
+		if e.IsDir() {
+			if err := b.addWatch(e.Path); err != nil && !os.IsNotExist(err) {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (b *inotifyBackend) addWatch(path string) error {
+	wd, err := syscall.InotifyAddWatch(b.fd, path, inotifyMask)
+	if err != nil {
+		return &os.PathError{Op: "inotify_add_watch", Path: path, Err: err}
+	}
+	b.paths[wd], b.wds[path] = path, wd
+	return nil
+}
+
+func (b *inotifyBackend) remove(path string) error {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	delete(b.roots, path)
+	b.forget(path)
+	if b.lost[path] {
+		delete(b.lost, path)
+		b.forgetDir(filepath.Dir(path))
+	}
+	return nil
+}
+
+// 取消路径及其下级的监视
+func (b *inotifyBackend) forget(path string) {
+	prefix := path + string(filepath.Separator)
+	for p, wd := range b.wds {
+		if p == path || strings.HasPrefix(p, prefix) {
+			syscall.InotifyRmWatch(b.fd, uint32(wd))
+			delete(b.wds, p)
+			delete(b.paths, wd)
+		}
+	}
+}
+
+// 根路径被删除或移走（包括被改名覆盖）后重新监视
+// 路径已经存在时返回true，否则监视上级目录，等它重新出现
+func (b *inotifyBackend) rewatch(path string, events *[]Event) bool {
+	b.forget(path)
+	if err := b.addTree(path, events); err == nil {
+		return true
+	}
+	b.lost[path] = true
+	dir := filepath.Dir(path)
+	if _, ok := b.wds[dir]; !ok {
+		if wd, err := syscall.InotifyAddWatch(b.fd, dir, inotifyMask); err == nil {
+			b.dirs[wd] = dir
+		}
+	}
+	return false
+}
+
+// 没有根路径在等待时，取消对上级目录的监视
+func (b *inotifyBackend) forgetDir(dir string) {
+	for p := range b.lost {
+		if filepath.Dir(p) == dir {
+			return
+		}
+	}
+	for wd, d := range b.dirs {
+		if d == dir {
+			delete(b.dirs, wd)
+			if _, ok := b.paths[wd]; !ok {
+				syscall.InotifyRmWatch(b.fd, uint32(wd))
+			}
+		}
+	}
+}
+
+func (b *inotifyBackend) close() error {
+	err := b.fp.Close()
+	b.wg.Wait()
+	return err
+}
+
+func (b *inotifyBackend) loop() {
+	defer b.wg.Done()
+	buf := make([]byte, 64*1024)
+	for {
+		n, err := b.fp.Read(buf)
+		if err != nil {
+			if !errors.Is(err, os.ErrClosed) {
+				b.w.fail(err)
+			}
+			return
+		}
+		for offset := 0; offset+syscall.SizeofInotifyEvent <= n; {
+			raw := (*syscall.InotifyEvent)(unsafe.Pointer(&buf[offset]))
+			name := ""
+			if raw.Len > 0 {
+				start := offset + syscall.SizeofInotifyEvent
+				name = strings.TrimRight(string(buf[start:start+int(raw.Len)]), "\x00")
+			}
+			offset += syscall.SizeofInotifyEvent + int(raw.Len)
+			b.handle(int(raw.Wd), raw.Mask, name)
+		}
+	}
+}
+
+func (b *inotifyBackend) handle(wd int, mask uint32, name string) {
+	if mask&syscall.IN_Q_OVERFLOW != 0 {
+		b.w.fail(errInotifyOverflow)
+		return
+	}
+	var events []Event
+	b.mutex.Lock()
+	defer func() {
+		b.mutex.Unlock()
+		for _, ev := range events {
+			b.w.emit(ev)
+		}
+	}()
+	path, ok := b.paths[wd]
+	if mask&syscall.IN_IGNORED != 0 {
+		if ok && b.wds[path] == wd {
+			delete(b.wds, path)
+		}
+		delete(b.paths, wd)
+		delete(b.dirs, wd)
+		return
+	}
+	if !ok {
+		if path, ok = b.dirs[wd]; !ok {
+			return
+		}
+	}
+	if name != "" {
+		path = filepath.Join(path, name)
+	}
+	// 等待中的根路径重新出现
+	if b.lost[path] && mask&(syscall.IN_CREATE|syscall.IN_MOVED_TO) != 0 {
+		delete(b.lost, path)
+		b.forgetDir(filepath.Dir(path))
+		events = append(events, Event{Path: path, Op: EVENT_CREATE})
+		if err := b.addTree(path, &events); err != nil && !os.IsNotExist(err) {
+			b.w.fail(err)
+		}
+		return
+	}
+	if _, ok := b.paths[wd]; !ok {
+		return // 上级目录中的其他文件
+	}
+	isRoot := b.roots[path]
+	var op EventOp
+	switch {
+	case mask&(syscall.IN_CREATE|syscall.IN_MOVED_TO) != 0:
+		op = EVENT_CREATE
+		// 新建的子目录加入监视，并补发其中已有内容的事件
+		if mask&syscall.IN_ISDIR != 0 && b.w.opts.Recursive {
+			events = append(events, Event{Path: path, Op: op})
+			if err := b.addTree(path, &events); err != nil && !os.IsNotExist(err) {
+				b.w.fail(err)
+			}
+			return
+		}
+	case mask&(syscall.IN_MODIFY|syscall.IN_CLOSE_WRITE) != 0:
+		op = EVENT_WRITE
+	case mask&syscall.IN_DELETE != 0:
+		op = EVENT_REMOVE
+	case mask&syscall.IN_MOVED_FROM != 0:
+		op = EVENT_RENAME
+		// 移走的子目录不再监视，移到监视范围内时会重新加入
+		if mask&syscall.IN_ISDIR != 0 && !isRoot {
+			b.forget(path)
+		}
+	case mask&(syscall.IN_DELETE_SELF|syscall.IN_MOVE_SELF) != 0 && isRoot:
+		// 原子保存时新文件改名覆盖了根路径，视为修改
+		if b.rewatch(path, &events) {
+			op = EVENT_WRITE
+		} else if mask&syscall.IN_DELETE_SELF != 0 {
+			op = EVENT_REMOVE
+		} else {
+			op = EVENT_RENAME
+		}
+	}
+	if op != 0 {
+		events = append(events, Event{Path: path, Op: op})
+	}
+}
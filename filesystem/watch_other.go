@@ -0,0 +1,10 @@
+//go:build !linux
+// +build !linux
+
+package filesystem
+
+import "errors"
+
+func newNativeBackend(w *Watcher) (watchBackend, error) {
+	return nil, errors.New("native watcher is not supported")
+}
@@ -0,0 +1,164 @@
+package filesystem
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// 收集事件，直到安静一段时间
+func collectEvents(w *Watcher, quiet time.Duration) map[string]EventOp {
+	result := make(map[string]EventOp)
+	for {
+		select {
+		case ev := <-w.Events:
+			result[ev.Path] |= ev.Op
+		case <-time.After(quiet):
+			return result
+		}
+	}
+}
+
+func testWatcher(t *testing.T, opts *WatchOptions) {
+	tmp, _ := ioutil.TempDir("", "watch")
+	defer os.RemoveAll(tmp)
+	makeTree(t, tmp, map[string]string{"a.conf": "a", "sub/b.conf": "b"})
+	w, err := NewWatcher(opts)
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer w.Close()
+	assert.NoError(t, w.Add(tmp))
+	wait := 300 * time.Millisecond
+
+	appendFile(t, filepath.Join(tmp, "a.conf"), "more")
+	appendFile(t, filepath.Join(tmp, "a.conf"), "again")
+	appendFile(t, filepath.Join(tmp, "sub", "b.conf"), "more")
+	events := collectEvents(w, wait)
+	assert.Equal(t, EVENT_WRITE, events[filepath.Join(tmp, "a.conf")]&EVENT_WRITE)
+	assert.Equal(t, EVENT_WRITE, events[filepath.Join(tmp, "sub", "b.conf")]&EVENT_WRITE)
+
+	// 新建的子目录也被监视
+	os.MkdirAll(filepath.Join(tmp, "new", "deep"), DIR_MODE)
+	time.Sleep(wait / 2)
+	appendFile(t, filepath.Join(tmp, "new", "deep", "c.conf"), "c")
+	events = collectEvents(w, wait)
+	assert.NotZero(t, events[filepath.Join(tmp, "new")]&EVENT_CREATE)
+	assert.NotZero(t, events[filepath.Join(tmp, "new", "deep", "c.conf")]&EVENT_CREATE)
+
+	os.Remove(filepath.Join(tmp, "a.conf"))
+	events = collectEvents(w, wait)
+	assert.Equal(t, map[string]EventOp{filepath.Join(tmp, "a.conf"): EVENT_REMOVE}, events)
+
+	assert.NoError(t, w.Remove(tmp))
+	appendFile(t, filepath.Join(tmp, "sub", "b.conf"), "ignored")
+	assert.Empty(t, collectEvents(w, wait))
+	assert.NoError(t, w.Close())
+	_, ok := <-w.Events
+	assert.False(t, ok)
+}
+
+func TestWatcher(t *testing.T) {
+	opts := &WatchOptions{Recursive: true, Debounce: 50 * time.Millisecond}
+	w, _ := NewWatcher(opts)
+	native := !w.Polling()
+	w.Close()
+	if native {
+		t.Run("Native", func(t *testing.T) { testWatcher(t, opts) })
+	}
+	t.Run("Poll", func(t *testing.T) {
+		testWatcher(t, &WatchOptions{Recursive: true, Poll: true, Interval: 20 * time.Millisecond})
+	})
+}
+
+// 其他文件持续变化时，事件也在Debounce时间内发出
+func TestWatchBusy(t *testing.T) {
+	tmp, _ := ioutil.TempDir("", "watch")
+	defer os.RemoveAll(tmp)
+	w, err := NewWatcher(&WatchOptions{Debounce: 100 * time.Millisecond, Interval: 20 * time.Millisecond})
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer w.Close()
+	assert.NoError(t, w.Add(tmp))
+	quit, stopped := make(chan struct{}), make(chan struct{})
+	defer func() {
+		close(quit)
+		<-stopped
+	}()
+	go func() {
+		defer close(stopped)
+		busy := filepath.Join(tmp, "busy.log")
+		for {
+			select {
+			case <-quit:
+				return
+			case <-time.After(30 * time.Millisecond):
+				if fp, err := os.OpenFile(busy, os.O_WRONLY|os.O_CREATE|os.O_APPEND, FILE_MODE); err == nil {
+					fp.WriteString("line\n")
+					fp.Close()
+				}
+			}
+		}
+	}()
+	time.Sleep(200 * time.Millisecond)
+	conf := filepath.Join(tmp, "app.conf")
+	ioutil.WriteFile(conf, []byte("x"), FILE_MODE)
+	deadline := time.After(time.Second)
+	busyEvents := 0
+	for {
+		select {
+		case ev := <-w.Events:
+			if ev.Path == conf {
+				assert.NotZero(t, ev.Op&EVENT_CREATE)
+				assert.True(t, busyEvents > 0)
+				return
+			}
+			busyEvents++
+		case <-deadline:
+			t.Fatal("event of app.conf was not delivered")
+		}
+	}
+}
+
+func TestWatchFile(t *testing.T) {
+	tmp, _ := ioutil.TempDir("", "watch")
+	defer os.RemoveAll(tmp)
+	fname := filepath.Join(tmp, "app.conf")
+	appendFile(t, fname, "a")
+	w, _ := NewWatcher(nil)
+	defer w.Close()
+	assert.NoError(t, w.Add(fname))
+	assert.Error(t, w.Add(filepath.Join(tmp, "missing")))
+	appendFile(t, fname, "b")
+	select {
+	case ev := <-w.Events:
+		assert.Equal(t, Event{Path: fname, Op: EVENT_WRITE}, ev)
+		assert.Equal(t, "write "+fname, ev.String())
+	case <-time.After(3 * time.Second):
+		t.Fatal("no event")
+	}
+	assert.Equal(t, "create|rename", (EVENT_CREATE | EVENT_RENAME).String())
+
+	// 原子保存会替换文件，之后的修改仍然能收到
+	for i := 0; i < 3; i++ {
+		assert.NoError(t, WriteFileAtomic(fname, []byte(fmt.Sprintf("v%d", i)), FILE_MODE))
+		events := collectEvents(w, 300*time.Millisecond)
+		assert.Equal(t, map[string]EventOp{fname: EVENT_WRITE}, events, "save %d", i)
+	}
+	// 删除后重新创建
+	os.Remove(fname)
+	assert.Equal(t, map[string]EventOp{fname: EVENT_REMOVE}, collectEvents(w, 300*time.Millisecond))
+	appendFile(t, filepath.Join(tmp, "other.conf"), "x")
+	appendFile(t, fname, "c")
+	events := collectEvents(w, 300*time.Millisecond)
+	assert.NotZero(t, events[fname]&EVENT_CREATE)
+	assert.NotContains(t, events, filepath.Join(tmp, "other.conf"))
+	appendFile(t, fname, "d")
+	assert.Equal(t, map[string]EventOp{fname: EVENT_WRITE}, collectEvents(w, 300*time.Millisecond))
+}
@@ -0,0 +1,151 @@
+package geohash
+
+import (
+	"math"
+	"strings"
+)
+
+const (
+	GEOHASH_BASE32    = "0123456789bcdefghjkmnpqrstuvwxyz"
+	GEOHASH_MAX_CHARS = 12
+)
+
+// 字符在base32字母表中的位置，不合法的字符为-1
+var geohashIndex = func() [256]int8 {
+	var idx [256]int8
+	for i := range idx {
+		idx[i] = -1
+	}
+	for i := 0; i < len(GEOHASH_BASE32); i++ {
+		idx[GEOHASH_BASE32[i]] = int8(i)
+	}
+	return idx
+}()
+
+// 标准的base32 Geohash，经度和纬度交错编码，Z形曲线
+// 与Redis GEO、Elasticsearch等通用，但只能用于字符串前缀，不能得到连续的整数区间
+type Geohash struct {
+	prec int
+}
+
+// 根据距离（米）选择精度，网格的长和宽都不超过distance，最多12位
+func NewGeohash(distance float64) *Geohash {
+	return &Geohash{prec: GeohashPrecision(distance)}
+}
+
+func GeohashPrecision(distance float64) int {
+	for prec := 1; prec < GEOHASH_MAX_CHARS; prec++ {
+		latBits, lngBits := geohashBits(prec)
+		height := precErrors[0] / math.Exp2(float64(latBits))
+		width := precErrors[0] * 2 / math.Exp2(float64(lngBits))
+		if math.Max(height, width) <= distance {
+			return prec
+		}
+	}
+	return GEOHASH_MAX_CHARS
+}
+
+func geohashBits(prec int) (latBits, lngBits int) {
+	bits := prec * 5
+	return bits / 2, bits - bits/2
+}
+
+func (g *Geohash) Precision() int {
+	return g.prec
+}
+
+func (g *Geohash) Encode(lat, lng float64) string {
+	if lat < LAT_MIN || lat > LAT_MAX || lng < LNG_MIN || lng > LNG_MAX {
+		return ""
+	}
+	minLat, maxLat, minLng, maxLng := LAT_MIN, LAT_MAX, LNG_MIN, LNG_MAX
+	var buf strings.Builder
+	even, bit, ch := true, 0, 0
+	for buf.Len() < g.prec {
+		if even { // 偶数位是经度
+			if mid := (minLng + maxLng) / 2; lng >= mid {
+				ch, minLng = ch<<1|1, mid
+			} else {
+				ch, maxLng = ch<<1, mid
+			}
+		} else {
+			if mid := (minLat + maxLat) / 2; lat >= mid {
+				ch, minLat = ch<<1|1, mid
+			} else {
+				ch, maxLat = ch<<1, mid
+			}
+		}
+		even = !even
+		if bit++; bit == 5 {
+			buf.WriteByte(GEOHASH_BASE32[ch])
+			bit, ch = 0, 0
+		}
+	}
+	return buf.String()
+}
+
+// 解码为网格的中心点，哈希不合法时返回0,0
+func (g *Geohash) Decode(hash string) (lat, lng float64) {
+	box, err := g.Bounds(hash)
+	if err != nil {
+		return
+	}
+	return box.Center()
+}
+
+// 哈希（或前缀）对应的经纬度范围，长度可以与精度不同
+func (g *Geohash) Bounds(hash string) (BBox, error) {
+	box := BBox{MinLat: LAT_MIN, MaxLat: LAT_MAX, MinLng: LNG_MIN, MaxLng: LNG_MAX}
+	even := true
+	for i := 0; i < len(hash); i++ {
+		ch := geohashIndex[hash[i]]
+		if ch < 0 {
+			return BBox{}, ErrInvalidHash
+		}
+		for mask := int8(16); mask > 0; mask >>= 1 {
+			if even {
+				mid := (box.MinLng + box.MaxLng) / 2
+				if ch&mask != 0 {
+					box.MinLng = mid
+				} else {
+					box.MaxLng = mid
+				}
+			} else {
+				mid := (box.MinLat + box.MaxLat) / 2
+				if ch&mask != 0 {
+					box.MinLat = mid
+				} else {
+					box.MaxLat = mid
+				}
+			}
+			even = !even
+		}
+	}
+	return box, nil
+}
+
+// 周围8个同样大小的网格，顺序与Coordinate.Neighbors相同
+func (g *Geohash) Neighbors(hash string) []string {
+	box, err := g.Bounds(hash)
+	if err != nil || hash == "" {
+		return nil
+	}
+	lat, lng := box.Center()
+	dlat, dlng := box.MaxLat-box.MinLat, box.MaxLng-box.MinLng
+	sub := &Geohash{prec: len(hash)}
+	var result []string
+	for _, off := range neighborOffsets {
+		nlat := lat + float64(off[1])*dlat
+		if nlat < LAT_MIN || nlat > LAT_MAX {
+			continue
+		}
+		nlng := lng + float64(off[0])*dlng
+		if nlng > LNG_MAX {
+			nlng -= 360
+		} else if nlng < LNG_MIN {
+			nlng += 360
+		}
+		result = append(result, sub.Encode(nlat, nlng))
+	}
+	return result
+}
@@ -0,0 +1,28 @@
+package geohash
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBase32Geohash(t *testing.T) {
+	g := &Geohash{prec: 11}
+	assert.Equal(t, "u4pruydqqvj", g.Encode(57.64911, 10.40744))
+	lat, lng := g.Decode("u4pruydqqvj")
+	assert.InDelta(t, 57.64911, lat, 1e-5)
+	assert.InDelta(t, 10.40744, lng, 1e-5)
+	assert.Equal(t, "ezs42", (&Geohash{prec: 5}).Encode(42.6, -5.6))
+
+	box, err := g.Bounds("ezs42")
+	assert.NoError(t, err)
+	assert.InDelta(t, 42.583, box.MinLat, 1e-3)
+	assert.InDelta(t, -5.625, box.MinLng, 1e-3)
+	assert.Equal(t, []string{"ezs48", "ezs49", "ezs43", "ezs41", "ezs40", "ezefp", "ezefr", "ezefx"},
+		g.Neighbors("ezs42"))
+	_, err = g.Bounds("ezs4a")
+	assert.Equal(t, ErrInvalidHash, err)
+
+	assert.Equal(t, 9, GeohashPrecision(20))
+	assert.Equal(t, 5, NewGeohash(5000).Precision())
+}
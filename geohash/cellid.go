@@ -0,0 +1,209 @@
+package geohash
+
+import (
+	"math/bits"
+	"strconv"
+	"strings"
+)
+
+// 网格ID的最大层级
+const MAX_CELL_LEVEL = 30
+
+// 64位的Hilbert网格ID，类似Google S2的CellID，但只有一个面
+// 高位是Hilbert序号，随后一个1作为结束标记，其余位为0
+// 同一网格的所有子网格ID都在 [RangeMin, RangeMax] 内，适合数据库范围查询
+// 第L层的网格与Coordinate长度为L的哈希前缀是同一个网格
+type CellID uint64
+
+// 坐标所在的网格，level取值0~30
+func CellIDFromLatLng(lat, lng float64, level int) CellID {
+	if level < 0 || level > MAX_CELL_LEVEL ||
+		lat < LAT_MIN || lat > LAT_MAX || lng < LNG_MIN || lng > LNG_MAX {
+		return 0
+	}
+	dim := int64(1) << level
+	x := int64((lng - LNG_MIN) / 360.0 * float64(dim))
+	y := int64((lat - LAT_MIN) / 180.0 * float64(dim))
+	if x >= dim {
+		x = dim - 1
+	}
+	if y >= dim {
+		y = dim - 1
+	}
+	return cellFromPos(level, hilbertD(dim, x, y))
+}
+
+// 由Coordinate的哈希（或前缀）得到网格ID
+func CellIDFromHash(hash string) CellID {
+	if len(hash) > MAX_CELL_LEVEL {
+		return 0
+	}
+	var pos int64
+	if hash != "" {
+		var err error
+		if pos, err = strconv.ParseInt(hash, 4, 64); err != nil {
+			return 0
+		}
+	}
+	return cellFromPos(len(hash), pos)
+}
+
+// 解析String()得到的十六进制字符串
+func CellIDFromToken(token string) CellID {
+	if token == "" || len(token) > 16 {
+		return 0
+	}
+	id, err := strconv.ParseUint(token+strings.Repeat("0", 16-len(token)), 16, 64)
+	if err != nil {
+		return 0
+	}
+	if c := CellID(id); c.IsValid() {
+		return c
+	}
+	return 0
+}
+
+func cellFromPos(level int, pos int64) CellID {
+	return CellID((uint64(pos)<<1 | 1) << uint(2*(MAX_CELL_LEVEL-level)+2))
+}
+
+func (c CellID) lsb() uint64 {
+	return uint64(c) & -uint64(c)
+}
+
+func (c CellID) IsValid() bool {
+	n := bits.TrailingZeros64(uint64(c))
+	return c != 0 && n%2 == 0 && n <= 2*MAX_CELL_LEVEL+2
+}
+
+func (c CellID) Level() int {
+	return MAX_CELL_LEVEL + 1 - bits.TrailingZeros64(uint64(c))/2
+}
+
+// 在本层中的Hilbert序号
+func (c CellID) Pos() int64 {
+	return int64(uint64(c) >> uint(bits.TrailingZeros64(uint64(c))+1))
+}
+
+// 上级网格，level大于当前层级时返回自身
+func (c CellID) Parent(level int) CellID {
+	if level < 0 || level >= c.Level() {
+		return c
+	}
+	lsb := uint64(1) << uint(2*(MAX_CELL_LEVEL-level)+2)
+	return CellID(uint64(c)&-lsb | lsb)
+}
+
+// 下一级的4个子网格
+func (c CellID) Children() [4]CellID {
+	var result [4]CellID
+	if c.Level() >= MAX_CELL_LEVEL {
+		return result
+	}
+	lsb := c.lsb() >> 2
+	id := uint64(c) - c.lsb() + lsb
+	for i := range result {
+		result[i] = CellID(id)
+		id += lsb << 1
+	}
+	return result
+}
+
+// 所有子孙网格ID的最小和最大值
+func (c CellID) RangeMin() CellID {
+	return CellID(uint64(c) - (c.lsb() - 1))
+}
+
+func (c CellID) RangeMax() CellID {
+	return CellID(uint64(c) + (c.lsb() - 1))
+}
+
+// 是否包含另一个网格（含自身）
+func (c CellID) Contains(o CellID) bool {
+	return o >= c.RangeMin() && o <= c.RangeMax()
+}
+
+// 对应的Coordinate哈希
+func (c CellID) Hash() string {
+	return formatCell(c.Level(), c.Pos())
+}
+
+func (c CellID) Bounds() BBox {
+	level := c.Level()
+	x, y := hilbertXY(int64(1)<<level, c.Pos())
+	return cellBox(level, x, y)
+}
+
+func (c CellID) Center() (lat, lng float64) {
+	return c.Bounds().Center()
+}
+
+// 去掉末尾0的十六进制字符串，同一层级时字符串顺序与ID顺序一致
+func (c CellID) String() string {
+	if c == 0 {
+		return "X"
+	}
+	s := strconv.FormatUint(uint64(c), 16)
+	s = strings.Repeat("0", 16-len(s)) + s
+	return strings.TrimRight(s, "0")
+}
+
+// 周围8个同样大小的网格，顺序与Coordinate.Neighbors相同
+func (c CellID) Neighbors() []CellID {
+	var result []CellID
+	for _, hash := range cellNeighbors(c.Hash()) {
+		result = append(result, CellIDFromHash(hash))
+	}
+	return result
+}
+
+// 使用网格ID字符串作为哈希的索引
+type CellIndexer struct {
+	level int
+}
+
+func NewCellIndexer(level int) *CellIndexer {
+	if level < 0 {
+		level = 0
+	} else if level > MAX_CELL_LEVEL {
+		level = MAX_CELL_LEVEL
+	}
+	return &CellIndexer{level: level}
+}
+
+func (ci *CellIndexer) Level() int {
+	return ci.level
+}
+
+func (ci *CellIndexer) Encode(lat, lng float64) string {
+	id := CellIDFromLatLng(lat, lng, ci.level)
+	if id == 0 {
+		return ""
+	}
+	return id.String()
+}
+
+func (ci *CellIndexer) Decode(hash string) (lat, lng float64) {
+	if id := CellIDFromToken(hash); id != 0 {
+		lat, lng = id.Center()
+	}
+	return
+}
+
+func (ci *CellIndexer) Bounds(hash string) (BBox, error) {
+	id := CellIDFromToken(hash)
+	if id == 0 {
+		return BBox{}, ErrInvalidHash
+	}
+	return id.Bounds(), nil
+}
+
+func (ci *CellIndexer) Neighbors(hash string) []string {
+	var result []string
+	if id := CellIDFromToken(hash); id != 0 {
+		for _, n := range id.Neighbors() {
+			result = append(result, n.String())
+		}
+	}
+	return result
+}
@@ -0,0 +1,56 @@
+package geohash
+
+import (
+	"testing"
+
+	"github.com/kellydunn/golang-geo"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCellID(t *testing.T) {
+	point := ToPoint(center)
+	coord := NewCoordinate(5)
+	hash := coord.Encode(point.Lat(), point.Lng())
+	id := CellIDFromLatLng(point.Lat(), point.Lng(), len(hash))
+	assert.True(t, id.IsValid())
+	assert.Equal(t, len(hash), id.Level())
+	assert.Equal(t, hash, id.Hash())
+	assert.Equal(t, id, CellIDFromHash(hash))
+	assert.Equal(t, id, CellIDFromToken(id.String()))
+
+	parent := id.Parent(10)
+	assert.Equal(t, 10, parent.Level())
+	assert.Equal(t, hash[:10], parent.Hash())
+	assert.True(t, parent.Contains(id))
+	assert.False(t, id.Contains(parent))
+	for i, child := range parent.Children() {
+		assert.Equal(t, 11, child.Level())
+		assert.Equal(t, parent, child.Parent(10))
+		assert.Equal(t, int64(i), child.Pos()-parent.Pos()*4)
+	}
+	assert.True(t, parent.Bounds().Contains(point.Lat(), point.Lng()))
+	assert.Len(t, parent.Neighbors(), 8)
+	assert.Equal(t, CellID(0), CellIDFromToken("8"))
+}
+
+func TestIndexers(t *testing.T) {
+	var ps []*geo.Point
+	for _, p := range road {
+		ps = append(ps, ToPoint(p))
+	}
+	point := ToPoint(inner)
+	indexers := []Indexer{NewCoordinate(20), NewGeohash(20), NewCellIndexer(20)}
+	for _, idx := range indexers {
+		hash := idx.Encode(point.Lat(), point.Lng())
+		box, err := idx.Bounds(hash)
+		assert.NoError(t, err)
+		assert.True(t, box.Contains(point.Lat(), point.Lng()), hash)
+		lat, lng := idx.Decode(hash)
+		assert.True(t, box.Contains(lat, lng))
+		assert.Len(t, idx.Neighbors(hash), 8)
+
+		f := NewStripeIndexer(20, idx, ps)
+		assert.True(t, f.Contains(point))
+		assert.False(t, f.Contains(ToPoint(outer)))
+	}
+}
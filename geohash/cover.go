@@ -0,0 +1,411 @@
+package geohash
+
+import (
+	"errors"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/kellydunn/golang-geo"
+)
+
+var ErrInvalidHash = errors.New("invalid geohash")
+
+// 经纬度范围
+type BBox struct {
+	MinLat, MinLng, MaxLat, MaxLng float64
+}
+
+func (b BBox) Contains(lat, lng float64) bool {
+	return lat >= b.MinLat && lat <= b.MaxLat && lng >= b.MinLng && lng <= b.MaxLng
+}
+
+// 两个范围是否相交（含边界）
+func (b BBox) Intersects(o BBox) bool {
+	return b.MinLat <= o.MaxLat && o.MinLat <= b.MaxLat &&
+		b.MinLng <= o.MaxLng && o.MinLng <= b.MaxLng
+}
+
+func (b BBox) Center() (lat, lng float64) {
+	return (b.MinLat + b.MaxLat) / 2, (b.MinLng + b.MaxLng) / 2
+}
+
+// 四个角，顺序为西南、东南、东北、西北
+func (b BBox) Corners() [4]*geo.Point {
+	return [4]*geo.Point{
+		geo.NewPoint(b.MinLat, b.MinLng), geo.NewPoint(b.MinLat, b.MaxLng),
+		geo.NewPoint(b.MaxLat, b.MaxLng), geo.NewPoint(b.MaxLat, b.MinLng),
+	}
+}
+
+// 整数哈希的闭区间，可直接用于 ZRANGEBYSCORE 或 BETWEEN 查询
+type HashRange struct {
+	Min, Max int64
+}
+
+func (r HashRange) Contains(code int64) bool {
+	return code >= r.Min && code <= r.Max
+}
+
+// 精度，即完整哈希的长度
+func (c *Coordinate) Precision() int {
+	return int(c.prec)
+}
+
+// 整数形式的哈希，与Encode的结果按4进制解析后相同，坐标不合法时返回-1
+func (c *Coordinate) EncodeInt(lat, lng float64) int64 {
+	if !c.Check(lat, lng) {
+		return -1
+	}
+	x, y := c.coord2int(lng, lat)
+	return c.xy2hash(int64(x), int64(y))
+}
+
+// 解析哈希，返回层级（即长度）和网格坐标
+func parseCell(hash string) (level int, x, y int64, err error) {
+	level = len(hash)
+	if level == 0 {
+		return
+	}
+	if level > 31 {
+		err = ErrInvalidHash
+		return
+	}
+	code, err := strconv.ParseInt(hash, 4, 64)
+	if err != nil {
+		err = ErrInvalidHash
+		return
+	}
+	x, y = hilbertXY(int64(1)<<level, code)
+	return
+}
+
+func formatCell(level int, code int64) string {
+	if level == 0 {
+		return ""
+	}
+	hash := strconv.FormatInt(code, 4)
+	if n := level - len(hash); n > 0 {
+		hash = strings.Repeat("0", n) + hash
+	}
+	return hash
+}
+
+func cellBox(level int, x, y int64) BBox {
+	dim := float64(int64(1) << level)
+	return BBox{
+		MinLat: float64(y)/dim*180 - 90, MaxLat: float64(y+1)/dim*180 - 90,
+		MinLng: float64(x)/dim*360 - 180, MaxLng: float64(x+1)/dim*360 - 180,
+	}
+}
+
+// 哈希（或前缀）对应的经纬度范围
+func (c *Coordinate) Bounds(hash string) (BBox, error) {
+	level, x, y, err := parseCell(hash)
+	if err != nil {
+		return BBox{}, err
+	}
+	return cellBox(level, x, y), nil
+}
+
+// 周围8个网格的经度和纬度方向偏移
+var neighborOffsets = [8][2]int64{
+	{0, 1}, {1, 1}, {1, 0}, {1, -1}, {0, -1}, {-1, -1}, {-1, 0}, {-1, 1},
+}
+
+// 周围8个同样大小的网格，顺序为北、东北、东、东南、南、西南、西、西北
+// 经度在180度处回绕，超出南北极的网格不返回
+func (c *Coordinate) Neighbors(hash string) []string {
+	return cellNeighbors(hash)
+}
+
+func cellNeighbors(hash string) []string {
+	level, x, y, err := parseCell(hash)
+	if err != nil || level == 0 {
+		return nil
+	}
+	dim := int64(1) << level
+	var result []string
+	for _, off := range neighborOffsets {
+		ny := y + off[1]
+		if ny < 0 || ny >= dim {
+			continue
+		}
+		nx := (x + off[0] + dim) % dim
+		result = append(result, formatCell(level, hilbertD(dim, nx, ny)))
+	}
+	return result
+}
+
+// 前缀对应的整数哈希区间
+func (c *Coordinate) PrefixRange(prefix string) (HashRange, error) {
+	if len(prefix) > int(c.prec) {
+		return HashRange{}, ErrInvalidHash
+	}
+	var code int64
+	if prefix != "" {
+		var err error
+		if code, err = strconv.ParseInt(prefix, 4, 64); err != nil {
+			return HashRange{}, ErrInvalidHash
+		}
+	}
+	shift := 2 * (c.prec - uint64(len(prefix)))
+	return HashRange{Min: code << shift, Max: (code+1)<<shift - 1}, nil
+}
+
+// 把前缀转为整数区间，相邻的区间合并
+func (c *Coordinate) CoverRanges(prefixes []string) []HashRange {
+	var ranges []HashRange
+	for _, prefix := range prefixes {
+		if r, err := c.PrefixRange(prefix); err == nil {
+			ranges = append(ranges, r)
+		}
+	}
+	sort.Slice(ranges, func(i, j int) bool {
+		return ranges[i].Min < ranges[j].Min
+	})
+	var result []HashRange
+	for _, r := range ranges {
+		if n := len(result); n > 0 && r.Min <= result[n-1].Max+1 {
+			if r.Max > result[n-1].Max {
+				result[n-1].Max = r.Max
+			}
+			continue
+		}
+		result = append(result, r)
+	}
+	return result
+}
+
+// 覆盖圆形区域的哈希前缀，radius单位为米
+// 网格最小约为半径的四分之一，但不会超过Coordinate的精度
+func (c *Coordinate) CoverCircle(center *geo.Point, radius float64) []string {
+	if center == nil || radius <= 0 {
+		return nil
+	}
+	r := &circleRegion{center: center, radius: radius}
+	return c.cover(r, GetCoordPreLen(radius)+2)
+}
+
+// 覆盖多边形区域的哈希前缀，网格最小约为多边形跨度的八分之一
+func (c *Coordinate) CoverPolygon(poly *Polygon) []string {
+	if poly == nil || !poly.IsClosed() {
+		return nil
+	}
+	r := newPolygonRegion(poly)
+	sw := geo.NewPoint(r.box.MinLat, r.box.MinLng)
+	ne := geo.NewPoint(r.box.MaxLat, r.box.MaxLng)
+	span := sw.GreatCircleDistance(ne) * 1000
+	return c.cover(r, GetCoordPreLen(span)+3)
+}
+
+// 需要覆盖的区域
+type region interface {
+	bounds() BBox
+	intersects(b BBox) bool // 与网格有交集
+	covers(b BBox) bool     // 完全包含网格
+}
+
+// 从整个地球开始逐级细分，完全包含的网格不再细分，到maxLevel时部分相交的网格也保留
+func (c *Coordinate) cover(r region, maxLevel int) []string {
+	if maxLevel > int(c.prec) {
+		maxLevel = int(c.prec)
+	}
+	if maxLevel < 1 {
+		maxLevel = 1
+	}
+	var result []string
+	box := r.bounds()
+	var walk func(level int, x, y int64)
+	walk = func(level int, x, y int64) {
+		cell := cellBox(level, x, y)
+		if !box.Intersects(cell) || !r.intersects(cell) {
+			return
+		}
+		if level == maxLevel || r.covers(cell) {
+			result = append(result, formatCell(level, hilbertD(int64(1)<<level, x, y)))
+			return
+		}
+		for _, dx := range []int64{0, 1} {
+			for _, dy := range []int64{0, 1} {
+				walk(level+1, 2*x+dx, 2*y+dy)
+			}
+		}
+	}
+	walk(0, 0, 0)
+	return compactPrefixes(result)
+}
+
+// 同一网格的4个子网格都在时，用上一级网格代替
+func compactPrefixes(prefixes []string) []string {
+	for {
+		sort.Strings(prefixes)
+		var result []string
+		merged := false
+		for i := 0; i < len(prefixes); i++ {
+			p := prefixes[i]
+			if n := len(p); n > 0 && p[n-1] == '0' && i+3 < len(prefixes) {
+				parent := p[:n-1]
+				if prefixes[i+1] == parent+"1" && prefixes[i+2] == parent+"2" &&
+					prefixes[i+3] == parent+"3" {
+					result = append(result, parent)
+					i += 3
+					merged = true
+					continue
+				}
+			}
+			result = append(result, p)
+		}
+		prefixes = result
+		if !merged {
+			return prefixes
+		}
+	}
+}
+
+type circleRegion struct {
+	center *geo.Point
+	radius float64
+}
+
+func (r *circleRegion) dist(lat, lng float64) float64 {
+	return r.center.GreatCircleDistance(geo.NewPoint(lat, lng)) * 1000
+}
+
+// 距离（米）对应的纬度差，与geo计算距离时的地球半径一致
+// 围栏的距离会取整到米，所以多留1米
+func latDegrees(meters float64) float64 {
+	return (meters + 1) * 180 / (math.Pi * geo.EARTH_RADIUS * 1000)
+}
+
+func (r *circleRegion) bounds() BBox {
+	dlat := latDegrees(r.radius)
+	lat, lng := r.center.Lat(), r.center.Lng()
+	box := BBox{MinLat: lat - dlat, MaxLat: lat + dlat, MinLng: LNG_MIN, MaxLng: LNG_MAX}
+	if box.MinLat <= LAT_MIN || box.MaxLat >= LAT_MAX {
+		return box // 包含了极点
+	}
+	cos := math.Min(math.Cos(box.MinLat*math.Pi/180), math.Cos(box.MaxLat*math.Pi/180))
+	if dlng := dlat / cos; lng-dlng >= LNG_MIN && lng+dlng <= LNG_MAX {
+		box.MinLng, box.MaxLng = lng-dlng, lng+dlng
+	}
+	return box
+}
+
+func (r *circleRegion) intersects(b BBox) bool {
+	// 网格内离圆心最近的点
+	lat := math.Max(b.MinLat, math.Min(b.MaxLat, r.center.Lat()))
+	lng := r.center.Lng()
+	if lng < b.MinLng || lng > b.MaxLng {
+		if lngDiff(lng, b.MinLng) < lngDiff(lng, b.MaxLng) {
+			lng = b.MinLng
+		} else {
+			lng = b.MaxLng
+		}
+	}
+	return r.dist(lat, lng) <= r.radius
+}
+
+func (r *circleRegion) covers(b BBox) bool {
+	for _, p := range b.Corners() {
+		if r.dist(p.Lat(), p.Lng()) > r.radius {
+			return false
+		}
+	}
+	return true
+}
+
+// 经度差，考虑180度回绕
+func lngDiff(a, b float64) float64 {
+	d := math.Abs(a - b)
+	if d > 180 {
+		d = 360 - d
+	}
+	return d
+}
+
+type polygonRegion struct {
+	poly *Polygon
+	box  BBox
+}
+
+func newPolygonRegion(poly *Polygon) *polygonRegion {
+	r := &polygonRegion{poly: poly}
+	r.box = polygonBounds(poly)
+	return r
+}
+
+func polygonBounds(poly *Polygon) BBox {
+	box := BBox{MinLat: LAT_MAX, MinLng: LNG_MAX, MaxLat: LAT_MIN, MaxLng: LNG_MIN}
+	for _, p := range poly.Points() {
+		box.MinLat = math.Min(box.MinLat, p.Lat())
+		box.MaxLat = math.Max(box.MaxLat, p.Lat())
+		box.MinLng = math.Min(box.MinLng, p.Lng())
+		box.MaxLng = math.Max(box.MaxLng, p.Lng())
+	}
+	return box
+}
+
+func (r *polygonRegion) bounds() BBox {
+	return r.box
+}
+
+// 多边形的边是否与网格的边相交，或者有顶点落在网格内
+func (r *polygonRegion) crosses(b BBox) bool {
+	points := r.poly.Points()
+	corners := b.Corners()
+	for i, p := range points {
+		if b.Contains(p.Lat(), p.Lng()) {
+			return true
+		}
+		q := points[(i+1)%len(points)]
+		for j, a := range corners {
+			if segmentsIntersect(p, q, a, corners[(j+1)%4]) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (r *polygonRegion) intersects(b BBox) bool {
+	if r.crosses(b) {
+		return true
+	}
+	// 没有边相交时，要么网格在多边形内，要么互不相交
+	lat, lng := b.Center()
+	return r.poly.Contains(geo.NewPoint(lat, lng))
+}
+
+func (r *polygonRegion) covers(b BBox) bool {
+	if r.crosses(b) {
+		return false
+	}
+	lat, lng := b.Center()
+	return r.poly.Contains(geo.NewPoint(lat, lng))
+}
+
+// 平面上线段AB与CD是否相交（含端点）
+func segmentsIntersect(a, b, c, d *geo.Point) bool {
+	d1 := orientation(c, d, a)
+	d2 := orientation(c, d, b)
+	d3 := orientation(a, b, c)
+	d4 := orientation(a, b, d)
+	if ((d1 > 0 && d2 < 0) || (d1 < 0 && d2 > 0)) &&
+		((d3 > 0 && d4 < 0) || (d3 < 0 && d4 > 0)) {
+		return true
+	}
+	return (d1 == 0 && onSegment(c, d, a)) || (d2 == 0 && onSegment(c, d, b)) ||
+		(d3 == 0 && onSegment(a, b, c)) || (d4 == 0 && onSegment(a, b, d))
+}
+
+func orientation(a, b, c *geo.Point) float64 {
+	return (b.Lng()-a.Lng())*(c.Lat()-a.Lat()) - (b.Lat()-a.Lat())*(c.Lng()-a.Lng())
+}
+
+// 已知共线时，C是否在线段AB上
+func onSegment(a, b, c *geo.Point) bool {
+	return math.Min(a.Lat(), b.Lat()) <= c.Lat() && c.Lat() <= math.Max(a.Lat(), b.Lat()) &&
+		math.Min(a.Lng(), b.Lng()) <= c.Lng() && c.Lng() <= math.Max(a.Lng(), b.Lng())
+}
@@ -0,0 +1,93 @@
+package geohash
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/kellydunn/golang-geo"
+	"github.com/stretchr/testify/assert"
+)
+
+func hasPrefix(prefixes []string, hash string) bool {
+	for _, p := range prefixes {
+		if strings.HasPrefix(hash, p) {
+			return true
+		}
+	}
+	return false
+}
+
+func inRanges(ranges []HashRange, code int64) bool {
+	for _, r := range ranges {
+		if r.Contains(code) {
+			return true
+		}
+	}
+	return false
+}
+
+func TestNeighbors(t *testing.T) {
+	coord := NewCoordinate(5)
+	point := ToPoint(center)
+	hash := coord.Encode(point.Lat(), point.Lng())[:14]
+	box, err := coord.Bounds(hash)
+	assert.NoError(t, err)
+	assert.True(t, box.Contains(point.Lat(), point.Lng()))
+
+	neighbors := coord.Neighbors(hash)
+	assert.Len(t, neighbors, 8)
+	dlat, dlng := box.MaxLat-box.MinLat, box.MaxLng-box.MinLng
+	lat, lng := box.Center()
+	offsets := [][2]float64{{1, 0}, {1, 1}, {0, 1}, {-1, 1}, {-1, 0}, {-1, -1}, {0, -1}, {1, -1}}
+	for i, off := range offsets {
+		h := coord.Encode(lat+off[0]*dlat, lng+off[1]*dlng)
+		assert.Equal(t, h[:14], neighbors[i])
+	}
+
+	// 靠近北极时没有北边的网格，经度在180度回绕
+	top := coord.Neighbors(coord.Encode(89.999, 179.999)[:3])
+	assert.Len(t, top, 5)
+	assert.Contains(t, top, coord.Encode(89.999, -179.999)[:3])
+	assert.Nil(t, coord.Neighbors("12x"))
+}
+
+func TestCoverCircle(t *testing.T) {
+	coord := NewCoordinate(5)
+	c := ToPoint(center)
+	prefixes := coord.CoverCircle(c, 5000)
+	ranges := coord.CoverRanges(prefixes)
+	assert.NotEmpty(t, prefixes)
+	assert.True(t, len(ranges) <= len(prefixes))
+	t.Log(len(prefixes), "prefixes", len(ranges), "ranges")
+	for angle := 0; angle < 360; angle += 15 {
+		for _, dist := range []float64{0, 1, 2.5, 4.9} {
+			p := c.PointAtDistanceAndBearing(dist, float64(angle))
+			hash := coord.Encode(p.Lat(), p.Lng())
+			assert.True(t, hasPrefix(prefixes, hash), "%v %v", angle, dist)
+			assert.True(t, inRanges(ranges, coord.EncodeInt(p.Lat(), p.Lng())))
+		}
+		p := c.PointAtDistanceAndBearing(20, float64(angle))
+		assert.False(t, inRanges(ranges, coord.EncodeInt(p.Lat(), p.Lng())))
+	}
+}
+
+func TestCoverPolygon(t *testing.T) {
+	var ps []*geo.Point
+	for _, p := range points {
+		ps = append(ps, ToPoint(p))
+	}
+	coord := NewCoordinate(5)
+	poly := geo.NewPolygon(ps)
+	prefixes := coord.CoverPolygon(poly)
+	assert.NotEmpty(t, prefixes)
+	for _, p := range append(ps, ToPoint(center), ToPoint(inner)) {
+		assert.True(t, hasPrefix(prefixes, coord.Encode(p.Lat(), p.Lng())))
+	}
+	far := ToPoint(center).PointAtDistanceAndBearing(5, 0)
+	assert.False(t, hasPrefix(prefixes, coord.Encode(far.Lat(), far.Lng())))
+
+	r, err := coord.PrefixRange("23")
+	assert.NoError(t, err)
+	assert.Equal(t, HashRange{Min: 2<<42 | 3<<40, Max: (2<<42 | 4<<40) - 1}, r)
+	assert.Equal(t, []string{"1", "20"}, compactPrefixes([]string{"13", "20", "10", "11", "12"}))
+}
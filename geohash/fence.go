@@ -33,12 +33,35 @@ type Fence interface {
 	Contains(point *geo.Point) bool // 是否在围栏内（含边界）
 }
 
+// 有外接矩形的围栏，可以加入FenceIndex
+type Bounded interface {
+	Bounds() BBox
+}
+
+// 围栏的外接矩形，Polygon、Circle、Stripe以及实现了Bounded的围栏才有
+func FenceBounds(f Fence) (BBox, bool) {
+	switch v := f.(type) {
+	case Bounded:
+		return v.Bounds(), true
+	case *Polygon:
+		if v.IsClosed() {
+			return polygonBounds(v), true
+		}
+	}
+	return BBox{}, false
+}
+
 // 圆形围栏
 type Circle struct {
 	Center *geo.Point // 中心点
 	Radius int        // 半径
 }
 
+func (c *Circle) Bounds() BBox {
+	r := &circleRegion{center: c.Center, radius: float64(c.Radius)}
+	return r.bounds()
+}
+
 func (c *Circle) Contains(point *geo.Point) bool {
 	if c.Radius <= 0 {
 		return false
@@ -55,22 +78,27 @@ type Stripe struct {
 	padding int // 道路单边宽度（米）
 	points  map[string]*geo.Point
 	values  []string
-	coord   *Coordinate
+	index   Indexer
 }
 
 // padding为道路单边宽度（米）
 func NewStripe(padding int, points []*geo.Point) *Stripe {
-	s := &Stripe{padding, make(map[string]*geo.Point), nil, nil}
+	return NewStripeIndexer(padding, nil, points)
+}
+
+// 使用指定的索引方式计算哈希，index为nil时使用Hilbert哈希
+func NewStripeIndexer(padding int, index Indexer, points []*geo.Point) *Stripe {
+	s := &Stripe{padding, make(map[string]*geo.Point), nil, index}
 	s.Insert(points...)
 	return s
 }
 
-// 计算Hilbert哈希值
+// 计算哈希值，默认为Hilbert哈希
 func (s *Stripe) Hash(point *geo.Point) string {
-	if s.coord == nil {
-		s.coord = NewCoordinate(float64(s.padding))
+	if s.index == nil {
+		s.index = NewCoordinate(float64(s.padding))
 	}
-	return s.coord.Encode(point.Lat(), point.Lng())
+	return s.index.Encode(point.Lat(), point.Lng())
 }
 
 func (s *Stripe) Len() int {
@@ -124,6 +152,28 @@ func (s *Stripe) NearestPoints(point *geo.Point) (a, b *geo.Point) {
 	return
 }
 
+// 所有点的外接矩形，四周再加上道路宽度
+func (s *Stripe) Bounds() BBox {
+	box := BBox{MinLat: LAT_MAX, MinLng: LNG_MAX, MaxLat: LAT_MIN, MaxLng: LNG_MIN}
+	for _, p := range s.points {
+		box.MinLat = math.Min(box.MinLat, p.Lat())
+		box.MaxLat = math.Max(box.MaxLat, p.Lat())
+		box.MinLng = math.Min(box.MinLng, p.Lng())
+		box.MaxLng = math.Max(box.MaxLng, p.Lng())
+	}
+	dlat := latDegrees(float64(s.padding))
+	box.MinLat = math.Max(LAT_MIN, box.MinLat-dlat)
+	box.MaxLat = math.Min(LAT_MAX, box.MaxLat+dlat)
+	cos := math.Min(math.Cos(box.MinLat*math.Pi/180), math.Cos(box.MaxLat*math.Pi/180))
+	if dlng := dlat / cos; cos > 0 {
+		box.MinLng = math.Max(LNG_MIN, box.MinLng-dlng)
+		box.MaxLng = math.Min(LNG_MAX, box.MaxLng+dlng)
+	} else {
+		box.MinLng, box.MaxLng = LNG_MIN, LNG_MAX
+	}
+	return box
+}
+
 func (s *Stripe) Contains(point *geo.Point) bool {
 	var dist int
 	a, b := s.NearestPoints(point)
@@ -168,9 +168,18 @@ func (c *Coordinate) int2coord(x, y float64) (lng, lat float64) {
 	return
 }
 
-func (c *Coordinate) xy2hash(x, y int64) (d int64) {
+func (c *Coordinate) xy2hash(x, y int64) int64 {
+	return hilbertD(int64(c.dim), x, y)
+}
+
+func (c *Coordinate) hash2xy(d int64) (x, y int64) {
+	return hilbertXY(int64(c.dim), d)
+}
+
+// 网格坐标转为Hilbert曲线上的序号，dim为网格边长
+func hilbertD(dim, x, y int64) (d int64) {
 	var rx, ry int64
-	lvl := int64(c.dim) >> 1
+	lvl := dim >> 1
 	for lvl > 0 {
 		if (x & lvl) > 0 {
 			rx = 1
@@ -189,10 +198,10 @@ func (c *Coordinate) xy2hash(x, y int64) (d int64) {
 	return
 }
 
-func (c *Coordinate) hash2xy(d int64) (x, y int64) {
+func hilbertXY(dim, d int64) (x, y int64) {
 	var rx, ry int64
 	lvl := int64(1)
-	for lvl < int64(c.dim) {
+	for lvl < dim {
 		rx = 1 & (d >> 1)
 		ry = 1 & (d ^ rx)
 		x, y = coordRotate(lvl, x, y, rx, ry)
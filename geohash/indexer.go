@@ -0,0 +1,17 @@
+package geohash
+
+// 坐标与哈希字符串互转的索引方式
+// Coordinate是4进制的Hilbert哈希，Geohash是标准的base32哈希，CellIndexer是64位网格ID
+// 同一种索引的哈希按字符串排序后，相邻的哈希在空间上也相近
+type Indexer interface {
+	Encode(lat, lng float64) string
+	Decode(hash string) (lat, lng float64) // 网格中心点
+	Bounds(hash string) (BBox, error)
+	Neighbors(hash string) []string
+}
+
+var (
+	_ Indexer = (*Coordinate)(nil)
+	_ Indexer = (*Geohash)(nil)
+	_ Indexer = (*CellIndexer)(nil)
+)
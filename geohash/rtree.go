@@ -0,0 +1,340 @@
+package geohash
+
+import (
+	"errors"
+	"math"
+	"sort"
+	"sync"
+
+	"github.com/kellydunn/golang-geo"
+)
+
+var ErrNoBounds = errors.New("fence has no bounding box")
+
+// R树每个节点的最多和最少条目数
+const (
+	RTREE_MAX_ENTRIES = 16
+	RTREE_MIN_ENTRIES = 6
+)
+
+type rentry struct {
+	box   BBox
+	child *rnode // 非叶子节点的条目
+	id    string // 叶子节点的条目
+	fence Fence
+}
+
+type rnode struct {
+	leaf    bool
+	entries []*rentry
+	parent  *rnode
+}
+
+func (n *rnode) bounds() BBox {
+	if len(n.entries) == 0 {
+		return BBox{}
+	}
+	box := n.entries[0].box
+	for _, e := range n.entries[1:] {
+		box = unionBox(box, e.box)
+	}
+	return box
+}
+
+// 父节点中指向n的条目
+func (n *rnode) entryOf(child *rnode) *rentry {
+	for _, e := range n.entries {
+		if e.child == child {
+			return e
+		}
+	}
+	return nil
+}
+
+func (n *rnode) remove(e *rentry) {
+	for i, x := range n.entries {
+		if x == e {
+			n.entries = append(n.entries[:i], n.entries[i+1:]...)
+			return
+		}
+	}
+}
+
+// 围栏的空间索引（R树），快速找出包含某个点或与某个范围相交的围栏
+// 可以在查询的同时增删围栏，不处理跨越180度经线的围栏
+//
+//	idx := NewFenceIndex()
+//	idx.Insert("park", &Circle{Center: center, Radius: 1000})
+//	ids := idx.Containing(point)
+type FenceIndex struct {
+	root   *rnode
+	leafOf map[string]*rnode
+	mutex  sync.RWMutex
+}
+
+func NewFenceIndex() *FenceIndex {
+	return &FenceIndex{root: &rnode{leaf: true}, leafOf: make(map[string]*rnode)}
+}
+
+func (t *FenceIndex) Len() int {
+	t.mutex.RLock()
+	defer t.mutex.RUnlock()
+	return len(t.leafOf)
+}
+
+func (t *FenceIndex) Get(id string) Fence {
+	t.mutex.RLock()
+	defer t.mutex.RUnlock()
+	if n, ok := t.leafOf[id]; ok {
+		for _, e := range n.entries {
+			if e.id == id {
+				return e.fence
+			}
+		}
+	}
+	return nil
+}
+
+// 加入围栏，同名的围栏会被替换，围栏修改后需要重新加入
+func (t *FenceIndex) Insert(id string, f Fence) error {
+	box, ok := FenceBounds(f)
+	if !ok {
+		return ErrNoBounds
+	}
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.delete(id)
+	t.insert(&rentry{box: box, id: id, fence: f})
+	return nil
+}
+
+// 删除围栏，不存在时返回false
+func (t *FenceIndex) Delete(id string) bool {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	return t.delete(id)
+}
+
+// 外接矩形与box相交的围栏，按ID排序
+func (t *FenceIndex) Search(box BBox) []string {
+	var ids []string
+	t.search(box, func(e *rentry) {
+		ids = append(ids, e.id)
+	})
+	sort.Strings(ids)
+	return ids
+}
+
+// 包含该点的围栏，按ID排序
+func (t *FenceIndex) Containing(point *geo.Point) []string {
+	var ids []string
+	box := BBox{MinLat: point.Lat(), MaxLat: point.Lat(), MinLng: point.Lng(), MaxLng: point.Lng()}
+	t.search(box, func(e *rentry) {
+		if e.fence.Contains(point) {
+			ids = append(ids, e.id)
+		}
+	})
+	sort.Strings(ids)
+	return ids
+}
+
+func (t *FenceIndex) search(box BBox, fn func(e *rentry)) {
+	t.mutex.RLock()
+	defer t.mutex.RUnlock()
+	var walk func(n *rnode)
+	walk = func(n *rnode) {
+		for _, e := range n.entries {
+			if !box.Intersects(e.box) {
+				continue
+			}
+			if n.leaf {
+				fn(e)
+			} else {
+				walk(e.child)
+			}
+		}
+	}
+	walk(t.root)
+}
+
+func (t *FenceIndex) attach(n *rnode, e *rentry) {
+	n.entries = append(n.entries, e)
+	if e.child != nil {
+		e.child.parent = n
+	} else {
+		t.leafOf[e.id] = n
+	}
+}
+
+func (t *FenceIndex) insert(e *rentry) {
+	// 逐层选择扩大面积最小的子节点
+	n := t.root
+	for !n.leaf {
+		var best *rentry
+		bestGrow, bestArea := math.Inf(1), math.Inf(1)
+		for _, x := range n.entries {
+			area := boxArea(x.box)
+			grow := boxArea(unionBox(x.box, e.box)) - area
+			if grow < bestGrow || (grow == bestGrow && area < bestArea) {
+				best, bestGrow, bestArea = x, grow, area
+			}
+		}
+		n = best.child
+	}
+	t.attach(n, e)
+	t.adjust(n)
+}
+
+// 从n向上调整外接矩形，条目过多时分裂
+func (t *FenceIndex) adjust(n *rnode) {
+	for n != nil {
+		var sibling *rnode
+		if len(n.entries) > RTREE_MAX_ENTRIES {
+			sibling = t.split(n)
+		}
+		p := n.parent
+		if p == nil {
+			if sibling != nil {
+				root := &rnode{}
+				t.attach(root, &rentry{box: n.bounds(), child: n})
+				t.attach(root, &rentry{box: sibling.bounds(), child: sibling})
+				t.root = root
+			}
+			return
+		}
+		p.entryOf(n).box = n.bounds()
+		if sibling != nil {
+			t.attach(p, &rentry{box: sibling.bounds(), child: sibling})
+		}
+		n = p
+	}
+}
+
+// 平方复杂度的分裂，先选出放在一起最浪费的两个条目，再把其他条目分到两组中
+func (t *FenceIndex) split(n *rnode) *rnode {
+	entries := n.entries
+	var s1, s2 int
+	worst := math.Inf(-1)
+	for i := 0; i < len(entries); i++ {
+		for j := i + 1; j < len(entries); j++ {
+			a, b := entries[i].box, entries[j].box
+			waste := boxArea(unionBox(a, b)) - boxArea(a) - boxArea(b)
+			if waste > worst {
+				s1, s2, worst = i, j, waste
+			}
+		}
+	}
+	g1, g2 := []*rentry{entries[s1]}, []*rentry{entries[s2]}
+	b1, b2 := entries[s1].box, entries[s2].box
+	var rest []*rentry
+	for i, e := range entries {
+		if i != s1 && i != s2 {
+			rest = append(rest, e)
+		}
+	}
+	for len(rest) > 0 {
+		// 剩下的条目必须全给某一组才能满足最少条目数
+		if len(g1)+len(rest) <= RTREE_MIN_ENTRIES {
+			g1 = append(g1, rest...)
+			break
+		}
+		if len(g2)+len(rest) <= RTREE_MIN_ENTRIES {
+			g2 = append(g2, rest...)
+			break
+		}
+		// 选出对两组偏好差别最大的条目
+		var pick int
+		var d1, d2 float64
+		maxDiff := math.Inf(-1)
+		for i, e := range rest {
+			x1 := boxArea(unionBox(b1, e.box)) - boxArea(b1)
+			x2 := boxArea(unionBox(b2, e.box)) - boxArea(b2)
+			if diff := math.Abs(x1 - x2); diff > maxDiff {
+				pick, d1, d2, maxDiff = i, x1, x2, diff
+			}
+		}
+		e := rest[pick]
+		rest = append(rest[:pick], rest[pick+1:]...)
+		toFirst := d1 < d2
+		if d1 == d2 {
+			a1, a2 := boxArea(b1), boxArea(b2)
+			toFirst = a1 < a2 || (a1 == a2 && len(g1) <= len(g2))
+		}
+		if toFirst {
+			g1, b1 = append(g1, e), unionBox(b1, e.box)
+		} else {
+			g2, b2 = append(g2, e), unionBox(b2, e.box)
+		}
+	}
+	n.entries = g1
+	sibling := &rnode{leaf: n.leaf}
+	for _, e := range g2 {
+		t.attach(sibling, e)
+	}
+	return sibling
+}
+
+func (t *FenceIndex) delete(id string) bool {
+	n, ok := t.leafOf[id]
+	if !ok {
+		return false
+	}
+	for _, e := range n.entries {
+		if e.id == id {
+			n.remove(e)
+			break
+		}
+	}
+	delete(t.leafOf, id)
+	// 条目过少的节点整个移除，其中的围栏重新插入
+	var orphans []*rentry
+	for n != t.root {
+		p := n.parent
+		if len(n.entries) < RTREE_MIN_ENTRIES {
+			p.remove(p.entryOf(n))
+			orphans = collectLeaves(n, orphans)
+		} else {
+			p.entryOf(n).box = n.bounds()
+		}
+		n = p
+	}
+	t.shrinkRoot()
+	for _, e := range orphans {
+		t.insert(e)
+	}
+	t.shrinkRoot()
+	return true
+}
+
+// 根节点只有一个子节点时，树高减一
+func (t *FenceIndex) shrinkRoot() {
+	for !t.root.leaf && len(t.root.entries) <= 1 {
+		if len(t.root.entries) == 0 {
+			t.root = &rnode{leaf: true}
+			return
+		}
+		t.root = t.root.entries[0].child
+		t.root.parent = nil
+	}
+}
+
+func collectLeaves(n *rnode, result []*rentry) []*rentry {
+	if n.leaf {
+		return append(result, n.entries...)
+	}
+	for _, e := range n.entries {
+		result = collectLeaves(e.child, result)
+	}
+	return result
+}
+
+func unionBox(a, b BBox) BBox {
+	return BBox{
+		MinLat: math.Min(a.MinLat, b.MinLat), MinLng: math.Min(a.MinLng, b.MinLng),
+		MaxLat: math.Max(a.MaxLat, b.MaxLat), MaxLng: math.Max(a.MaxLng, b.MaxLng),
+	}
+}
+
+func boxArea(b BBox) float64 {
+	return (b.MaxLat - b.MinLat) * (b.MaxLng - b.MinLng)
+}
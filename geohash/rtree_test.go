@@ -0,0 +1,108 @@
+package geohash
+
+import (
+	"fmt"
+	"math/rand"
+	"sort"
+	"testing"
+
+	"github.com/kellydunn/golang-geo"
+	"github.com/stretchr/testify/assert"
+)
+
+// 逐个检查所有围栏，用于核对索引的结果
+func bruteContaining(fences map[string]Fence, point *geo.Point) []string {
+	var ids []string
+	for id, f := range fences {
+		if f.Contains(point) {
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+func TestFenceIndex(t *testing.T) {
+	var ps []*geo.Point
+	for _, p := range road {
+		ps = append(ps, ToPoint(p))
+	}
+	idx := NewFenceIndex()
+	assert.NoError(t, idx.Insert("circle", &Circle{Center: ToPoint(center), Radius: radius}))
+	assert.NoError(t, idx.Insert("polygon", geo.NewPolygon(ps)))
+	assert.NoError(t, idx.Insert("stripe", NewStripe(20, ps)))
+	assert.Equal(t, ErrNoBounds, idx.Insert("bad", geo.NewPolygon(ps[:2])))
+	assert.Equal(t, 3, idx.Len())
+
+	assert.Equal(t, []string{"circle", "stripe"}, idx.Containing(ToPoint(inner)))
+	far := ToPoint(center).PointAtDistanceAndBearing(50, 90)
+	assert.Empty(t, idx.Containing(far))
+	box := BBox{MinLat: 22.55, MaxLat: 22.56, MinLng: 114.04, MaxLng: 114.045}
+	assert.Equal(t, []string{"circle", "polygon", "stripe"}, idx.Search(box))
+
+	assert.True(t, idx.Delete("circle"))
+	assert.False(t, idx.Delete("circle"))
+	assert.Nil(t, idx.Get("circle"))
+	assert.Equal(t, []string{"stripe"}, idx.Containing(ToPoint(inner)))
+}
+
+func TestFenceIndexRandom(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	base := ToPoint(center)
+	randPoint := func() *geo.Point {
+		return base.PointAtDistanceAndBearing(rnd.Float64()*50, rnd.Float64()*360)
+	}
+	idx := NewFenceIndex()
+	fences := make(map[string]Fence)
+	for i := 0; i < 2000; i++ {
+		id := fmt.Sprintf("f%04d", i)
+		var f Fence = &Circle{Center: randPoint(), Radius: 200 + rnd.Intn(3000)}
+		if i%3 == 0 {
+			c := randPoint()
+			f = geo.NewPolygon([]*geo.Point{
+				c, c.PointAtDistanceAndBearing(2, 80), c.PointAtDistanceAndBearing(2, 10),
+			})
+		}
+		fences[id] = f
+		assert.NoError(t, idx.Insert(id, f))
+	}
+	for i := 0; i < 2000; i += 2 {
+		id := fmt.Sprintf("f%04d", i)
+		delete(fences, id)
+		assert.True(t, idx.Delete(id))
+	}
+	// 替换已有的围栏
+	fences["f0001"] = &Circle{Center: base, Radius: 100}
+	idx.Insert("f0001", fences["f0001"])
+	assert.Equal(t, len(fences), idx.Len())
+	for i := 0; i < 200; i++ {
+		p := randPoint()
+		assert.Equal(t, bruteContaining(fences, p), idx.Containing(p))
+	}
+	assert.Contains(t, idx.Containing(base), "f0001")
+}
+
+// 围栏边界附近的点，索引和逐个检查的结果一致
+func TestFenceIndexEdge(t *testing.T) {
+	c := geo.NewPoint(30, 110)
+	fences := map[string]Fence{
+		"circle": &Circle{Center: c, Radius: 10000},
+		"stripe": NewStripe(1000, []*geo.Point{geo.NewPoint(30, 111), geo.NewPoint(30, 111.1)}),
+	}
+	idx := NewFenceIndex()
+	for id, f := range fences {
+		assert.NoError(t, idx.Insert(id, f))
+	}
+	assert.Equal(t, []string{"circle"}, idx.Containing(geo.NewPoint(30.08988, 110)))
+	check := func(p *geo.Point, radius float64) {
+		for angle := 0; angle < 360; angle += 5 {
+			for dist := radius - 2; dist <= radius+2; dist += 0.25 {
+				q := p.PointAtDistanceAndBearing(dist/1000, float64(angle))
+				assert.Equal(t, bruteContaining(fences, q), idx.Containing(q), "%v %v", angle, dist)
+			}
+		}
+	}
+	check(c, 10000)
+	check(geo.NewPoint(30, 111), 1000)
+	check(geo.NewPoint(30, 111.1), 1000)
+}
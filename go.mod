@@ -1,18 +1,27 @@
 module github.com/azhai/gozzo-utils
 
-go 1.14
+go 1.18
 
 require (
-	github.com/erikstmartin/go-testdb v0.0.0-20160219214506-8d10e4a1bae5 // indirect
+	github.com/cespare/xxhash/v2 v2.3.0
 	github.com/gomodule/redigo v2.0.0+incompatible
 	github.com/kardianos/service v1.0.0
 	github.com/kellydunn/golang-geo v0.7.0
-	github.com/kylelemons/go-gypsy v0.0.0-20160905020020-08cad365cd28 // indirect
-	github.com/lib/pq v1.4.0 // indirect
+	github.com/klauspost/compress v1.17.2
 	github.com/streadway/amqp v0.0.0-20200108173154-1c71cc93ed71
 	github.com/stretchr/testify v1.5.1
-	github.com/ziutek/mymysql v1.5.4 // indirect
 	go.uber.org/zap v1.15.0
-	golang.org/x/sys v0.0.0-20200428200454-593003d681fa // indirect
+	golang.org/x/sys v0.0.0-20200428200454-593003d681fa
+)
+
+require (
+	github.com/davecgh/go-spew v1.1.1 // indirect
+	github.com/erikstmartin/go-testdb v0.0.0-20160219214506-8d10e4a1bae5 // indirect
+	github.com/kylelemons/go-gypsy v0.0.0-20160905020020-08cad365cd28 // indirect
+	github.com/lib/pq v1.4.0 // indirect
+	github.com/pmezard/go-difflib v1.0.0 // indirect
+	github.com/ziutek/mymysql v1.5.4 // indirect
+	go.uber.org/atomic v1.6.0 // indirect
+	go.uber.org/multierr v1.5.0 // indirect
 	gopkg.in/yaml.v2 v2.2.8 // indirect
 )
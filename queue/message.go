@@ -36,6 +36,7 @@ type Message struct {
 	Exchange  string // basic.publish exchange
 	Routing   string // basic.publish routing key
 	QueueName string
+	acked     chan error // 来自磁盘队列的消息，发布者在此报告结果
 }
 
 func NewMessage(body []byte) *Message {
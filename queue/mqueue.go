@@ -5,6 +5,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/azhai/gozzo-utils/filesystem"
 	"github.com/azhai/gozzo-utils/logging"
 	"github.com/streadway/amqp"
 )
@@ -20,6 +21,7 @@ type MessageQueue struct {
 	logger   logging.ILogger
 	Input    chan *Message
 	Handlers map[string]RecvFunc
+	spool    *filesystem.DiskQueue
 }
 
 func NewMessageQueue() *MessageQueue {
@@ -59,7 +61,9 @@ func (mq *MessageQueue) AddMessage(msg *Message, routExch ...string) {
 			msg.Exchange = routExch[1]
 		}
 	}
-	mq.Input <- msg
+	if mq.spool == nil || !mq.pushSpool(msg) {
+		mq.Input <- msg
+	}
 	if mq.logger != nil {
 		mq.logger.Debug(msg.ToString())
 	}
@@ -82,6 +86,7 @@ func (mq *MessageQueue) Publish(ch *Channel, input chan *Message, retries int) (
 		select {
 		case msg = <-input:
 			err = ch.PushMessage(msg)
+			msg.ack(err)
 			if err != nil {
 				if !IsValidateError(err) {
 					errch <- err
@@ -1,12 +1,16 @@
 package queue
 
 import (
+	"errors"
 	"fmt"
+	"io/ioutil"
+	"os"
 	"sync/atomic"
 	"testing"
 	"time"
 
 	"github.com/azhai/gozzo-utils/common"
+	"github.com/azhai/gozzo-utils/filesystem"
 	"github.com/streadway/amqp"
 	"github.com/stretchr/testify/assert"
 )
@@ -163,3 +167,64 @@ func BenchmarkSubscribe(b *testing.B) {
 		mq.Input <- messages[idx+1]
 	}
 }
+
+func TestSpool(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "spool")
+	defer os.RemoveAll(dir)
+	spool, err := filesystem.OpenDiskQueue(dir, nil)
+	if !assert.NoError(t, err) {
+		return
+	}
+	mq := NewMessageQueue()
+	assert.NoError(t, mq.SetSpool(spool))
+	for i := 0; i < 3; i++ {
+		mq.AddMessage(CreateMessage(i)) // 没有发布者也不会阻塞
+	}
+	for i := 0; i < 3; i++ {
+		msg := <-mq.Input
+		assert.Equal(t, int16(i), msg.GetHeaderInt16("MsgId"))
+		assert.Equal(t, common.Hex2Bin(fmt.Sprintf(bodyTpl, i)), msg.Body)
+		msg.ack(nil)
+	}
+	spool.Close()
+}
+
+func TestSpoolRetry(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "spool")
+	defer os.RemoveAll(dir)
+	spool, err := filesystem.OpenDiskQueue(dir, nil)
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer spool.Close()
+	mq := NewMessageQueue()
+	assert.NoError(t, mq.SetSpool(spool))
+	c, _ := spool.Consumer(SPOOL_CONSUMER)
+	for i := 0; i < 3; i++ {
+		mq.AddMessage(CreateMessage(i))
+	}
+
+	// 发布失败的消息不确认，稍后重发
+	msg := <-mq.Input
+	assert.Equal(t, int16(0), msg.GetHeaderInt16("MsgId"))
+	msg.ack(errors.New("Exception (504) Reason: \"channel/connection is not open\""))
+	msg = <-mq.Input
+	assert.Equal(t, int16(0), msg.GetHeaderInt16("MsgId"))
+	committed, _ := c.Offset()
+	assert.Equal(t, int64(0), committed)
+	msg.ack(nil)
+
+	// 不合法的消息重发也没用，直接确认
+	msg = <-mq.Input
+	assert.Equal(t, int16(1), msg.GetHeaderInt16("MsgId"))
+	msg.ack(errors.New("header type int16 not supported"))
+	msg = <-mq.Input
+	assert.Equal(t, int16(2), msg.GetHeaderInt16("MsgId"))
+	msg.ack(nil)
+	for i := 0; i < 100 && spool.Len() > 0; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	assert.Equal(t, int64(0), spool.Len())
+	committed, _ = c.Offset()
+	assert.Equal(t, int64(3), committed)
+}
@@ -0,0 +1,137 @@
+package queue
+
+import (
+	"bytes"
+	"encoding/gob"
+	"time"
+
+	"github.com/azhai/gozzo-utils/filesystem"
+	"github.com/streadway/amqp"
+)
+
+// 消息写入磁盘队列时使用的消费者名称
+const SPOOL_CONSUMER = "publisher"
+
+// 发布成功的消息批量确认，队列读空时也会确认
+const (
+	SPOOL_COMMIT_EVERY     = 100
+	SPOOL_COMMIT_INTERVAL  = time.Second
+	SPOOL_COMPACT_INTERVAL = 10 * time.Second // 确认后最多这么久删除一次已确认的分段
+)
+
+func init() {
+	// Headers中可能出现的非基本类型
+	gob.Register(time.Time{})
+	gob.Register(amqp.Decimal{})
+	gob.Register(amqp.Table{})
+	gob.Register(map[string]interface{}{})
+	gob.Register([]interface{}{})
+}
+
+// 编码消息，用于写入磁盘队列
+func EncodeMessage(msg *Message) ([]byte, error) {
+	var buf bytes.Buffer
+	err := gob.NewEncoder(&buf).Encode(msg)
+	return buf.Bytes(), err
+}
+
+func DecodeMessage(data []byte) (*Message, error) {
+	msg := new(Message)
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(msg); err != nil {
+		return nil, err
+	}
+	if msg.Headers == nil {
+		msg.Headers = make(map[string]interface{})
+	}
+	return msg, nil
+}
+
+// 使用磁盘队列缓冲待发布的消息，AddMessage不再因为RabbitMQ不可用而阻塞
+// 进程重启后，还没有发布成功的消息会继续发送
+func (mq *MessageQueue) SetSpool(spool *filesystem.DiskQueue) error {
+	c, err := spool.Consumer(SPOOL_CONSUMER)
+	if err != nil {
+		return err
+	}
+	mq.spool = spool
+	go mq.drainSpool(c)
+	return nil
+}
+
+// 把磁盘队列中的消息依次交给Input，发布成功后才确认
+// 发布失败时同一条消息稍后重发，进程退出时未确认的消息下次启动会重新发送
+func (mq *MessageQueue) drainSpool(c *filesystem.QueueConsumer) {
+	pending, lastCommit, lastCompact := 0, time.Now(), time.Now()
+	commit := func() {
+		var err error
+		if pending > 0 {
+			err = c.Commit()
+			pending, lastCommit = 0, time.Now()
+		}
+		if err == nil && time.Since(lastCompact) >= SPOOL_COMPACT_INTERVAL {
+			_, err = mq.spool.Compact()
+			lastCompact = time.Now()
+		}
+		if err != nil && mq.logger != nil {
+			mq.logger.Error(err.Error())
+		}
+	}
+	for {
+		wait := mq.spool.Wait()
+		_, data, err := c.Next()
+		if err == filesystem.ErrQueueClosed {
+			return
+		} else if err == filesystem.ErrQueueEmpty {
+			commit()
+			<-wait
+			continue
+		} else if err != nil {
+			if mq.logger != nil {
+				mq.logger.Error(err.Error())
+			}
+			time.Sleep(1 * time.Second)
+			continue
+		}
+		if msg, err := DecodeMessage(data); err == nil {
+			mq.publishSpooled(msg)
+		} else if mq.logger != nil {
+			mq.logger.Error(err.Error()) // 无法解码的消息只能丢弃
+		}
+		pending++
+		if pending >= SPOOL_COMMIT_EVERY || time.Since(lastCommit) >= SPOOL_COMMIT_INTERVAL {
+			commit()
+		}
+	}
+}
+
+// 交给发布者并等待结果，直到发布成功或消息本身不合法
+func (mq *MessageQueue) publishSpooled(msg *Message) {
+	for {
+		msg.acked = make(chan error, 1)
+		mq.Input <- msg
+		err := <-msg.acked
+		if err == nil || IsValidateError(err) {
+			return
+		}
+		time.Sleep(1 * time.Second) // 等发布者重连
+	}
+}
+
+// 报告发布结果，不是来自磁盘队列的消息忽略
+func (m *Message) ack(err error) {
+	if m.acked != nil {
+		m.acked <- err
+	}
+}
+
+// 写入磁盘队列，失败时退回到直接发送
+func (mq *MessageQueue) pushSpool(msg *Message) bool {
+	data, err := EncodeMessage(msg)
+	if err == nil {
+		_, err = mq.spool.Push(data)
+	}
+	if err != nil && mq.logger != nil {
+		mq.logger.Error(err.Error())
+	}
+	return err == nil
+}
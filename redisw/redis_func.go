@@ -1,6 +1,8 @@
 package redisw
 
 import (
+	"time"
+
 	"github.com/gomodule/redigo/redis"
 )
 
@@ -84,3 +86,26 @@ func (r *RedisWrapper) IncrFloat(key string, offset float64) (float64, error) {
 	}
 	return redis.Float64(reply, err)
 }
+
+// 防重放的nonce存储，实现cryptogy.NonceStore
+type RedisNonceStore struct {
+	Prefix string
+	*RedisWrapper
+}
+
+func NewRedisNonceStore(r *RedisWrapper, prefix string) *RedisNonceStore {
+	return &RedisNonceStore{Prefix: prefix, RedisWrapper: r}
+}
+
+// 记录nonce，已存在时返回false
+func (s *RedisNonceStore) SaveNonce(nonce string, expire time.Duration) (bool, error) {
+	secs := int(expire / time.Second)
+	if secs < 1 {
+		secs = 1
+	}
+	reply, err := s.Exec("SET", s.Prefix+nonce, 1, "EX", secs, "NX")
+	if reply == nil && err == nil {
+		return false, nil
+	}
+	return ReplyBool(reply, err)
+}